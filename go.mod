@@ -7,6 +7,8 @@ require (
 	github.com/energye/golcl v1.1.2
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/spf13/viper v1.20.1
+	golang.org/x/crypto v0.32.0
+	golang.org/x/net v0.33.0
 )
 
 require (
@@ -0,0 +1,295 @@
+// Package audit 提供访问审计日志功能
+// 将导航、阻止、账户切换和下载等事件以JSONL格式写入本地轮转文件，并可批量上报到aegis
+package audit
+
+import (
+	"cef/internal/config"
+	"cef/pkg/external/aegis"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计事件类型
+const (
+	EventNavigation    = "navigation"     // 导航
+	EventBlocked       = "blocked"        // 访问被阻止
+	EventAccountChange = "account_change" // 账户切换
+	EventDownload      = "download"       // 下载
+	EventCertificate   = "certificate"    // 证书错误或证书固定校验
+	EventRewrite       = "rewrite"        // 响应内容被改写规则修改
+	EventCookies       = "cookies"        // 账户Cookie导入或导出
+	EventSession       = "session"        // 会话快照保存或恢复
+	EventSwitches      = "switches"       // 启用了降低安全性的命令行开关
+)
+
+// 审计决策
+const (
+	DecisionAllow    = "allow"
+	DecisionBlock    = "block"
+	DecisionRedirect = "redirect"
+	DecisionPage     = "page"
+	DecisionCancel   = "cancel"
+)
+
+const (
+	currentFileName = "audit.jsonl"
+	reportStream    = "audit"
+)
+
+// Record 审计记录
+type Record struct {
+	Time     time.Time   `json:"time"`
+	Event    string      `json:"event"`
+	Account  string      `json:"account,omitempty"`
+	WindowId int32       `json:"window_id,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Rule     string      `json:"rule,omitempty"`
+	Decision string      `json:"decision,omitempty"`
+	Detail   interface{} `json:"detail,omitempty"`
+}
+
+var defaultLogger *Logger
+
+// DefaultLogger 获取默认审计日志记录器
+func DefaultLogger() *Logger {
+	return defaultLogger
+}
+
+// SetDefault 设置默认审计日志记录器
+func SetDefault(logger *Logger) {
+	defaultLogger = logger
+}
+
+// Log 使用默认记录器写入审计记录，未设置默认记录器时忽略
+func Log(record Record) {
+	if defaultLogger != nil {
+		defaultLogger.Log(record)
+	}
+}
+
+// Logger 审计日志记录器
+type Logger struct {
+	lock    sync.Mutex
+	config  config.AuditConfig
+	file    *os.File
+	size    int64
+	pending []Record // 等待上报的记录
+	first   uint64   // pending[0]的序号，每条记录入队时按顺序编号
+	done    chan struct{}
+	closed  sync.Once
+	wg      sync.WaitGroup
+}
+
+// NewLogger 创建新的审计日志记录器实例
+func NewLogger(cfg config.AuditConfig) (*Logger, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join("logs", "audit")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushIntervalSec <= 0 {
+		cfg.FlushIntervalSec = 30
+	}
+	l := &Logger{
+		config: cfg,
+		done:   make(chan struct{}),
+	}
+	if err := os.MkdirAll(cfg.Dir, 0750); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %v", err)
+	}
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	l.cleanExpired()
+
+	if cfg.Upload {
+		l.wg.Add(1)
+		go l.uploadLoop()
+	}
+	return l, nil
+}
+
+// Log 写入一条审计记录
+func (l *Logger) Log(record Record) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		fmt.Printf("审计记录序列化失败: %v\n", err)
+		return
+	}
+	data = append(data, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return
+	}
+	if l.needRotate(int64(len(data))) {
+		if err = l.rotate(); err != nil {
+			fmt.Printf("审计日志轮转失败: %v\n", err)
+		}
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		fmt.Printf("审计日志写入失败: %v\n", err)
+	}
+
+	if l.config.Upload {
+		// 上报失败时积压的记录有上限，避免内存无限增长
+		if len(l.pending) >= l.config.BatchSize*10 {
+			l.pending = l.pending[1:]
+			l.first++
+		}
+		l.pending = append(l.pending, record)
+	}
+}
+
+// Close 上报剩余记录并关闭日志文件，重复调用时只关闭一次
+func (l *Logger) Close() {
+	l.closed.Do(func() {
+		close(l.done)
+		l.wg.Wait()
+
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if l.file != nil {
+			_ = l.file.Close()
+			l.file = nil
+		}
+	})
+}
+
+// openFile 打开当前日志文件
+func (l *Logger) openFile() error {
+	file, err := os.OpenFile(filepath.Join(l.config.Dir, currentFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("打开审计日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("读取审计日志文件信息失败: %v", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// needRotate 检查写入后是否超出文件大小上限
+func (l *Logger) needRotate(writeSize int64) bool {
+	maxSize := l.config.MaxSizeMB * 1024 * 1024
+	return maxSize > 0 && l.size > 0 && l.size+writeSize > maxSize
+}
+
+// rotate 将当前日志文件重命名为带时间戳的文件，并打开新的日志文件
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	rotatedName := fmt.Sprintf("audit-%s.jsonl", time.Now().Format("20060102-150405.000"))
+	if err := os.Rename(filepath.Join(l.config.Dir, currentFileName), filepath.Join(l.config.Dir, rotatedName)); err != nil {
+		fmt.Printf("审计日志重命名失败: %v\n", err)
+	}
+	if err := l.openFile(); err != nil {
+		return err
+	}
+	go l.cleanExpired()
+	return nil
+}
+
+// cleanExpired 删除超出保留天数的轮转日志文件
+func (l *Logger) cleanExpired() {
+	if l.config.RetentionDays <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(l.config.Dir)
+	if err != nil {
+		return
+	}
+	deadline := time.Now().AddDate(0, 0, -l.config.RetentionDays)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == currentFileName || !strings.HasPrefix(name, "audit-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(l.config.Dir, name)); err != nil {
+			fmt.Printf("删除过期审计日志失败: %v\n", err)
+		}
+	}
+}
+
+// uploadLoop 定时批量上报审计记录
+func (l *Logger) uploadLoop() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(time.Duration(l.config.FlushIntervalSec) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.flush()
+		case <-l.done:
+			l.flush()
+			return
+		}
+	}
+}
+
+// flush 上报积压的记录，失败时保留到下次上报
+func (l *Logger) flush() {
+	client := aegis.DefaultClient()
+	if client == nil {
+		return
+	}
+	for {
+		l.lock.Lock()
+		if len(l.pending) == 0 {
+			l.lock.Unlock()
+			return
+		}
+		size := len(l.pending)
+		if size > l.config.BatchSize {
+			size = l.config.BatchSize
+		}
+		batch := append([]Record(nil), l.pending[:size]...)
+		start := l.first
+		l.lock.Unlock()
+
+		if err := client.ReportEvents(reportStream, batch); err != nil {
+			fmt.Printf("审计日志上报失败: %v\n", err)
+			return
+		}
+
+		l.lock.Lock()
+		l.acknowledge(start, size)
+		l.lock.Unlock()
+	}
+}
+
+// acknowledge 移除已上报的记录，需要持有锁
+// 上报期间积压队列可能因超出上限从头部丢弃记录，按序号只移除本次上报且仍在队列中的部分
+func (l *Logger) acknowledge(start uint64, size int) {
+	end := start + uint64(size)
+	if end <= l.first {
+		return
+	}
+	remove := int(end - l.first)
+	if remove > len(l.pending) {
+		remove = len(l.pending)
+	}
+	l.pending = l.pending[remove:]
+	l.first += uint64(remove)
+}
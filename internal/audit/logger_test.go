@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"cef/internal/config"
+	"testing"
+)
+
+func newTestLogger(t *testing.T, batchSize int) *Logger {
+	t.Helper()
+	l, err := NewLogger(config.AuditConfig{Dir: t.TempDir(), BatchSize: batchSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不启动上报协程，由测试直接驱动积压队列
+	l.config.Upload = true
+	t.Cleanup(l.Close)
+	return l
+}
+
+func logURLs(l *Logger, urls ...string) {
+	for _, url := range urls {
+		l.Log(Record{Event: EventNavigation, URL: url})
+	}
+}
+
+func pendingURLs(l *Logger) []string {
+	var urls []string
+	for _, record := range l.pending {
+		urls = append(urls, record.URL)
+	}
+	return urls
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLogger_AcknowledgeRemovesBatch(t *testing.T) {
+	l := newTestLogger(t, 2)
+	logURLs(l, "a", "b", "c")
+	start := l.first
+	l.acknowledge(start, 2)
+	if got := pendingURLs(l); !equal(got, []string{"c"}) {
+		t.Fatalf("pending = %v", got)
+	}
+}
+
+func TestLogger_AcknowledgeAfterOverflow(t *testing.T) {
+	l := newTestLogger(t, 1) // 积压上限10条
+	logURLs(l, "0", "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	// 上报前3条期间又写入2条，头部的0、1被丢弃
+	start, size := l.first, 3
+	logURLs(l, "10", "11")
+	l.acknowledge(start, size)
+	want := []string{"3", "4", "5", "6", "7", "8", "9", "10", "11"}
+	if got := pendingURLs(l); !equal(got, want) {
+		t.Fatalf("pending = %v, want %v", got, want)
+	}
+}
+
+func TestLogger_AcknowledgeBatchFullyDropped(t *testing.T) {
+	l := newTestLogger(t, 1)
+	logURLs(l, "0", "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	start, size := l.first, 2
+	logURLs(l, "10", "11", "12")
+	l.acknowledge(start, size)
+	want := []string{"3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+	if got := pendingURLs(l); !equal(got, want) {
+		t.Fatalf("pending = %v, want %v", got, want)
+	}
+}
+
+func TestLogger_CloseTwice(t *testing.T) {
+	l, err := NewLogger(config.AuditConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	l.Close()
+	// 关闭后写入的记录被忽略
+	l.Log(Record{Event: EventNavigation, URL: "https://example.com"})
+}
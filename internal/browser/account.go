@@ -0,0 +1,140 @@
+// Package browser 窗口账户绑定
+// 每个窗口绑定一个账户，请求头、代理、指纹脚本和白名单按窗口的账户解析
+package browser
+
+import (
+	"fmt"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/cef/ipc"
+	"github.com/energye/energy/v2/cef/ipc/context"
+)
+
+// bindWindowAccount 将账户绑定到窗口
+func (h *EventHandler) bindWindowAccount(windowId int32, account string) {
+	if account == "" {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.windowAccounts[windowId] = account
+}
+
+// unbindWindowAccount 解除窗口绑定的账户
+func (h *EventHandler) unbindWindowAccount(windowId int32) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.windowAccounts, windowId)
+}
+
+// bindPendingWindow 记录浏览器尚未创建的窗口对应的账户
+// 浏览器创建前窗口ID无效，在OnAfterCreated中由bindCreatedWindow完成绑定
+func (h *EventHandler) bindPendingWindow(window cef.IBrowserWindow, account string) {
+	if account == "" {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pendingAccounts[window] = account
+}
+
+// bindCreatedWindow 浏览器创建后将待绑定的账户绑定到窗口ID
+func (h *EventHandler) bindCreatedWindow(window cef.IBrowserWindow) {
+	h.lock.Lock()
+	account, ok := h.pendingAccounts[window]
+	delete(h.pendingAccounts, window)
+	h.lock.Unlock()
+	if !ok {
+		return
+	}
+	h.bindWindowAccount(window.Id(), account)
+	h.sessionManager.Attach(window.Id(), account)
+}
+
+// getWindowAccount 获取窗口绑定的账户
+// 未绑定账户的窗口（如等待登录的窗口）返回空，使用默认配置
+func (h *EventHandler) getWindowAccount(window cef.IBrowserWindow) string {
+	if window == nil {
+		return ""
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.windowAccounts[window.Id()]
+}
+
+// GetWindowAccounts 获取所有窗口绑定的账户
+func (h *EventHandler) GetWindowAccounts() map[int32]string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	result := make(map[int32]string, len(h.windowAccounts))
+	for windowId, account := range h.windowAccounts {
+		result[windowId] = account
+	}
+	return result
+}
+
+// OpenAccountWindow 为指定账户打开新窗口，targetURL为空时打开账户配置的默认页面
+// 启用会话隔离时窗口使用账户的请求上下文，否则使用全局请求上下文
+func (h *EventHandler) OpenAccountWindow(account, targetURL string) {
+	if account == "" {
+		return
+	}
+	if targetURL == "" {
+		targetURL = h.browserConfig(account).App.DefaultURL
+	}
+	cef.RunOnMainThread(func() {
+		var context *cef.ICefRequestContext
+		if h.sessionManager.Enabled() {
+			if context = h.sessionManager.RequestContext(account); context == nil {
+				return
+			}
+		}
+		h.openAccountWindow(account, targetURL, context)
+	})
+}
+
+// registerAccountIPC 注册账户窗口相关的IPC事件
+// 内置页面可以通过ipc.emit("openAccountWindow", [account, url])为指定账户打开新窗口，其他页面的调用被拒绝
+func (h *EventHandler) registerAccountIPC() {
+	ipc.On("openAccountWindow", func(ctx context.IContext) {
+		if _, _, ok := internalIPCSender(ctx, "openAccountWindow"); !ok {
+			return
+		}
+		args := ctx.ArgumentList()
+		if args == nil || args.Size() < 1 {
+			return
+		}
+		h.OpenAccountWindow(args.GetStringByIndex(0), args.GetStringByIndex(1))
+	})
+}
+
+// openAccountWindow 打开绑定账户的新窗口，context为空时使用全局请求上下文
+// 需要在UI线程中调用
+func (h *EventHandler) openAccountWindow(account, targetURL string, context *cef.ICefRequestContext) cef.IBrowserWindow {
+	return h.createAccountWindow(account, targetURL, context, h.fingerprintExtraInfo(account))
+}
+
+// createAccountWindow 使用指定的extra_info打开绑定账户的新窗口，extraInfo需要包含账户的指纹脚本
+// 需要在UI线程中调用
+func (h *EventHandler) createAccountWindow(account, targetURL string, context *cef.ICefRequestContext, extraInfo *cef.ICefDictionaryValue) cef.IBrowserWindow {
+	screen := h.browserConfig(account).Screen
+	property := cef.BrowserWindow.Config.WindowProperty
+	property.Url = targetURL
+	property.Title = fmt.Sprintf("%s - %s", property.Title, account)
+	if screen.Width > 0 && screen.Height > 0 {
+		property.Width = int32(screen.Width)
+		property.Height = int32(screen.Height)
+	}
+	window := cef.NewBrowserWindow(nil, property, nil)
+	if window == nil {
+		fmt.Printf("创建账户窗口失败 - 账户: %s\n", account)
+		return nil
+	}
+	window.SetCreateBrowserExtraInfo("", context, extraInfo)
+	// 浏览器创建后才有窗口ID，先记录窗口对应的账户，在OnAfterCreated中绑定
+	h.bindPendingWindow(window, account)
+	h.setupChromiumEvents(window)
+	window.EnableAllDefaultEvent()
+	window.Show()
+	return window
+}
@@ -0,0 +1,148 @@
+// Package browser 账户切换事件
+// 窗口绑定的账户变化后通知订阅者，由订阅者按新账户刷新UA、代理、脚本并重新加载页面
+package browser
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+// AccountChangeEvent 账户切换事件
+type AccountChangeEvent struct {
+	Time     time.Time
+	Window   cef.IBrowserWindow
+	WindowId int32
+	Account  string // 新账户
+	Previous string // 切换前的账户，窗口首次识别到账户时为空
+}
+
+// AccountChangeHandler 处理账户切换事件
+type AccountChangeHandler func(event AccountChangeEvent)
+
+// accountSubscriber 账户切换事件订阅者
+type accountSubscriber struct {
+	name    string
+	handler AccountChangeHandler
+}
+
+// AccountEventBus 账户切换事件总线，按订阅顺序同步通知订阅者
+type AccountEventBus struct {
+	lock        sync.RWMutex
+	subscribers []accountSubscriber
+}
+
+// NewAccountEventBus 创建新的账户切换事件总线实例
+func NewAccountEventBus() *AccountEventBus {
+	return &AccountEventBus{}
+}
+
+// Subscribe 订阅账户切换事件，同名订阅者会被替换
+func (b *AccountEventBus) Subscribe(name string, handler AccountChangeHandler) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i := range b.subscribers {
+		if b.subscribers[i].name == name {
+			b.subscribers[i].handler = handler
+			return
+		}
+	}
+	b.subscribers = append(b.subscribers, accountSubscriber{name: name, handler: handler})
+}
+
+// Unsubscribe 取消订阅
+func (b *AccountEventBus) Unsubscribe(name string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i := range b.subscribers {
+		if b.subscribers[i].name == name {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish 发布账户切换事件，订阅者panic时不影响其他订阅者
+func (b *AccountEventBus) Publish(event AccountChangeEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.lock.RLock()
+	subscribers := append([]accountSubscriber(nil), b.subscribers...)
+	b.lock.RUnlock()
+	for _, subscriber := range subscribers {
+		b.notify(subscriber, event)
+	}
+}
+
+// notify 通知单个订阅者
+func (b *AccountEventBus) notify(subscriber accountSubscriber, event AccountChangeEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("账户切换事件处理失败 - 订阅者: %s, 错误: %v\n", subscriber.name, r)
+		}
+	}()
+	subscriber.handler(event)
+}
+
+// GetAccountEvents 获取账户切换事件总线（用于订阅账户切换）
+func (h *EventHandler) GetAccountEvents() *AccountEventBus {
+	return h.accountEvents
+}
+
+// subscribeAccountEvents 订阅账户切换后需要刷新的状态
+// 请求头在每次请求时按窗口账户设置，无需订阅
+func (h *EventHandler) subscribeAccountEvents() {
+	// 按新账户生成的指纹脚本不同，清除旧账户的脚本缓存
+	h.accountEvents.Subscribe("scripts", func(event AccountChangeEvent) {
+		h.scriptGenerator.Invalidate(event.Previous)
+		h.scriptGenerator.Invalidate(event.Account)
+		// 渲染进程之后创建的JS上下文使用新账户的脚本
+		if event.Window != nil {
+			h.sendFingerprintScripts(event.Window)
+		}
+	})
+	h.accountEvents.Subscribe("user_agent", h.overrideUserAgent)
+	h.accountEvents.Subscribe("proxy", h.reapplyProxy)
+	h.accountEvents.Subscribe("reload", h.reloadForAccount)
+}
+
+// overrideUserAgent 通过DevTools覆盖窗口的UA，使navigator.userAgent与请求头保持一致
+func (h *EventHandler) overrideUserAgent(event AccountChangeEvent) {
+	basic := h.browserConfig(event.Account).Basic
+	if basic.UserAgent == "" {
+		return
+	}
+	cef.RunOnMainThread(func() {
+		params := cef.DictionaryValueRef.New()
+		params.SetString("userAgent", basic.UserAgent)
+		params.SetString("acceptLanguage", basic.AcceptLanguage)
+		params.SetString("platform", basic.Platform)
+		event.Window.Chromium().ExecuteDevToolsMethod(0, "Network.setUserAgentOverride", params)
+	})
+}
+
+// reapplyProxy 按新账户的代理配置重新设置窗口请求上下文的代理
+func (h *EventHandler) reapplyProxy(event AccountChangeEvent) {
+	cef.RunOnMainThread(func() {
+		h.applyWindowProxy(event.Window)
+	})
+}
+
+// reloadForAccount 以新账户的身份重新加载窗口当前页面
+// 启用会话隔离时窗口会切换到账户会话的新窗口，不在原窗口重新加载
+func (h *EventHandler) reloadForAccount(event AccountChangeEvent) {
+	if h.sessionManager.Enabled() {
+		return
+	}
+	cef.RunOnMainThread(func() {
+		browser := event.Window.Chromium().Browser()
+		if browser == nil || !browser.IsValid() || isInternalPage(browser.MainFrame().Url()) {
+			return
+		}
+		fmt.Printf("账户切换，重新加载页面 - 窗口: %d, 账户: %s\n", event.WindowId, event.Account)
+		browser.Reload()
+	})
+}
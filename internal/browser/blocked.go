@@ -0,0 +1,72 @@
+// Package browser 阻止页面
+// 通过内置静态资源服务器渲染blocked.html，向用户说明访问被阻止的原因
+package browser
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	blockedPagePath     = "/blocked.html"
+	blockedPageTemplate = "resources/blocked.html"
+)
+
+// blockedPageData 阻止页面模板数据
+type blockedPageData struct {
+	URL     string
+	Message string
+	Account string
+	HomeURL string
+}
+
+// blockedPageURL 生成阻止页面地址
+func blockedPageURL(blockedURL, account string) string {
+	query := url.Values{}
+	query.Set("url", blockedURL)
+	if account != "" {
+		query.Set("account", account)
+	}
+	return fmt.Sprintf("%s%s?%s", assetServerOrigin, blockedPagePath, query.Encode())
+}
+
+// isInternalPage 检查URL是否为内置静态资源服务器的页面
+func isInternalPage(requestURL string) bool {
+	return strings.HasPrefix(requestURL, assetServerOrigin+"/")
+}
+
+// blockedPageHandler 渲染阻止页面
+func (init *Initializer) blockedPageHandler() http.HandlerFunc {
+	tmpl, err := template.ParseFS(init.resources, blockedPageTemplate)
+	if err != nil {
+		fmt.Printf("阻止页面模板加载失败: %v\n", err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tmpl == nil {
+			http.Error(w, "blocked page template not loaded", http.StatusInternalServerError)
+			return
+		}
+		account := r.URL.Query().Get("account")
+		blockedURL := r.URL.Query().Get("url")
+		validator := init.eventHandler.whitelistValidator
+		data := blockedPageData{
+			URL:     blockedURL,
+			Message: validator.Evaluate(blockedURL, account).Message,
+			Account: account,
+			HomeURL: validator.GetRedirectURL(account),
+		}
+		if data.Message == "" {
+			data.Message = validator.GetBlockedMessage(account)
+		}
+		if data.HomeURL == "" {
+			data.HomeURL = init.browserConfig.App.DefaultURL
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.Execute(w, data); err != nil {
+			fmt.Printf("阻止页面渲染失败: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package browser 证书错误处理
+// 将CEF的证书错误回调和已建立连接的证书交给security.CertificatePolicy判定
+package browser
+
+import (
+	"cef/internal/audit"
+	"fmt"
+	"net/url"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/consts"
+)
+
+// handleCertificateError 处理证书错误，返回true表示已通过callback决定继续
+func (h *EventHandler) handleCertificateError(browser *cef.ICefBrowser, certError consts.TCefErrorCode, requestURL string, sslInfo *cef.ICefSslInfo, callback *cef.ICefCallback, window cef.IBrowserWindow) bool {
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return false
+	}
+	var chain [][]byte
+	if sslInfo != nil && sslInfo.IsValid() {
+		chain = certificateChain(sslInfo.GetX509Certificate())
+	}
+	account := h.getWindowAccount(window)
+	decision := h.certificatePolicy.OnCertificateError(parsedURL.Hostname(), int32(certError), chain, account)
+
+	record := audit.Record{
+		Event:    audit.EventCertificate,
+		Account:  account,
+		WindowId: window.Id(),
+		URL:      requestURL,
+		Rule:     decision.Reason,
+		Decision: audit.DecisionBlock,
+		Detail:   map[string]int32{"cert_error": int32(certError)},
+	}
+	if !decision.Allowed {
+		fmt.Printf("证书错误，拒绝访问 - URL: %s, 错误码: %d, 原因: %s\n", requestURL, certError, decision.Reason)
+		audit.Log(record)
+		return false
+	}
+	fmt.Printf("证书错误已按策略放行 - URL: %s, 错误码: %d, 原因: %s\n", requestURL, certError, decision.Reason)
+	record.Decision = audit.DecisionAllow
+	audit.Log(record)
+	callback.Cont()
+	return true
+}
+
+// verifyCertificatePins 校验已加载页面的证书是否符合SPKI固定配置
+// 由导航记录访问回调触发，不符合时展示阻止页面
+// 注意：没有证书错误的连接CEF不提供请求完成前的证书回调，此处在页面加载完成后才校验，
+// 此时请求（包括Cookie）已经发送，页面脚本也可能已经执行，固定配置只能阻止继续使用该页面，不能防止数据经该连接泄露
+func (h *EventHandler) verifyCertificatePins(entry *cef.ICefNavigationEntry, window cef.IBrowserWindow) {
+	if entry == nil || !entry.IsValid() {
+		return
+	}
+	pageURL := entry.GetUrl()
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil || parsedURL.Scheme != "https" {
+		return
+	}
+	sslStatus := entry.GetSSLStatus()
+	if sslStatus == nil || !sslStatus.IsValid() {
+		return
+	}
+	account := h.getWindowAccount(window)
+	chain := certificateChain(sslStatus.GetX509Certificate())
+	if h.certificatePolicy.VerifyPins(parsedURL.Hostname(), chain, account) {
+		return
+	}
+	fmt.Println("证书公钥与固定配置不符，阻止访问:", pageURL)
+	audit.Log(audit.Record{
+		Event:    audit.EventCertificate,
+		Account:  account,
+		WindowId: window.Id(),
+		URL:      pageURL,
+		Rule:     "spki_pin_mismatch",
+		Decision: audit.DecisionPage,
+	})
+	if browser := window.Browser(); browser != nil && browser.IsValid() {
+		browser.MainFrame().LoadUrl(blockedPageURL(pageURL, account))
+	}
+}
+
+// certificateChain 获取DER编码的证书链，第一个为站点证书
+func certificateChain(cert *cef.ICefX509Certificate) [][]byte {
+	if cert == nil || !cert.IsValid() {
+		return nil
+	}
+	var chain [][]byte
+	if der := binaryValueBytes(cert.GetDerEncoded()); der != nil {
+		chain = append(chain, der)
+	}
+	if size := cert.GetIssuerChainSize(); size > 0 {
+		if issuers := cert.GetDEREncodedIssuerChain(size); issuers != nil {
+			for i := uint32(0); i < issuers.Count(); i++ {
+				if der := binaryValueBytes(issuers.Get(i)); der != nil {
+					chain = append(chain, der)
+				}
+			}
+			issuers.Free()
+		}
+	}
+	return chain
+}
+
+// binaryValueBytes 读取ICefBinaryValue的全部内容
+func binaryValueBytes(value *cef.ICefBinaryValue) []byte {
+	if value == nil || !value.IsValid() {
+		return nil
+	}
+	size := value.GetSize()
+	if size == 0 {
+		return nil
+	}
+	buffer := make([]byte, size)
+	n := value.GetData(buffer, 0)
+	return buffer[:n]
+}
@@ -0,0 +1,287 @@
+// Package browser 账户Cookie导入导出
+// 将账户请求上下文中的Cookie导出为JSON或Netscape cookies.txt，或导入到指定账户，只处理白名单域名下的Cookie
+// 导出内容包含HttpOnly的会话Cookie，IPC命令只接受内置页面的调用，并且只处理调用窗口绑定的账户
+package browser
+
+import (
+	"cef/internal/audit"
+	"cef/internal/cookies"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/cef/ipc"
+	"github.com/energye/energy/v2/cef/ipc/context"
+	"github.com/energye/energy/v2/cef/ipc/target"
+	"github.com/energye/energy/v2/consts"
+)
+
+// cookieVisitTimeout 读取Cookie的最长等待时间
+const cookieVisitTimeout = 5 * time.Second
+
+// CookieTransferResult Cookie导入导出结果，通过IPC返回给调用的内置页面
+type CookieTransferResult struct {
+	Account string `json:"account"`
+	Format  string `json:"format"`
+	Count   int    `json:"count"`             // 导出或导入的Cookie数量
+	Skipped int    `json:"skipped,omitempty"` // 不在白名单内或已过期而跳过的数量
+	Data    string `json:"data,omitempty"`    // 导出的内容
+	Error   string `json:"error,omitempty"`
+}
+
+// accountRequestContext 获取账户使用的请求上下文
+// 启用会话隔离时为账户会话的请求上下文，否则为全局请求上下文，需要在UI线程中调用
+func (h *EventHandler) accountRequestContext(account string) *cef.ICefRequestContext {
+	if h.sessionManager.Enabled() && account != "" {
+		return h.sessionManager.RequestContext(account)
+	}
+	return cef.RequestContextRef.Global()
+}
+
+// cookieAllowed Cookie的域名是否在账户的白名单内
+func (h *EventHandler) cookieAllowed(account string) func(cookies.Cookie) bool {
+	return func(cookie cookies.Cookie) bool {
+		return h.whitelistValidator.IsDomainAllowed(cookie.Domain, account)
+	}
+}
+
+// ExportCookies 导出账户请求上下文中白名单域名下的Cookie
+// 读取完成后在后台goroutine中调用done
+func (h *EventHandler) ExportCookies(account, format string, done func(data []byte, count int, err error)) {
+	cef.RunOnMainThread(func() {
+		requestContext := h.accountRequestContext(account)
+		if requestContext == nil {
+			go done(nil, 0, fmt.Errorf("账户%s的请求上下文不可用", account))
+			return
+		}
+		visitCookies(requestContext, func(all []cookies.Cookie) {
+			exported := cookies.Filter(all, h.cookieAllowed(account))
+			data, err := cookies.Marshal(exported, format)
+			if err != nil {
+				done(nil, 0, err)
+				return
+			}
+			logCookieTransfer(account, "export", format, len(exported), len(all)-len(exported))
+			done(data, len(exported), nil)
+		})
+	})
+}
+
+// ImportCookies 将Cookie导入账户的请求上下文，跳过不在白名单内和已过期的Cookie
+// format为空时按内容自动识别，设置完成后在后台goroutine中调用done
+func (h *EventHandler) ImportCookies(account, format string, data []byte, done func(imported, skipped int, err error)) {
+	parsed, err := cookies.Unmarshal(data, format)
+	if err != nil {
+		go done(0, 0, err)
+		return
+	}
+	now := time.Now()
+	allowed := h.cookieAllowed(account)
+	accepted := cookies.Filter(parsed, func(cookie cookies.Cookie) bool {
+		return !cookie.Expired(now) && allowed(cookie)
+	})
+	cef.RunOnMainThread(func() {
+		requestContext := h.accountRequestContext(account)
+		if requestContext == nil {
+			go done(0, len(parsed), fmt.Errorf("账户%s的请求上下文不可用", account))
+			return
+		}
+		manager := requestContext.GetCookieManager(nil)
+		imported := 0
+		for _, cookie := range accepted {
+			if setCookie(manager, cookie, now) {
+				imported++
+			}
+		}
+		manager.FlushStore(nil)
+		if format == "" {
+			format = cookies.DetectFormat(data)
+		}
+		logCookieTransfer(account, "import", format, imported, len(parsed)-imported)
+		go done(imported, len(parsed)-imported, nil)
+	})
+}
+
+// visitCookies 读取请求上下文中的所有Cookie，读取完成或超时后调用done
+// CEF在没有Cookie时不会回调访问器，也不通知访问结束，因此空的Cookie存储总是等待cookieVisitTimeout后才调用done
+func visitCookies(requestContext *cef.ICefRequestContext, done func([]cookies.Cookie)) {
+	var lock sync.Mutex
+	var result []cookies.Cookie
+	finished := make(chan struct{}, 1)
+	visitor := cef.CookieVisitorRef.New()
+	visitor.SetOnVisit(func(cookie *cef.TCefCookie, deleteCookie, visitNext *bool) {
+		lock.Lock()
+		result = append(result, fromCefCookie(cookie))
+		lock.Unlock()
+		*visitNext = true
+		if cookie.Count+1 >= cookie.Total {
+			select {
+			case finished <- struct{}{}:
+			default:
+			}
+		}
+	})
+	requestContext.GetCookieManager(nil).VisitAllCookies(visitor)
+
+	// 没有Cookie时不会回调访问器，超时后按已读取的Cookie结束
+	go func() {
+		select {
+		case <-finished:
+		case <-time.After(cookieVisitTimeout):
+		}
+		lock.Lock()
+		cookieList := append([]cookies.Cookie(nil), result...)
+		lock.Unlock()
+		done(cookieList)
+	}()
+}
+
+// setCookie 设置Cookie，仅对主机生效的Cookie不传域名
+func setCookie(manager *cef.ICefCookieManager, cookie cookies.Cookie, now time.Time) bool {
+	domain := cookie.Domain
+	if cookie.HostOnly() {
+		domain = ""
+	}
+	var expires time.Time
+	if cookie.Expires > 0 {
+		expires = time.Unix(cookie.Expires, 0)
+	}
+	return manager.SetCookie(cookie.URL(), cookie.Name, cookie.Value, domain, cookie.Path,
+		cookie.Secure, cookie.HttpOnly, cookie.Expires > 0, now, now, expires,
+		toCefSameSite(cookie.SameSite), toCefPriority(cookie.Priority), nil)
+}
+
+// fromCefCookie 将CEF的Cookie转换为导出格式
+func fromCefCookie(cookie *cef.TCefCookie) cookies.Cookie {
+	result := cookies.Cookie{
+		Domain:   cookie.Domain,
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.Httponly,
+	}
+	if cookie.HasExpires {
+		result.Expires = cookie.Expires.Unix()
+	}
+	switch cookie.SameSite {
+	case consts.Ccss_CEF_COOKIE_SAME_SITE_NO_RESTRICTION:
+		result.SameSite = cookies.SameSiteNoRestriction
+	case consts.Ccss_CEF_COOKIE_SAME_SITE_LAX_MODE:
+		result.SameSite = cookies.SameSiteLax
+	case consts.Ccss_CEF_COOKIE_SAME_SITE_STRICT_MODE:
+		result.SameSite = cookies.SameSiteStrict
+	}
+	switch cookie.Priority {
+	case consts.CEF_COOKIE_PRIORITY_LOW:
+		result.Priority = cookies.PriorityLow
+	case consts.CEF_COOKIE_PRIORITY_HIGH:
+		result.Priority = cookies.PriorityHigh
+	}
+	return result
+}
+
+// toCefSameSite 将导出格式的SameSite转换为CEF的取值
+func toCefSameSite(sameSite string) consts.TCefCookieSameSite {
+	switch sameSite {
+	case cookies.SameSiteNoRestriction:
+		return consts.Ccss_CEF_COOKIE_SAME_SITE_NO_RESTRICTION
+	case cookies.SameSiteLax:
+		return consts.Ccss_CEF_COOKIE_SAME_SITE_LAX_MODE
+	case cookies.SameSiteStrict:
+		return consts.Ccss_CEF_COOKIE_SAME_SITE_STRICT_MODE
+	}
+	return consts.Ccss_CEF_COOKIE_SAME_SITE_UNSPECIFIED
+}
+
+// toCefPriority 将导出格式的优先级转换为CEF的取值
+func toCefPriority(priority string) consts.TCefCookiePriority {
+	switch priority {
+	case cookies.PriorityLow:
+		return consts.CEF_COOKIE_PRIORITY_LOW
+	case cookies.PriorityHigh:
+		return consts.CEF_COOKIE_PRIORITY_HIGH
+	}
+	return consts.CEF_COOKIE_PRIORITY_MEDIUM
+}
+
+// logCookieTransfer 记录Cookie导入导出的审计日志
+func logCookieTransfer(account, action, format string, count, skipped int) {
+	fmt.Printf("Cookie导入导出 - 账户: %s, 操作: %s, 格式: %s, 数量: %d, 跳过: %d\n", account, action, format, count, skipped)
+	audit.Log(audit.Record{
+		Event:   audit.EventCookies,
+		Account: account,
+		Rule:    action,
+		Detail: map[string]interface{}{
+			"format":  format,
+			"count":   count,
+			"skipped": skipped,
+		},
+	})
+}
+
+// registerCookieIPC 注册Cookie导入导出的IPC命令，只接受内置页面的调用，只处理调用窗口绑定的账户，结果只发送给调用的框架
+// 内置页面通过ipc.emit("exportCookies", [format])导出，结果通过ipc.on("cookiesExported", ...)接收；
+// 通过ipc.emit("importCookies", [format, data])导入，format为空时按内容识别，结果通过ipc.on("cookiesImported", ...)接收
+func (h *EventHandler) registerCookieIPC() {
+	ipc.On("exportCookies", func(ctx context.IContext) {
+		sender, account, ok := h.cookieIPCSender(ctx, "exportCookies")
+		if !ok {
+			return
+		}
+		format := ctx.ArgumentList().GetStringByIndex(0)
+		if format == "" {
+			format = cookies.FormatJSON
+		}
+		h.ExportCookies(account, format, func(data []byte, count int, err error) {
+			result := CookieTransferResult{Account: account, Format: format, Count: count, Data: string(data)}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			emitCookieResult("cookiesExported", sender, result)
+		})
+	})
+	ipc.On("importCookies", func(ctx context.IContext) {
+		sender, account, ok := h.cookieIPCSender(ctx, "importCookies")
+		if !ok {
+			return
+		}
+		args := ctx.ArgumentList()
+		format, data := args.GetStringByIndex(0), []byte(args.GetStringByIndex(1))
+		if format == "" {
+			format = cookies.DetectFormat(data)
+		}
+		h.ImportCookies(account, format, data, func(imported, skipped int, err error) {
+			result := CookieTransferResult{Account: account, Format: format, Count: imported, Skipped: skipped}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			emitCookieResult("cookiesImported", sender, result)
+		})
+	})
+}
+
+// cookieIPCSender 校验Cookie命令来自内置页面，并获取调用窗口绑定的账户，窗口未绑定账户时拒绝
+func (h *EventHandler) cookieIPCSender(ctx context.IContext, event string) (target.ITarget, string, bool) {
+	window, frame, ok := internalIPCSender(ctx, event)
+	if !ok || ctx.ArgumentList() == nil {
+		return nil, "", false
+	}
+	account := h.getWindowAccount(window)
+	if account == "" {
+		fmt.Printf("拒绝IPC命令%s: 窗口%d未绑定账户\n", event, window.Id())
+		return nil, "", false
+	}
+	return frame.Target(), account, true
+}
+
+// emitCookieResult 将Cookie导入导出结果发送给调用的框架
+func emitCookieResult(name string, sender target.ITarget, result CookieTransferResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	ipc.EmitTarget(name, sender, string(data))
+}
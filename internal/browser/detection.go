@@ -0,0 +1,294 @@
+// Package browser 账户识别
+// 按配置的规则从响应内容、Cookie或页面元素中提取当前登录的账户
+package browser
+
+import (
+	"cef/internal/config"
+	"cef/internal/filter"
+	"cef/internal/security"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/cef/ipc"
+	"github.com/energye/energy/v2/cef/ipc/context"
+)
+
+// 账户识别来源
+const (
+	DetectionSourceResponse = "response" // 响应内容
+	DetectionSourceCookie   = "cookie"   // Cookie
+	DetectionSourceDOM      = "dom"      // 页面元素
+)
+
+// accountDetectedEvent 页面脚本上报DOM识别结果的IPC事件
+const accountDetectedEvent = "accountDetected"
+
+const (
+	// maxDetectedValueLength 页面上报的元素值的最大长度
+	maxDetectedValueLength = 1024
+	// maxAccountLength 账户的最大长度（与邮箱地址的长度上限一致）
+	maxAccountLength = 254
+)
+
+// defaultAccountDetectionRules 未配置识别规则时使用的内置规则
+var defaultAccountDetectionRules = []config.AccountDetectionRule{
+	{
+		Name:        "oceanengine-user-info",
+		Source:      DetectionSourceResponse,
+		HostPattern: "agent.oceanengine.com",
+		URLPattern:  "/user-info",
+		Regex:       `(?U)"email":"([[:graph:]]+)"`,
+	},
+}
+
+// AccountDetector 账户识别器
+type AccountDetector struct {
+	browserConfig func(...string) *config.BrowserConfig
+
+	lock    sync.Mutex
+	regexps map[string]*regexp.Regexp // 已编译的正则，编译失败时为nil
+}
+
+// NewAccountDetector 创建新的账户识别器实例
+func NewAccountDetector(browserConfig func(...string) *config.BrowserConfig) *AccountDetector {
+	return &AccountDetector{
+		browserConfig: browserConfig,
+		regexps:       make(map[string]*regexp.Regexp),
+	}
+}
+
+// Rules 获取识别规则，识别在绑定账户之前进行，因此使用默认配置
+func (d *AccountDetector) Rules() []config.AccountDetectionRule {
+	if rules := d.browserConfig().AccountDetection; len(rules) > 0 {
+		return rules
+	}
+	return defaultAccountDetectionRules
+}
+
+// MatchRules 获取指定来源下与页面匹配的规则
+// 来源为response时还需匹配响应URL
+func (d *AccountDetector) MatchRules(source, pageURL, responseURL string) []config.AccountDetectionRule {
+	var result []config.AccountDetectionRule
+	for _, rule := range d.Rules() {
+		if ruleSource(rule) != source || !matchPageHost(pageURL, rule.HostPattern) {
+			continue
+		}
+		if source == DetectionSourceResponse && rule.URLPattern != "" {
+			re := d.compile(rule.URLPattern)
+			if re == nil || !re.MatchString(responseURL) {
+				continue
+			}
+		}
+		result = append(result, rule)
+	}
+	return result
+}
+
+// ExtractFromBody 按规则从响应内容中提取账户，返回第一个成功提取的结果
+func (d *AccountDetector) ExtractFromBody(rules []config.AccountDetectionRule, body []byte) string {
+	for _, rule := range rules {
+		value := string(body)
+		if rule.JSONPath != "" {
+			var data interface{}
+			if err := json.Unmarshal(body, &data); err != nil {
+				continue
+			}
+			field, ok := filter.JSONPathGet(data, rule.JSONPath)
+			if !ok || field == nil {
+				continue
+			}
+			if text, ok := field.(string); ok {
+				value = text
+			} else {
+				value = fmt.Sprint(field)
+			}
+		}
+		if account := d.ExtractValue(rule, value); account != "" {
+			return account
+		}
+	}
+	return ""
+}
+
+// ExtractValue 使用规则的正则截取账户，未配置正则时返回去除空白的原值
+func (d *AccountDetector) ExtractValue(rule config.AccountDetectionRule, value string) string {
+	if rule.Regex == "" {
+		return strings.TrimSpace(value)
+	}
+	re := d.compile(rule.Regex)
+	if re == nil {
+		return ""
+	}
+	matched := re.FindStringSubmatch(value)
+	switch {
+	case len(matched) >= 2:
+		return strings.TrimSpace(matched[1])
+	case len(matched) == 1:
+		return strings.TrimSpace(matched[0])
+	}
+	return ""
+}
+
+// ExtractDOMValue 校验页面上报的元素值并提取账户
+// 值来自页面脚本，只接受识别脚本可能上报的值：已去除首尾空白、长度有限且不含控制字符；
+// 配置了正则时值必须匹配正则，提取的账户不能包含空白
+func (d *AccountDetector) ExtractDOMValue(rule config.AccountDetectionRule, value string) string {
+	if value == "" || len(value) > maxDetectedValueLength || value != strings.TrimSpace(value) {
+		return ""
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return ""
+		}
+	}
+	account := d.ExtractValue(rule, value)
+	if len(account) > maxAccountLength || strings.IndexFunc(account, unicode.IsSpace) >= 0 {
+		return ""
+	}
+	return account
+}
+
+// RuleByName 根据名称查找规则
+func (d *AccountDetector) RuleByName(name string) (config.AccountDetectionRule, bool) {
+	for _, rule := range d.Rules() {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return config.AccountDetectionRule{}, false
+}
+
+// compile 编译并缓存正则，避免在每次响应时重复编译
+func (d *AccountDetector) compile(pattern string) *regexp.Regexp {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if re, ok := d.regexps[pattern]; ok {
+		return re
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Printf("账户识别正则编译失败 - 正则: %s, 错误: %v\n", pattern, err)
+	}
+	d.regexps[pattern] = re
+	return re
+}
+
+// ruleSource 获取规则的提取来源，未配置时按配置的字段推断
+func ruleSource(rule config.AccountDetectionRule) string {
+	switch {
+	case rule.Source != "":
+		return rule.Source
+	case rule.CookieName != "":
+		return DetectionSourceCookie
+	case rule.Selector != "":
+		return DetectionSourceDOM
+	default:
+		return DetectionSourceResponse
+	}
+}
+
+// matchPageHost 检查页面域名是否匹配规则
+func matchPageHost(pageURL, hostPattern string) bool {
+	if hostPattern == "" {
+		return true
+	}
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	return security.MatchHost(parsedURL.Hostname(), hostPattern)
+}
+
+// onAccountDetected 识别到窗口的账户后绑定账户并切换账户会话
+func (h *EventHandler) onAccountDetected(window cef.IBrowserWindow, account string) {
+	if window == nil || account == "" {
+		return
+	}
+	h.setCurrentAccount(window, account)
+	h.switchAccountSession(window, account)
+}
+
+// detectAccountOnLoad 页面加载完成后按cookie和dom规则识别账户
+func (h *EventHandler) detectAccountOnLoad(browser *cef.ICefBrowser, frame *cef.ICefFrame, window cef.IBrowserWindow) {
+	pageURL := frame.Url()
+	for _, rule := range h.accountDetector.MatchRules(DetectionSourceCookie, pageURL, "") {
+		h.detectAccountFromCookie(browser, pageURL, rule, window)
+	}
+	for _, rule := range h.accountDetector.MatchRules(DetectionSourceDOM, pageURL, "") {
+		frame.ExecuteJavaScript(domDetectionScript(rule), "", 0)
+	}
+}
+
+// detectAccountFromCookie 从页面的Cookie中识别账户
+func (h *EventHandler) detectAccountFromCookie(browser *cef.ICefBrowser, pageURL string, rule config.AccountDetectionRule, window cef.IBrowserWindow) {
+	requestContext := browser.GetRequestContext()
+	if requestContext == nil || rule.CookieName == "" {
+		return
+	}
+	visitor := cef.CookieVisitorRef.New()
+	visitor.SetOnVisit(func(cookie *cef.TCefCookie, deleteCookie, result *bool) {
+		*result = true
+		if cookie.Name != rule.CookieName {
+			return
+		}
+		if account := h.accountDetector.ExtractValue(rule, cookie.Value); account != "" {
+			*result = false
+			h.onAccountDetected(window, account)
+		}
+	})
+	requestContext.GetCookieManager(nil).VisitUrlCookies(pageURL, true, visitor)
+}
+
+// domDetectionScript 生成读取页面元素并通过IPC上报的脚本
+// 单页应用的元素可能在加载完成后才渲染，脚本会轮询一段时间
+func domDetectionScript(rule config.AccountDetectionRule) string {
+	name, _ := json.Marshal(rule.Name)
+	selector, _ := json.Marshal(rule.Selector)
+	attribute, _ := json.Marshal(rule.Attribute)
+	return fmt.Sprintf(`(function(){
+    var name = %s, selector = %s, attribute = %s, times = 0;
+    var timer = setInterval(function(){
+        var el = document.querySelector(selector);
+        var value = el ? (attribute ? el.getAttribute(attribute) : el.textContent) : "";
+        if ((value && value.trim()) || ++times >= 20) {
+            clearInterval(timer);
+            if (value && value.trim() && window.ipc) {
+                ipc.emit(%q, [name, value.trim()]);
+            }
+        }
+    }, 500);
+})();`, name, selector, attribute, accountDetectedEvent)
+}
+
+// registerDetectionIPC 注册接收DOM识别结果的IPC事件
+// 页面中的任何脚本都可以发送该事件，只接受规则匹配的页面主框架发送的、符合规则提取格式的值
+func (h *EventHandler) registerDetectionIPC() {
+	ipc.On(accountDetectedEvent, func(ctx context.IContext) {
+		args := ctx.ArgumentList()
+		if args == nil || args.Size() < 2 {
+			return
+		}
+		rule, ok := h.accountDetector.RuleByName(args.GetStringByIndex(0))
+		if !ok || ruleSource(rule) != DetectionSourceDOM {
+			return
+		}
+		window, frame := ipcSender(ctx)
+		if window == nil || frame == nil || !frame.IsMain() {
+			return
+		}
+		pageURL := frame.Url()
+		if !matchPageHost(pageURL, rule.HostPattern) || !h.whitelistValidator.IsURLAllowed(pageURL, h.getWindowAccount(window)) {
+			fmt.Printf("拒绝账户识别结果 - 规则: %s, 页面不匹配: %s\n", rule.Name, pageURL)
+			return
+		}
+		if account := h.accountDetector.ExtractDOMValue(rule, args.GetStringByIndex(1)); account != "" {
+			h.onAccountDetected(window, account)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package browser
+
+import (
+	"cef/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestAccountDetector_ExtractDOMValue(t *testing.T) {
+	detector := NewAccountDetector(func(...string) *config.BrowserConfig { return &config.BrowserConfig{} })
+	plain := config.AccountDetectionRule{Name: "plain", Source: DetectionSourceDOM}
+	email := config.AccountDetectionRule{Name: "email", Source: DetectionSourceDOM, Regex: `([\w.+-]+@[\w-]+\.[\w.]+)`}
+	tests := []struct {
+		name  string
+		rule  config.AccountDetectionRule
+		value string
+		want  string
+	}{
+		{"plain", plain, "user@example.com", "user@example.com"},
+		{"empty", plain, "", ""},
+		{"untrimmed", plain, " user@example.com", ""},
+		{"control", plain, "user\x00@example.com", ""},
+		{"newline", plain, "user\n@example.com", ""},
+		{"inner space", plain, "user name", ""},
+		{"too long", plain, strings.Repeat("a", maxAccountLength+1), ""},
+		{"value too long", email, strings.Repeat("a", maxDetectedValueLength) + " a@b.com", ""},
+		{"regex", email, "当前账户: user@example.com", "user@example.com"},
+		{"regex mismatch", email, "not an email", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detector.ExtractDOMValue(tt.rule, tt.value); got != tt.want {
+				t.Fatalf("ExtractDOMValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPageHost(t *testing.T) {
+	tests := []struct {
+		pageURL     string
+		hostPattern string
+		want        bool
+	}{
+		{"https://agent.oceanengine.com/page", "agent.oceanengine.com", true},
+		{"https://sub.agent.oceanengine.com/", "agent.oceanengine.com", true},
+		{"https://evil.com/?agent.oceanengine.com", "agent.oceanengine.com", false},
+		{"https://agent.oceanengine.com.evil.com/", "agent.oceanengine.com", false},
+		{"https://any.com/", "", true},
+	}
+	for _, tt := range tests {
+		if got := matchPageHost(tt.pageURL, tt.hostPattern); got != tt.want {
+			t.Errorf("matchPageHost(%q, %q) = %v, want %v", tt.pageURL, tt.hostPattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package browser DevTools方法调用
+// 通过窗口的DevTools协议调用方法，并将结果按消息ID分发给调用方
+package browser
+
+import (
+	"sync"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+// devToolsCallback DevTools方法调用结果的回调，result只在回调期间有效
+type devToolsCallback func(success bool, result *cef.ICefDictionaryValue)
+
+// devToolsCalls 等待结果的DevTools方法调用
+type devToolsCalls struct {
+	lock    sync.Mutex
+	pending map[int64]devToolsCallback // 浏览器ID和消息ID -> 回调
+}
+
+// newDevToolsCalls 创建新的DevTools方法调用记录实例
+func newDevToolsCalls() *devToolsCalls {
+	return &devToolsCalls{pending: make(map[int64]devToolsCallback)}
+}
+
+// devToolsCallKey 浏览器ID和消息ID组成的调用标识，消息ID只在同一浏览器内唯一
+func devToolsCallKey(browserId, messageId int32) int64 {
+	return int64(browserId)<<32 | int64(uint32(messageId))
+}
+
+// call 执行DevTools方法，callback为空时不等待结果
+// 需要在UI线程中调用，结果在UI线程中回调
+func (c *devToolsCalls) call(window cef.IBrowserWindow, method string, params *cef.ICefDictionaryValue, callback devToolsCallback) bool {
+	if params == nil {
+		params = cef.DictionaryValueRef.New()
+	}
+	messageId := window.Chromium().ExecuteDevToolsMethod(0, method, params)
+	if messageId == 0 {
+		return false
+	}
+	if callback != nil {
+		// 结果在UI线程中回调，此处仍在UI线程，登记前不会收到结果
+		c.lock.Lock()
+		c.pending[devToolsCallKey(window.Chromium().BrowserId(), messageId)] = callback
+		c.lock.Unlock()
+	}
+	return true
+}
+
+// onResult 分发DevTools方法调用结果，未登记的调用（如不等待结果的调用）忽略
+func (c *devToolsCalls) onResult(browser *cef.ICefBrowser, messageId int32, success bool, result *cef.ICefValue) {
+	key := devToolsCallKey(browser.Identifier(), messageId)
+	c.lock.Lock()
+	callback, ok := c.pending[key]
+	delete(c.pending, key)
+	c.lock.Unlock()
+	if !ok {
+		return
+	}
+	var dict *cef.ICefDictionaryValue
+	if result != nil && result.IsValid() {
+		dict = result.GetDictionary()
+	}
+	callback(success && dict != nil, dict)
+}
+
+// cancel 窗口关闭时丢弃该浏览器未返回的调用，回调以失败结束
+func (c *devToolsCalls) cancel(browserId int32) {
+	c.lock.Lock()
+	var callbacks []devToolsCallback
+	for key, callback := range c.pending {
+		if int32(key>>32) == browserId {
+			callbacks = append(callbacks, callback)
+			delete(c.pending, key)
+		}
+	}
+	c.lock.Unlock()
+	for _, callback := range callbacks {
+		callback(false, nil)
+	}
+}
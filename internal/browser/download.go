@@ -0,0 +1,328 @@
+// Package browser 下载管理
+// 负责下载目录、类型与大小限制、文件名清理、进度通知和下载审计
+package browser
+
+import (
+	"cef/internal/audit"
+	"cef/internal/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+const (
+	defaultDownloadDir = "downloads"
+	maxFileNameLength  = 200
+)
+
+// Windows保留的设备文件名
+var reservedFileNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// DownloadEvent 下载进度事件
+type DownloadEvent struct {
+	Id            uint32 `json:"id"`
+	Account       string `json:"account"`
+	Url           string `json:"url"`
+	FullPath      string `json:"full_path"`
+	ReceivedBytes int64  `json:"received_bytes"`
+	TotalBytes    int64  `json:"total_bytes"`
+	Percent       int32  `json:"percent"`
+	Complete      bool   `json:"complete"`
+	Canceled      bool   `json:"canceled"`
+}
+
+// DownloadRecord 下载审计记录
+type DownloadRecord struct {
+	Time     time.Time `json:"time"`
+	Account  string    `json:"account"`
+	Url      string    `json:"url"`
+	MimeType string    `json:"mime_type"`
+	FullPath string    `json:"full_path"`
+	Size     int64     `json:"size"`
+	Sha256   string    `json:"sha256"`
+	Status   string    `json:"status"` // completed/canceled/rejected
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// DownloadManager 下载管理器
+type DownloadManager struct {
+	lock          sync.Mutex
+	browserConfig func(...string) *config.BrowserConfig
+	downloads     map[uint32]DownloadRecord // 下载ID -> 进行中的下载，记录只在持有锁时读写
+	onProgress    func(event DownloadEvent)
+}
+
+// NewDownloadManager 创建新的下载管理器实例
+func NewDownloadManager(browserConfig func(...string) *config.BrowserConfig) *DownloadManager {
+	return &DownloadManager{
+		browserConfig: browserConfig,
+		downloads:     make(map[uint32]DownloadRecord),
+	}
+}
+
+// SetOnProgress 设置下载进度回调
+func (m *DownloadManager) SetOnProgress(fn func(event DownloadEvent)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.onProgress = fn
+}
+
+// BeforeDownload 下载开始前校验类型和大小，并决定保存路径
+// 返回true表示已处理（继续或取消），不允许的下载不会调用callback.Cont
+func (m *DownloadManager) BeforeDownload(account string, item *cef.ICefDownloadItem, suggestedName string, callback *cef.ICefBeforeDownloadCallback) bool {
+	// 记录加入downloads之前只在当前回调中使用，加入之后只通过副本读写
+	record := DownloadRecord{
+		Time:     time.Now(),
+		Account:  account,
+		Url:      item.Url(),
+		MimeType: item.MimeType(),
+		Size:     item.TotalBytes(),
+	}
+	fileName := SanitizeFileName(suggestedName)
+	if reason := m.checkAllowed(account, fileName, record.MimeType, record.Size); reason != "" {
+		record.Status = "rejected"
+		record.Reason = reason
+		m.logAudit(account, record)
+		fmt.Printf("下载被拒绝 - URL: %s, 原因: %s\n", record.Url, reason)
+		return true
+	}
+
+	dir := m.accountDir(account)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		record.Status = "rejected"
+		record.Reason = fmt.Sprintf("创建下载目录失败: %v", err)
+		m.logAudit(account, record)
+		fmt.Println(record.Reason)
+		return true
+	}
+	record.FullPath = uniqueFilePath(filepath.Join(dir, fileName))
+
+	m.lock.Lock()
+	m.downloads[item.Id()] = record
+	m.lock.Unlock()
+
+	callback.Cont(record.FullPath, false)
+	return true
+}
+
+// DownloadUpdated 下载进度更新，超出大小限制时取消下载，完成时写入审计记录
+func (m *DownloadManager) DownloadUpdated(item *cef.ICefDownloadItem, callback *cef.ICefDownloadItemCallback) {
+	m.lock.Lock()
+	record, ok := m.downloads[item.Id()]
+	onProgress := m.onProgress
+	m.lock.Unlock()
+	if !ok {
+		// 未经BeforeDownload允许的下载一律取消
+		if item.IsInProgress() {
+			callback.Cancel()
+		}
+		return
+	}
+
+	if maxSize := m.maxSize(record.Account); maxSize > 0 && item.ReceivedBytes() > maxSize && item.IsInProgress() {
+		callback.Cancel()
+		record = m.update(item.Id(), record, func(record *DownloadRecord) {
+			record.Reason = fmt.Sprintf("超出大小限制: %d bytes", maxSize)
+		})
+	}
+
+	event := DownloadEvent{
+		Id:            item.Id(),
+		Account:       record.Account,
+		Url:           record.Url,
+		FullPath:      record.FullPath,
+		ReceivedBytes: item.ReceivedBytes(),
+		TotalBytes:    item.TotalBytes(),
+		Percent:       item.PercentComplete(),
+		Complete:      item.IsComplete(),
+		Canceled:      item.IsCanceled() || item.IsInterrupted(),
+	}
+	if onProgress != nil {
+		onProgress(event)
+	}
+
+	if !event.Complete && !event.Canceled {
+		return
+	}
+	m.lock.Lock()
+	// 取出删除前的最新记录，其他回调可能已更新了取消原因
+	if latest, ok := m.downloads[item.Id()]; ok {
+		record = latest
+	}
+	delete(m.downloads, item.Id())
+	m.lock.Unlock()
+
+	// 记录已从downloads中删除，之后的修改只作用于本地副本
+	record.Size = event.ReceivedBytes
+	if event.Complete {
+		record.Status = "completed"
+		if hash, err := fileSha256(record.FullPath); err == nil {
+			record.Sha256 = hash
+		} else {
+			fmt.Printf("计算下载文件哈希失败: %v\n", err)
+		}
+	} else {
+		record.Status = "canceled"
+		_ = os.Remove(record.FullPath)
+	}
+	m.logAudit(record.Account, record)
+}
+
+// update 在锁内修改进行中的下载记录，返回修改后的副本，下载已结束时只修改current
+func (m *DownloadManager) update(id uint32, current DownloadRecord, fn func(record *DownloadRecord)) DownloadRecord {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	record, ok := m.downloads[id]
+	if !ok {
+		record = current
+	}
+	fn(&record)
+	if ok {
+		m.downloads[id] = record
+	}
+	return record
+}
+
+// checkAllowed 检查下载是否被允许，返回不允许的原因
+func (m *DownloadManager) checkAllowed(account, fileName, mimeType string, size int64) string {
+	downloadConfig := m.browserConfig(account).Download
+	if maxSize := m.maxSize(account); maxSize > 0 && size > maxSize {
+		return fmt.Sprintf("超出大小限制: %d bytes", maxSize)
+	}
+	if len(downloadConfig.AllowedMimeTypes) > 0 && !matchMimeType(mimeType, downloadConfig.AllowedMimeTypes) {
+		return "不允许的MIME类型: " + mimeType
+	}
+	if len(downloadConfig.AllowedExtensions) > 0 && !matchExtension(fileName, downloadConfig.AllowedExtensions) {
+		return "不允许的文件扩展名: " + filepath.Ext(fileName)
+	}
+	return ""
+}
+
+// maxSize 获取账户的下载大小限制（字节），0表示不限制
+func (m *DownloadManager) maxSize(account string) int64 {
+	return m.browserConfig(account).Download.MaxSizeMB * 1024 * 1024
+}
+
+// baseDir 获取下载根目录
+func (m *DownloadManager) baseDir(account string) string {
+	if dir := m.browserConfig(account).Download.Dir; dir != "" {
+		return dir
+	}
+	return defaultDownloadDir
+}
+
+// accountDir 获取账户的下载目录
+func (m *DownloadManager) accountDir(account string) string {
+	if account == "" {
+		account = "default"
+	}
+	return filepath.Join(m.baseDir(account), SanitizeFileName(account))
+}
+
+// logAudit 记录下载审计信息
+func (m *DownloadManager) logAudit(account string, record DownloadRecord) {
+	decision := audit.DecisionAllow
+	if record.Status == "rejected" {
+		decision = audit.DecisionBlock
+	}
+	audit.Log(audit.Record{
+		Time:     record.Time,
+		Event:    audit.EventDownload,
+		Account:  account,
+		URL:      record.Url,
+		Decision: decision,
+		Detail:   record,
+	})
+}
+
+// SanitizeFileName 清理文件名，去除路径分隔符、控制字符和系统保留名称
+func SanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "download"
+	}
+
+	base := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if _, ok := reservedFileNames[base]; ok {
+		name = "_" + name
+	}
+
+	if len(name) > maxFileNameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > maxFileNameLength/2 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFileNameLength-len(ext)], "") + ext
+	}
+	return name
+}
+
+// matchMimeType 检查MIME类型是否在允许列表中，支持"text/*"形式的通配
+func matchMimeType(mimeType string, allowed []string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	for _, item := range allowed {
+		item = strings.ToLower(item)
+		if item == mimeType || (strings.HasSuffix(item, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(item, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExtension 检查文件扩展名是否在允许列表中
+func matchExtension(fileName string, allowed []string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	for _, item := range allowed {
+		if strings.ToLower(strings.TrimPrefix(item, ".")) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueFilePath 文件已存在时在文件名后追加序号
+func uniqueFilePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// fileSha256 计算文件的SHA256
+func fileSha256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
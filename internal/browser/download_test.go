@@ -0,0 +1,115 @@
+package browser
+
+import (
+	"cef/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"slash", "../../etc/passwd", "_.._etc_passwd"},
+		{"backslash", `..\..\windows\system.ini`, "_.._windows_system.ini"},
+		{"drive", `C:\boot.ini`, "C__boot.ini"},
+		{"control", "a\x00b\nc.txt", "a_b_c.txt"},
+		{"reserved", "CON", "_CON"},
+		{"reserved with ext", "nul.txt", "_nul.txt"},
+		{"reserved lower", "com1.log", "_com1.log"},
+		{"not reserved", "CONSOLE.txt", "CONSOLE.txt"},
+		{"empty", "", "download"},
+		{"dots", "...", "download"},
+		{"spaces", "  ", "download"},
+		{"trailing dot", "file.txt.", "file.txt"},
+		{"long", long + ".zip", long[:maxFileNameLength-4] + ".zip"},
+		{"long ext", "a." + long, ("a." + long)[:maxFileNameLength]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeFileName(tt.in)
+			if got != tt.want {
+				t.Fatalf("SanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(got) > maxFileNameLength {
+				t.Fatalf("length %d exceeds %d", len(got), maxFileNameLength)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileName_LongMultiByte(t *testing.T) {
+	got := SanitizeFileName(strings.Repeat("文", 100) + ".txt")
+	if len(got) > maxFileNameLength || !strings.HasSuffix(got, ".txt") {
+		t.Fatalf("got %q (%d bytes)", got, len(got))
+	}
+	if !strings.HasPrefix(got, "文") || strings.ContainsRune(got, '\uFFFD') {
+		t.Fatalf("invalid UTF-8 truncation: %q", got)
+	}
+}
+
+func TestMatchMimeType(t *testing.T) {
+	allowed := []string{"application/pdf", "text/*"}
+	tests := []struct {
+		mimeType string
+		want     bool
+	}{
+		{"application/pdf", true},
+		{"Application/PDF; charset=binary", true},
+		{"text/plain", true},
+		{"text/csv;charset=utf-8", true},
+		{"application/zip", false},
+		{"texts/plain", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := matchMimeType(tt.mimeType, allowed); got != tt.want {
+			t.Errorf("matchMimeType(%q) = %v, want %v", tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestMatchExtension(t *testing.T) {
+	allowed := []string{".pdf", "XLSX"}
+	tests := []struct {
+		fileName string
+		want     bool
+	}{
+		{"a.pdf", true},
+		{"a.PDF", true},
+		{"a.xlsx", true},
+		{"a.pdf.exe", false},
+		{"pdf", false},
+		{"a.", false},
+	}
+	for _, tt := range tests {
+		if got := matchExtension(tt.fileName, allowed); got != tt.want {
+			t.Errorf("matchExtension(%q) = %v, want %v", tt.fileName, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadManager_CheckAllowed(t *testing.T) {
+	cfg := &config.BrowserConfig{}
+	cfg.Download.MaxSizeMB = 1
+	cfg.Download.AllowedMimeTypes = []string{"application/pdf"}
+	cfg.Download.AllowedExtensions = []string{"pdf"}
+	m := NewDownloadManager(func(...string) *config.BrowserConfig { return cfg })
+
+	if reason := m.checkAllowed("", "a.pdf", "application/pdf", 1024); reason != "" {
+		t.Fatalf("allowed download rejected: %s", reason)
+	}
+	if reason := m.checkAllowed("", "a.pdf", "application/pdf", 2*1024*1024); reason == "" {
+		t.Fatal("oversized download allowed")
+	}
+	if reason := m.checkAllowed("", "a.pdf", "application/zip", 1024); reason == "" {
+		t.Fatal("disallowed MIME type allowed")
+	}
+	if reason := m.checkAllowed("", "a.exe", "application/pdf", 1024); reason == "" {
+		t.Fatal("disallowed extension allowed")
+	}
+}
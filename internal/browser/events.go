@@ -3,16 +3,16 @@
 package browser
 
 import (
+	"cef/internal/audit"
 	"cef/internal/config"
+	"cef/internal/filter"
 	"cef/internal/fingerprint"
+	"cef/internal/headers"
+	"cef/internal/proxy"
 	"cef/internal/security"
 	"fmt"
-	"net/url"
-	"regexp"
 	"strings"
 	"sync"
-	"time"
-	"unsafe"
 
 	"github.com/energye/energy/v2/cef"
 	"github.com/energye/energy/v2/cef/ipc"
@@ -21,20 +21,34 @@ import (
 	"github.com/energye/golcl/lcl/rtl/version"
 )
 
-// 需要删除的HTTP头部
-var needRemoveHeaderKey = []string{"DNT"}
-
 // EventHandler 浏览器事件处理器
 type EventHandler struct {
-	lock                    sync.RWMutex
-	browserConfig           func(...string) *config.BrowserConfig
-	whitelistValidator      *security.WhitelistValidator
-	scriptManager           *fingerprint.ScriptManager
-	scriptGenerator         *fingerprint.Generator
-	lastRedirectURL         string // 最后一次重定向的URL，用于防止循环
-	redirectCount           int    // 重定向次数计数器
-	currentAccount          string // 当前账户
-	notifyAccountChangeChan chan<- string
+	lock               sync.RWMutex
+	browserConfig      func(...string) *config.BrowserConfig
+	whitelistValidator *security.WhitelistValidator
+	scriptManager      *fingerprint.ScriptManager
+	scriptGenerator    *fingerprint.Generator
+	accountDetector    *AccountDetector                      // 账户识别
+	responseFilters    *filter.Registry                      // 响应过滤规则
+	rewriteCounts      map[string]int64                      // 响应改写规则名称 -> 生效次数
+	navigationTracker  *NavigationTracker                    // 按浏览器跟踪重定向，防止循环
+	currentAccount     string                                // 最近检测到的账户
+	windowAccounts     map[int32]string                      // 窗口ID -> 绑定的账户（窗口内检测到的账户或继承自打开者）
+	pendingAccounts    map[cef.IBrowserWindow]string         // 浏览器尚未创建的窗口 -> 账户
+	sessionManager     *SessionManager                       // 账户会话隔离
+	downloadManager    *DownloadManager                      // 下载管理器
+	certificatePolicy  *security.CertificatePolicy           // 证书校验策略
+	accountEvents      *AccountEventBus                      // 账户切换事件
+	proxySelector      *proxy.Selector                       // 代理池选择
+	proxyAssignments   map[string]proxyAssignment            // 请求上下文标识 -> 当前使用的代理
+	proxyHealth        *proxy.HealthChecker                  // 代理健康检查，未启用时为空
+	clientHints        *headers.ClientHints                  // 各源请求的客户端提示
+	headerRules        map[string]headerRuleCache            // 账户 -> 已编译的请求头改写规则
+	devTools           *devToolsCalls                        // 等待结果的DevTools方法调用
+	pendingRestores    map[cef.IBrowserWindow]sessionRestore // 从会话快照恢复的窗口 -> 等待回报的本地存储写回（浏览器进程）
+	injections         map[string]InjectionStatus            // 窗口ID/框架ID -> 文档开始注入的结果（浏览器进程）
+	renderBundles      map[int32]fingerprintBundle           // 浏览器ID -> 窗口账户的脚本（渲染进程）
+	renderRestores     map[int32]renderRestore               // 浏览器ID -> 尚未写回的本地存储（渲染进程）
 }
 
 // NewEventHandler 创建新的事件处理器实例
@@ -43,67 +57,53 @@ func NewEventHandler(
 	whitelistValidator *security.WhitelistValidator,
 	scriptManager *fingerprint.ScriptManager,
 	scriptGenerator *fingerprint.Generator,
-	notifyAccountChangeChan chan string,
 ) *EventHandler {
-	return &EventHandler{
-		browserConfig:           browserConfig,
-		whitelistValidator:      whitelistValidator,
-		scriptManager:           scriptManager,
-		scriptGenerator:         scriptGenerator,
-		notifyAccountChangeChan: notifyAccountChangeChan,
+	h := &EventHandler{
+		browserConfig:      browserConfig,
+		whitelistValidator: whitelistValidator,
+		scriptManager:      scriptManager,
+		scriptGenerator:    scriptGenerator,
+		windowAccounts:     make(map[int32]string),
+		pendingAccounts:    make(map[cef.IBrowserWindow]string),
+		sessionManager:     NewSessionManager(browserConfig),
+		downloadManager:    NewDownloadManager(browserConfig),
+		accountDetector:    NewAccountDetector(browserConfig),
+		responseFilters:    filter.NewRegistry(),
+		rewriteCounts:      make(map[string]int64),
+		navigationTracker:  NewNavigationTracker(defaultRedirectWindow, defaultMaxRedirects),
+		certificatePolicy:  security.NewCertificatePolicy(browserConfig),
+		accountEvents:      NewAccountEventBus(),
+		proxySelector:      proxy.NewSelector(),
+		proxyAssignments:   make(map[string]proxyAssignment),
+	}
+	h.registerRewriteRules()
+	h.subscribeAccountEvents()
+	h.clientHints = headers.NewClientHints()
+	h.headerRules = make(map[string]headerRuleCache)
+	h.devTools = newDevToolsCalls()
+	h.pendingRestores = make(map[cef.IBrowserWindow]sessionRestore)
+	h.injections = make(map[string]InjectionStatus)
+	h.renderBundles = make(map[int32]fingerprintBundle)
+	h.renderRestores = make(map[int32]renderRestore)
+	if healthCheck := browserConfig().Proxy.HealthCheck; healthCheck.Enabled {
+		h.proxyHealth = proxy.NewHealthChecker(healthCheck, h.proxyServers, h.onProxyHealthChange)
 	}
+	return h
 }
 
 // SetupEvents 设置浏览器事件处理
 func (h *EventHandler) SetupEvents(event *cef.BrowserEvent, window cef.IBrowserWindow) {
 	// 设置资源加载前的回调，用于修改请求头
 	event.SetOnBeforeResourceLoad(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, callback *cef.ICefCallback, result *consts.TCefReturnValue, window cef.IBrowserWindow) {
-		// 获取并清理原有头部映射
-		headerMap := request.GetHeaderMap()
-
-		// 删除无用的头并重置header，同时去重
-		cleanedHeaders := h.RemoveKey(headerMap, needRemoveHeaderKey)
-
-		// 额外去重处理：确保每个头部键只出现一次
-		deduplicatedHeaders := h.DeduplicateHeaders(cleanedHeaders)
-		// 设置清理后的头部
-		request.SetHeaderMap(deduplicatedHeaders)
-
-		// 从UA中提取平台信息
-		userAgent := h.browserConfig(h.getCurrentAccount()).Basic.UserAgent
-		if userAgent != "" {
-			request.SetHeaderByName("User-Agent", userAgent, true)
-		}
-		var platformValue string
-
-		if strings.Contains(userAgent, "Windows") {
-			platformValue = "Windows"
-		} else if strings.Contains(userAgent, "Macintosh") {
-			platformValue = "macOS"
-		} else if strings.Contains(userAgent, "Linux") {
-			platformValue = "Linux"
-		} else if strings.Contains(userAgent, "Android") {
-			platformValue = "Android"
-		} else if strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad") {
-			platformValue = "iOS"
-		} else {
-			platformValue = "Windows" // 默认平台
-		}
-		// 直接设置关键头部到request，确保生效
-		request.SetHeaderByName("sec-ch-ua-platform", `"`+platformValue+`"`, true)
-
-		// 直接设置Accept-Language头部，确保生效
-		acceptLang := h.browserConfig(h.getCurrentAccount()).Basic.AcceptLanguage
-		if acceptLang != "" {
-			request.SetHeaderByName("Accept-Language", acceptLang, true)
-		}
+		// 按请求头配置和改写规则重建请求头，同名请求头按多值策略合并
+		h.rewriteRequestHeaders(browser, frame, request, window)
 	})
 
 	// 设置页面加载完成事件的处理函数
 	// 当浏览器页面加载完成后会触发此事件
 	event.SetOnLoadEnd(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, httpStatusCode int32, window cef.IBrowserWindow) {
 		h.handlePageLoad(browser, frame, httpStatusCode, window)
-		if h.browserConfig().Proxy.Debug {
+		if h.browserConfig(h.getWindowAccount(window)).Proxy.Debug {
 			window.Chromium().ExecuteJavaScript(`fetch('https://ifconfig.io/ip')
     .then(response => {
         if (!response.ok) {
@@ -121,125 +121,120 @@ func (h *EventHandler) SetupEvents(event *cef.BrowserEvent, window cef.IBrowserW
 	})
 
 	event.SetOnBeforeBrowser(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, userGesture, isRedirect bool, window cef.IBrowserWindow) bool {
-		requestContext := browser.GetRequestContext()
-		if h.browserConfig().Proxy.Url != "" {
-			proxyDict := cef.DictionaryValueRef.New()
-			proxyDict.SetString("mode", h.browserConfig().Proxy.Mode)
-			proxyDict.SetString("server", h.browserConfig().Proxy.Url)
-			proxy := cef.ValueRef.New()
-			proxy.SetDictionary(proxyDict)
-			requestContext.SetPreference("proxy", proxy)
-		}
-		return false
+		// 在导航开始前校验白名单，不允许的导航直接取消（包括重定向和子框架导航）
+		return h.handleBeforeBrowse(browser, frame, request, isRedirect, window)
 	})
 
-	window.Chromium().SetOnGetAuthCredentials(func(sender lcl.IObject, browser *cef.ICefBrowser, originUrl string, isProxy bool, host string, port int32, realm, scheme string, callback *cef.ICefAuthCallback) bool {
-		if isProxy {
-			callback.Cont(h.browserConfig().Proxy.Username, h.browserConfig().Proxy.Password)
-			return true
-		}
+	// 弹出窗口（window.open、target=_blank）按配置的策略处理
+	event.SetOnBeforePopup(func(sender lcl.IObject, popupWindow cef.IBrowserWindow, browser *cef.ICefBrowser, frame *cef.ICefFrame, beforePopupInfo *cef.BeforePopupInfo, popupFeatures *cef.TCefPopupFeatures, windowInfo *cef.TCefWindowInfo, resultClient *cef.ICefClient, settings *cef.TCefBrowserSettings, resultExtraInfo *cef.ICefDictionaryValue, noJavascriptAccess *bool) bool {
+		return h.handleBeforePopup(popupWindow, browser, frame, beforePopupInfo.TargetUrl, window)
+	})
+
+	// 下载按账户保存到受控目录，并校验类型和大小
+	event.SetOnBeforeDownload(func(sender lcl.IObject, browser *cef.ICefBrowser, downloadItem *cef.ICefDownloadItem, suggestedName string, callback *cef.ICefBeforeDownloadCallback, window cef.IBrowserWindow) bool {
+		return h.downloadManager.BeforeDownload(h.getWindowAccount(window), downloadItem, suggestedName, callback)
+	})
+
+	event.SetOnDownloadUpdated(func(sender lcl.IObject, browser *cef.ICefBrowser, downloadItem *cef.ICefDownloadItem, callback *cef.ICefDownloadItemCallback) {
+		h.downloadManager.DownloadUpdated(downloadItem, callback)
+	})
+
+	// 浏览器创建后绑定窗口对应的账户
+	event.SetOnAfterCreated(func(sender lcl.IObject, browser *cef.ICefBrowser, window cef.IBrowserWindow) bool {
+		h.bindCreatedWindow(window)
+		// 代理在请求上下文可用后设置一次，而不是在每次导航时设置
+		h.applyWindowProxy(window)
+		// 绑定的账户可能与创建时传递的脚本不同（如弹出窗口继承打开者的账户），重新发送一次
+		h.sendFingerprintScripts(window)
 		return false
 	})
 
-	event.SetOnLoadStart(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, transitionType consts.TCefTransitionType, window cef.IBrowserWindow) {
-		if currentUrl, _ := url.Parse(frame.Url()); currentUrl.Host != "agent.oceanengine.com" {
-			return
+	// 窗口关闭时释放窗口绑定的账户、导航状态和账户会话
+	event.SetOnBeforeClose(func(sender lcl.IObject, browser *cef.ICefBrowser, window cef.IBrowserWindow) bool {
+		h.unbindWindowAccount(window.Id())
+		h.navigationTracker.Remove(browser.Identifier())
+		h.devTools.cancel(browser.Identifier())
+		h.removeInjections(window.Id())
+		h.sessionManager.Detach(window.Id())
+		// 主窗口关闭时应用退出，提前释放所有账户会话并写入Cookie
+		if window.WindowType() == consts.WT_MAIN_BROWSER {
+			h.sessionManager.Close()
 		}
-		window.Chromium().SetOnGetResourceResponseFilter(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, response *cef.ICefResponse) (responseFilter *cef.ICefResponseFilter) {
-			if !strings.Contains(request.URL(), "/user-info") {
-				return nil
-			}
-			filter := cef.ResponseFilterRef.New()
-			filter.InitFilter(func() bool {
-				return true
-			})
-			filter.Filter(func(dataIn uintptr, dataInSize uint32, dataInRead *uint32, dataOut uintptr, dataOutSize uint32, dataOutWritten *uint32) (status consts.TCefResponseFilterStatus) {
-				status = consts.RESPONSE_FILTER_DONE
-				if dataIn == 0 {
-					return
-				}
-				contentBuf := make([]byte, dataInSize)
-				i := uint32(0)
-				for ; i < dataInSize; i++ {
-					contentBuf[i] = *(*byte)(unsafe.Pointer(dataIn + uintptr(i)))
-				}
-				*dataInRead = dataInSize
-				if matched := regexp.MustCompile(`(?U)"email":"([[:graph:]]+)"`).FindSubmatch(contentBuf); len(matched) >= 2 {
-					h.setCurrentAccount(string(matched[1]))
-				}
-				for i = 0; i < dataInSize; i++ {
-					*(*byte)(unsafe.Pointer(dataOut + uintptr(i))) = contentBuf[i]
-				}
-				*dataOutWritten = i
-				return
-			})
-			return filter
-		})
+		return false
 	})
-}
 
-// RemoveKey 从StringMultiMap中删除指定的key
-func (h *EventHandler) RemoveKey(header *cef.ICefStringMultiMap, keysToRemove []string) *cef.ICefStringMultiMap {
-	// 临时存储保留的键值对
-	preservedData := make(map[string][]string)
+	h.setupChromiumEvents(window)
 
-	// 遍历所有数据
-	size := header.GetSize()
-	var ketMapping = make(map[string]struct{}, len(keysToRemove))
-	for _, s := range keysToRemove {
-		ketMapping[s] = struct{}{}
-	}
-	for i := uint32(0); i < size; i++ {
-		key := header.GetKey(i)
-		value := header.GetValue(i)
+	// 接收渲染进程回报的文档开始注入结果
+	h.setupInjectionEvents(event)
 
-		// 跳过要删除的键
-		if _, ok := ketMapping[key]; ok {
-			continue
-		}
+	// 前端可以请求为指定账户打开新窗口
+	h.registerAccountIPC()
+	h.registerDetectionIPC()
+	h.registerProxyIPC()
+	h.registerCookieIPC()
+	h.registerSnapshotIPC()
 
-		// 保存其他键值对
-		preservedData[key] = append(preservedData[key], value)
+	// 健康检查只在浏览器进程中运行
+	if h.proxyHealth != nil {
+		h.proxyHealth.Start()
 	}
-	// 清空原数据
-	header.Clear()
-	// 重新添加保留的数据
-	for key, values := range preservedData {
-		for _, value := range values {
-			header.Append(key, value)
-		}
-	}
-	return header
 }
 
-// DeduplicateHeaders 去重StringMultiMap中的头部值
-func (h *EventHandler) DeduplicateHeaders(header *cef.ICefStringMultiMap) *cef.ICefStringMultiMap {
-	// 临时存储保留的键值对
-	preservedData := make(map[string][]string)
+// setupChromiumEvents 设置窗口Chromium实例的事件
+// 这些事件不属于全局BrowserEvent，每个窗口（包括弹出窗口和账户窗口）都需要单独设置
+func (h *EventHandler) setupChromiumEvents(window cef.IBrowserWindow) {
+	// 证书错误按配置的证书策略处理，替代全局忽略证书错误
+	window.Chromium().SetOnCertificateError(func(sender lcl.IObject, browser *cef.ICefBrowser, certError consts.TCefErrorCode, requestUrl string, sslInfo *cef.ICefSslInfo, callback *cef.ICefCallback) bool {
+		return h.handleCertificateError(browser, certError, requestUrl, sslInfo, callback, window)
+	})
 
-	// 遍历所有数据
-	size := header.GetSize()
-	for i := uint32(0); i < size; i++ {
-		key := header.GetKey(i)
-		value := header.GetValue(i)
+	// 页面加载完成后通过导航记录获取证书，校验SPKI固定配置
+	window.Chromium().SetOnNavigationVisitorResultAvailable(func(sender lcl.IObject, entry *cef.ICefNavigationEntry, current bool, index, total int32) bool {
+		if current {
+			h.verifyCertificatePins(entry, window)
+		}
+		return true
+	})
 
-		// 保存其他键值对
-		preservedData[key] = append(preservedData[key], value)
-	}
-	// 清空原数据
-	header.Clear()
-	// 重新添加保留的数据
-	for key, values := range preservedData {
-		// 确保每个键只保留一个值
-		if len(values) > 0 {
-			header.Append(key, values[0])
+	window.Chromium().SetOnGetAuthCredentials(func(sender lcl.IObject, browser *cef.ICefBrowser, originUrl string, isProxy bool, host string, port int32, realm, scheme string, callback *cef.ICefAuthCallback) bool {
+		if isProxy {
+			callback.Cont(h.proxyCredentials(window, host, port))
+			return true
 		}
-	}
-	return header
+		return false
+	})
+
+	// 记录导航响应的Accept-CH，决定之后是否发送高熵客户端提示
+	window.Chromium().SetOnResourceResponse(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, response *cef.ICefResponse, result *bool) {
+		h.observeClientHints(request, response)
+	})
+
+	// DevTools方法调用结果按消息ID分发给调用方
+	window.Chromium().SetOnDevToolsMethodResult(func(sender lcl.IObject, browser *cef.ICefBrowser, messageId int32, success bool, result *cef.ICefValue) {
+		h.devTools.onResult(browser, messageId, success, result)
+	})
+
+	// 按响应过滤规则和账户识别规则检查或改写响应内容
+	window.Chromium().SetOnGetResourceResponseFilter(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, response *cef.ICefResponse) (responseFilter *cef.ICefResponseFilter) {
+		return h.getResponseFilter(browser, request, response, window)
+	})
+}
+
+// GetDownloadManager 获取下载管理器（用于订阅下载进度）
+func (h *EventHandler) GetDownloadManager() *DownloadManager {
+	return h.downloadManager
+}
+
+// GetSessionManager 获取账户会话管理器
+func (h *EventHandler) GetSessionManager() *SessionManager {
+	return h.sessionManager
 }
 
 func (h *EventHandler) Close() {
-	close(h.notifyAccountChangeChan)
+	if h.proxyHealth != nil {
+		h.proxyHealth.Close()
+	}
 	//os.RemoveAll("temp")
 }
 
@@ -247,71 +242,153 @@ func (h *EventHandler) Close() {
 func (h *EventHandler) handlePageLoad(browser *cef.ICefBrowser, frame *cef.ICefFrame, httpStatusCode int32, window cef.IBrowserWindow) {
 	currentURL := frame.Url()
 	fmt.Println("current frame:", currentURL, "window ID:", window.Id())
+	account := h.getWindowAccount(window)
 	// 检查URL是否被允许访问（优先检查，避免不必要的脚本注入）
-	if currentURL != "" && currentURL != "about:blank" && !h.whitelistValidator.IsURLAllowed(currentURL) {
-		h.handleBlockedURL(browser, currentURL)
-		return
+	if currentURL != "" && currentURL != "about:blank" && !isInternalPage(currentURL) {
+		if decision := h.whitelistValidator.Evaluate(currentURL, account); !decision.Allowed {
+			h.handleBlockedURL(browser, currentURL, account, window.Id(), decision.Rule)
+			return
+		}
+	}
+	// 主框架加载完成后获取当前导航记录，用于校验证书固定配置
+	if frame.IsMain() && strings.HasPrefix(currentURL, "https://") {
+		window.Chromium().GetNavigationEntries(true)
+	}
+	// 主框架加载完成后按Cookie和页面元素规则识别账户
+	if frame.IsMain() && !isInternalPage(currentURL) {
+		h.detectAccountOnLoad(browser, frame, window)
+	}
+	// 指纹脚本已在渲染进程中于文档开始时注入，未收到注入成功的回报时补充注入
+	// 主框架加载完成时检查所有框架，覆盖没有单独触发加载完成的子框架
+	if frame.IsMain() {
+		h.injectMissingFrames(browser, account, window)
+	} else {
+		h.injectMissingFrame(browser, frame, account, window)
 	}
-	// 仅对允许的URL进行指纹注入
-	h.injectFingerprintScripts(browser, frame)
-	// 延迟补强注入（仅一次）
-	go func() {
-		time.Sleep(200 * time.Millisecond)
-		h.injectFingerprintScripts(browser)
-	}()
 
 	// 发送系统信息到前端
 	//h.sendSystemInfo(window)
 }
 
-// handleBlockedURL 处理被阻止的URL访问
-func (h *EventHandler) handleBlockedURL(browser *cef.ICefBrowser, currentURL string) {
-	// 防止重定向循环：检查是否与上次重定向目标相同
-	redirectURL := h.whitelistValidator.GetRedirectURL()
-	if currentURL == h.lastRedirectURL || h.redirectCount > 3 {
-		// 避免无限重定向循环
-		return
+// handleBeforeBrowse 导航开始前检查URL是否允许访问
+// 返回true表示取消本次导航
+func (h *EventHandler) handleBeforeBrowse(browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, isRedirect bool, window cef.IBrowserWindow) bool {
+	targetURL := request.URL()
+	if targetURL == "" || targetURL == "about:blank" || isInternalPage(targetURL) {
+		return false
+	}
+	account := h.getWindowAccount(window)
+	decision := h.whitelistValidator.Evaluate(targetURL, account)
+	if decision.Allowed {
+		if frame.IsMain() {
+			audit.Log(audit.Record{
+				Event:    audit.EventNavigation,
+				Account:  account,
+				WindowId: window.Id(),
+				URL:      targetURL,
+				Rule:     decision.Rule,
+				Decision: audit.DecisionAllow,
+			})
+		}
+		return false
+	}
+	fmt.Println("取消导航:", targetURL, "main frame:", frame.IsMain(), "redirect:", isRedirect)
+	// 子框架导航仅取消，不影响主页面
+	if !frame.IsMain() {
+		h.whitelistValidator.LogBlockedAccess(targetURL)
+		audit.Log(audit.Record{
+			Event:    audit.EventBlocked,
+			Account:  account,
+			WindowId: window.Id(),
+			URL:      targetURL,
+			Rule:     decision.Rule,
+			Decision: audit.DecisionCancel,
+		})
+		return true
 	}
+	// 主框架导航取消后按规则重定向或展示阻止页面
+	h.handleBlockedURL(browser, targetURL, account, window.Id(), decision.Rule)
+	return true
+}
 
+// handleBlockedURL 处理被阻止的URL访问
+// 根据匹配的规则重定向、展示阻止页面或静默取消
+func (h *EventHandler) handleBlockedURL(browser *cef.ICefBrowser, currentURL, account string, windowId int32, rule string) {
 	h.whitelistValidator.LogBlockedAccess(currentURL)
 
-	if redirectURL != "" && redirectURL != currentURL {
-		h.lastRedirectURL = currentURL
-		h.redirectCount++
+	record := audit.Record{
+		Event:    audit.EventBlocked,
+		Account:  account,
+		WindowId: windowId,
+		URL:      currentURL,
+		Rule:     rule,
+	}
+	var redirectURL string
+	switch h.whitelistValidator.GetBlockedAction(currentURL, account) {
+	case security.BlockedActionCancel:
+		record.Decision = audit.DecisionCancel
+		audit.Log(record)
+		return
+	case security.BlockedActionPage:
+		record.Decision = audit.DecisionPage
+		redirectURL = blockedPageURL(currentURL, account)
+	default:
+		record.Decision = audit.DecisionRedirect
+		redirectURL = h.whitelistValidator.GetRedirectURL(account)
+	}
+	if redirectURL == "" {
+		record.Decision = audit.DecisionCancel
+		audit.Log(record)
+		return
+	}
+	// 防止重定向循环：同一浏览器在时间窗口内重复重定向时只取消不再跳转
+	if !h.navigationTracker.AllowRedirect(browser.Identifier(), currentURL, redirectURL) {
+		fmt.Println("检测到重定向循环，停止重定向:", currentURL, "->", redirectURL)
+		record.Decision = audit.DecisionCancel
+		record.Detail = map[string]string{"reason": "redirect_loop", "redirect_url": redirectURL}
+		audit.Log(record)
+		return
+	}
+	audit.Log(record)
+	browser.MainFrame().LoadUrl(redirectURL)
+}
 
-		browser.MainFrame().LoadUrl(redirectURL)
+// GetNavigationStates 获取各浏览器的导航状态（用于诊断）
+func (h *EventHandler) GetNavigationStates() []NavigationState {
+	return h.navigationTracker.Snapshot()
+}
 
-		// 重置计数器（延迟重置）
-		go func() {
-			time.Sleep(5 * time.Second)
-			h.redirectCount = 0
-		}()
+// injectFingerprintScripts 通过ExecuteJavaScript注入指纹伪装脚本
+// 页面脚本可能已经读取到原始值，仅在文档开始时的注入失败后补充使用
+func (h *EventHandler) injectFingerprintScripts(browser *cef.ICefBrowser, account string, frame ...*cef.ICefFrame) {
+	targetFrame := browser.MainFrame()
+	if len(frame) > 0 && frame[0].IsValid() {
+		targetFrame = frame[0]
+	}
+	if !targetFrame.IsValid() {
+		return
+	}
+	for _, script := range h.fingerprintScripts(account) {
+		targetFrame.ExecuteJavaScript(fmt.Sprintf(`console.log('开始注入[%s]脚本');`, script.Name), "", 0)
+		targetFrame.ExecuteJavaScript(script.Source, "", 0)
+		targetFrame.ExecuteJavaScript(fmt.Sprintf(`console.log('结束注入[%s]脚本');`, script.Name), "", 0)
 	}
 }
 
-// injectFingerprintScripts 注入指纹伪装脚本
-func (h *EventHandler) injectFingerprintScripts(browser *cef.ICefBrowser, frame ...*cef.ICefFrame) {
-	executeJavaScript := func(scriptName, script string) {
-		if script == "" {
-			return
-		}
-		targetFrame := browser.MainFrame()
-		if len(frame) > 0 && frame[0].IsValid() {
-			targetFrame = frame[0]
+// fingerprintScripts 按注入顺序获取账户的指纹伪装脚本
+func (h *EventHandler) fingerprintScripts(account string) []injectedScript {
+	var scripts []injectedScript
+	addScript := func(scriptName, script string) {
+		if script != "" {
+			scripts = append(scripts, injectedScript{Name: scriptName, Source: script})
 		}
-		if !targetFrame.IsValid() {
-			return
-		}
-		targetFrame.ExecuteJavaScript(fmt.Sprintf(`console.log('开始注入[%s]脚本');`, scriptName), "", 0)
-		targetFrame.ExecuteJavaScript(script, "", 0)
-		targetFrame.ExecuteJavaScript(fmt.Sprintf(`console.log('结束注入[%s]脚本');`, scriptName), "", 0)
 	}
 
 	// 注入HTTP头部修复脚本
-	executeJavaScript("HTTP头部修复", h.scriptManager.GetHeadersFixScript())
+	addScript("HTTP头部修复", h.scriptManager.GetHeadersFixScript())
 
 	// 注入WebSocket修复脚本
-	executeJavaScript("WebSocket修复", h.scriptManager.GetWebSocketFixScript())
+	addScript("WebSocket修复", h.scriptManager.GetWebSocketFixScript())
 
 	// 注入CORS禁用脚本（在指纹脚本之前）
 	corsScript := `
@@ -366,24 +443,27 @@ func (h *EventHandler) injectFingerprintScripts(browser *cef.ICefBrowser, frame
 		
 		console.log('CORS 禁用和 WebSocket 增强设置完成');
 	`
-	executeJavaScript("CORS禁用", corsScript)
+	addScript("CORS禁用", corsScript)
 
 	// 最简单的测试脚本 - 确保JavaScript执行正常
-	executeJavaScript("测试", `console.log('🔥 JavaScript执行测试 - 成功！');`)
+	addScript("测试", `console.log('🔥 JavaScript执行测试 - 成功！');`)
 
 	// 注入静态指纹脚本
 	if h.scriptManager.IsScriptLoaded() {
-		executeJavaScript("静态指纹", h.scriptManager.GetStaticScript())
+		addScript("静态指纹", h.scriptManager.GetStaticScript())
 	}
 
 	// 注入动态基础指纹脚本 !!!
-	executeJavaScript("动态基础指纹", h.scriptGenerator.GenerateBasicScript(h.getCurrentAccount()))
+	addScript("动态基础指纹", h.scriptGenerator.GenerateBasicScript(account))
 
 	// 注入高级指纹脚本
-	executeJavaScript("高级指纹", h.scriptGenerator.GenerateAdvancedScript(h.getCurrentAccount()))
+	addScript("高级指纹", h.scriptGenerator.GenerateAdvancedScript(account))
+
+	// blob:和data:地址的Worker先执行Worker指纹伪装脚本
+	addScript("Worker注入", h.scriptGenerator.GenerateWorkerHookScript(account))
 
 	// 方舟登陆脚本
-	executeJavaScript("方舟登陆", h.scriptGenerator.GenerateLoginScript())
+	addScript("方舟登陆", h.scriptGenerator.GenerateLoginScript())
 
 	// 验证脚本 - 检查关键指标
 	verificationScript := `
@@ -412,7 +492,8 @@ func (h *EventHandler) injectFingerprintScripts(browser *cef.ICefBrowser, frame
 		console.log('🔍 === 验证完成 ===');
 	}, 1000);
 	`
-	executeJavaScript("验证", verificationScript)
+	addScript("验证", verificationScript)
+	return scripts
 }
 
 // sendSystemInfo 发送系统信息到前端
@@ -441,17 +522,33 @@ func (h *EventHandler) sendSystemInfo(window cef.IBrowserWindow) {
 	ipc.Emit("windowType", windowType)
 }
 
-func (h *EventHandler) setCurrentAccount(account string) {
+// setCurrentAccount 将窗口内检测到的账户绑定到窗口
+func (h *EventHandler) setCurrentAccount(window cef.IBrowserWindow, account string) {
 	if account == "" {
 		return
 	}
 	h.lock.Lock()
-	defer h.lock.Unlock()
-	if account != h.currentAccount {
-		//h.notifyAccountChangeChan <- account
-		h.currentAccount = account
-		fmt.Println("set current account:", account)
+	previous := h.windowAccounts[window.Id()]
+	h.windowAccounts[window.Id()] = account
+	h.currentAccount = account
+	h.lock.Unlock()
+	if account == previous {
+		return
 	}
+	audit.Log(audit.Record{
+		Event:    audit.EventAccountChange,
+		Account:  account,
+		WindowId: window.Id(),
+		Detail:   map[string]string{"previous": previous},
+	})
+	fmt.Println("set window account:", window.Id(), account)
+	// 订阅者可能访问窗口账户，在释放锁之后发布
+	h.accountEvents.Publish(AccountChangeEvent{
+		Window:   window,
+		WindowId: window.Id(),
+		Account:  account,
+		Previous: previous,
+	})
 	//if err := os.MkdirAll("temp", 0750); err != nil {
 	//	fmt.Printf("Mkdir temp failed, %v\n", err)
 	//} else {
@@ -0,0 +1,94 @@
+// Package browser 请求头配置
+// 按窗口账户的请求头配置和改写规则重建CEF请求的请求头，并记录导航响应的Accept-CH
+package browser
+
+import (
+	"cef/internal/config"
+	"cef/internal/headers"
+	"fmt"
+	"net/url"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+// headerRuleCache 账户已编译的请求头改写规则，账户配置更新后重新编译
+type headerRuleCache struct {
+	config *config.BrowserConfig
+	rules  *headers.RuleSet
+	order  []string // 为空时保持原顺序
+}
+
+// rewriteRequestHeaders 按原顺序读取请求头，设置请求头配置的值后执行改写规则，最后按配置的顺序排列
+func (h *EventHandler) rewriteRequestHeaders(browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, window cef.IBrowserWindow) {
+	headerMap := request.GetHeaderMap()
+	if headerMap == nil {
+		return
+	}
+	list := headers.FromMultiMap(headerMap)
+	for _, header := range h.profileHeaders(browser, frame, request, window) {
+		list = list.Set(header.Name, header.Value)
+	}
+	var host string
+	if parsedURL, err := url.Parse(request.URL()); err == nil {
+		host = parsedURL.Hostname()
+	}
+	cached := h.headerRuleSet(h.getWindowAccount(window))
+	list = cached.rules.Apply(host, list).Reorder(cached.order)
+	list.WriteTo(headerMap)
+	request.SetHeaderMap(headerMap)
+}
+
+// headerRuleSet 获取账户的请求头改写规则和顺序，配置错误时不执行任何规则或保持原顺序
+func (h *EventHandler) headerRuleSet(account string) headerRuleCache {
+	cfg := h.browserConfig(account)
+	h.lock.RLock()
+	cached, ok := h.headerRules[account]
+	h.lock.RUnlock()
+	if ok && cached.config == cfg {
+		return cached
+	}
+	rules, err := headers.NewRuleSet(cfg.HeaderRules.Rules, cfg.HeaderRules.MultiValue)
+	if err != nil {
+		fmt.Printf("请求头改写规则加载失败 - 账户: %s, 错误: %v\n", account, err)
+		rules, _ = headers.NewRuleSet(nil, nil)
+	}
+	order, err := headers.ResolveOrder(cfg.HeaderRules.Order, cfg.HeaderRules.CustomOrder)
+	if err != nil {
+		fmt.Printf("请求头顺序加载失败 - 账户: %s, 错误: %v\n", account, err)
+	}
+	cached = headerRuleCache{config: cfg, rules: rules, order: order}
+	h.lock.Lock()
+	h.headerRules[account] = cached
+	h.lock.Unlock()
+	return cached
+}
+
+// profileHeaders 按窗口账户的请求头配置生成请求需要设置的请求头
+func (h *EventHandler) profileHeaders(browser *cef.ICefBrowser, frame *cef.ICefFrame, request *cef.ICefRequest, window cef.IBrowserWindow) []headers.Header {
+	profileRequest := headers.Request{
+		URL:          request.URL(),
+		ResourceType: headers.ResourceType(request.ResourceType()),
+	}
+	if mainFrame := browser.MainFrame(); mainFrame != nil {
+		profileRequest.TopLevelURL = mainFrame.Url()
+	}
+	switch profileRequest.ResourceType {
+	case headers.ResourceMainFrame, headers.ResourceNavigationPreloadMainFrame:
+		// 顶层导航的发起方以Referrer近似，地址栏输入和书签等没有Referrer
+		profileRequest.Initiator = request.ReferrerUrl()
+	case headers.ResourceSubFrame, headers.ResourceNavigationPreloadSubFrame:
+		profileRequest.Initiator = profileRequest.TopLevelURL
+	default:
+		if frame != nil && frame.IsValid() {
+			profileRequest.Initiator = frame.Url()
+		}
+	}
+	return headers.NewProfile(h.browserConfig(h.getWindowAccount(window)), h.clientHints).Headers(profileRequest)
+}
+
+// observeClientHints 记录顶层导航响应的Accept-CH，子框架的Accept-CH不影响顶层页面
+func (h *EventHandler) observeClientHints(request *cef.ICefRequest, response *cef.ICefResponse) {
+	if headers.ResourceType(request.ResourceType()) == headers.ResourceMainFrame {
+		h.clientHints.Observe(request.URL(), response.GetHeaderByName("Accept-CH"))
+	}
+}
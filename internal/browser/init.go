@@ -5,8 +5,19 @@ package browser
 import (
 	"cef/internal/config"
 	"embed"
+	"fmt"
 	"github.com/energye/energy/v2/cef"
 	"github.com/energye/energy/v2/pkgs/assetserve"
+	"net/http"
+)
+
+const (
+	// assetServerIP 内置静态资源服务器只监听本机回环地址，内置页面可以调用IPC命令，不能被局域网访问
+	assetServerIP = "127.0.0.1"
+	// assetServerPort 内置静态资源服务器端口
+	assetServerPort = 22022
+	// assetServerOrigin 内置静态资源服务器地址
+	assetServerOrigin = "http://localhost:22022"
 )
 
 // Initializer 浏览器初始化器
@@ -45,30 +56,22 @@ func (init *Initializer) Initialize() *cef.TCEFApplication {
 	// 注释掉可能导致重复的--accept-lang参数，改为在HTTP请求拦截器中设置
 	// app.AddCustomCommandLine("--accept-lang", init.browserConfig.Basic.AcceptLanguage)
 
-	// 设置CEF命令行开关来解决CORS和安全问题
-	app.AddCustomCommandLine("--disable-web-security", "")
-	app.AddCustomCommandLine("--allow-running-insecure-content", "")
-	app.AddCustomCommandLine("--ignore-certificate-errors", "")
-	app.AddCustomCommandLine("--ignore-ssl-errors", "")
-	app.AddCustomCommandLine("--ignore-urlfetcher-cert-requests", "")
-	app.AddCustomCommandLine("--disable-extensions", "")
-	app.AddCustomCommandLine("--disable-plugins", "")
-	app.AddCustomCommandLine("--disable-default-apps", "")
-	app.AddCustomCommandLine("--disable-background-timer-throttling", "")
-
-	// WebSocket深度支持配置 - 解决状态码200问题
-	app.AddCustomCommandLine("--enable-websockets", "")
-	app.AddCustomCommandLine("--enable-experimental-web-platform-features", "")
-	app.AddCustomCommandLine("--disable-site-isolation-trials", "")
-	app.AddCustomCommandLine("--allow-websocket-upgrade-on-any-port", "")
-	app.AddCustomCommandLine("--disable-background-networking", "")
-	app.AddCustomCommandLine("--disable-sync", "")
-	app.AddCustomCommandLine("--disable-translate", "")
-	//app.AddCustomCommandLine("--no-proxy-server", "")
-	app.AddCustomCommandLine("--disable-renderer-backgrounding", "")
-
-	// 统一的禁用功能配置（避免重复）
-	app.AddCustomCommandLine("--disable-features", "VizDisplayCompositor,SiteIsolation,TranslateUI,BackgroundSync")
+	// 启用账户会话隔离时，账户的缓存目录必须位于根缓存目录中
+	if sessionManager := init.eventHandler.GetSessionManager(); sessionManager.Enabled() {
+		app.SetRootCache(sessionManager.RootCachePath())
+		app.SetCache(sessionManager.DefaultCachePath())
+	}
+
+	// 其余命令行开关按配置的开关集合添加，不同部署可选择不同配置而无需重新编译
+	applySwitchProfile(app.AddCustomCommandLine, init.browserConfig)
+
+	// 账户切换后更新应用级User-Agent
+	// CEF初始化后应用级设置可能不再生效，窗口内的UA由user_agent订阅者通过DevTools覆盖
+	init.eventHandler.GetAccountEvents().Subscribe("app_user_agent", func(event AccountChangeEvent) {
+		if userAgent := init.eventHandler.browserConfig(event.Account).Basic.UserAgent; userAgent != "" {
+			app.SetUserAgent(userAgent)
+		}
+	})
 
 	// 配置浏览器窗口
 	init.configureBrowserWindow()
@@ -76,6 +79,9 @@ func (init *Initializer) Initialize() *cef.TCEFApplication {
 	// 配置静态资源服务器
 	init.configureAssetServer()
 
+	// 渲染进程在JS上下文创建时注入指纹脚本，回调需要在所有进程中设置
+	init.eventHandler.SetupRenderProcess(app)
+
 	// 设置浏览器窗口初始化时的回调函数
 	// 用于配置IPC通信和各种事件处理
 	cef.BrowserWindow.SetBrowserInit(func(event *cef.BrowserEvent, window cef.IBrowserWindow) {
@@ -113,14 +119,24 @@ func (init *Initializer) configureAssetServer() {
 	cef.SetBrowserProcessStartAfterCallback(func(b bool) {
 		// 创建一个新的静态资源HTTP服务器实例
 		server := assetserve.NewAssetsHttpServer()
-		// 设置HTTP服务器监听端口为22022
-		server.PORT = 22022
+		// 设置HTTP服务器监听127.0.0.1:22022，默认的0.0.0.0会暴露给局域网
+		server.IP = assetServerIP
+		server.PORT = assetServerPort
 		// 指定资源文件夹名称，与embed指令中的目录名对应
 		server.AssetsFSName = "resources"
 		// 将嵌入的文件系统赋值给服务器，使其能够提供静态资源服务
 		server.Assets = init.resources
+
+		// 阻止页面需要按模板渲染，其余路径交给静态资源服务器处理
+		mux := http.NewServeMux()
+		mux.HandleFunc(blockedPagePath, init.blockedPageHandler())
+		mux.Handle("/", server)
 		// 在新的goroutine中启动HTTP服务器（非阻塞方式）
-		go server.StartHttpServer()
+		go func() {
+			if err := http.ListenAndServe(fmt.Sprintf("%s:%d", server.IP, server.PORT), mux); err != nil {
+				fmt.Printf("静态资源服务器启动失败: %v\n", err)
+			}
+		}()
 	})
 }
 
@@ -132,11 +148,12 @@ func (init *Initializer) UpdateConfig(newConfig *config.BrowserConfig) {
 // GetConfigSummary 获取当前配置摘要
 func (init *Initializer) GetConfigSummary() map[string]interface{} {
 	return map[string]interface{}{
-		"default_url":   init.browserConfig.App.DefaultURL,
-		"window_title":  init.browserConfig.App.WindowTitle,
-		"screen_width":  init.browserConfig.Screen.Width,
-		"screen_height": init.browserConfig.Screen.Height,
-		"user_agent":    init.browserConfig.Basic.UserAgent,
-		"language":      init.browserConfig.Basic.AcceptLanguage,
+		"default_url":    init.browserConfig.App.DefaultURL,
+		"window_title":   init.browserConfig.App.WindowTitle,
+		"screen_width":   init.browserConfig.Screen.Width,
+		"screen_height":  init.browserConfig.Screen.Height,
+		"user_agent":     init.browserConfig.Basic.UserAgent,
+		"language":       init.browserConfig.Basic.AcceptLanguage,
+		"switch_profile": init.browserConfig.Switches.Profile,
 	}
 }
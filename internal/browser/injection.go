@@ -0,0 +1,294 @@
+// Package browser 文档开始时注入指纹脚本
+// 渲染进程在每个框架创建JS上下文时、页面脚本执行前注入窗口账户的指纹脚本，并将注入结果回报给浏览器进程；
+// 浏览器进程在创建浏览器和账户切换时将账户的脚本发送给渲染进程，未收到注入成功的回报时在页面加载完成后补充注入；
+// Worker没有JS上下文创建回调：通过网络加载的Worker、SharedWorker和Service Worker由Worker脚本的响应过滤覆盖，
+// blob:和data:地址的Worker、SharedWorker由页面中的Worker注入脚本覆盖；页面的CSP拒绝包装后的地址时不覆盖
+package browser
+
+import (
+	"cef/internal/filter"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/consts"
+	"github.com/energye/golcl/lcl"
+)
+
+const (
+	// fingerprintScriptsMessage 浏览器进程发送给渲染进程的账户脚本
+	fingerprintScriptsMessage = "fingerprint_scripts"
+	// fingerprintInjectedMessage 渲染进程回报给浏览器进程的注入结果
+	fingerprintInjectedMessage = "fingerprint_injected"
+	// fingerprintExtraInfoKey 创建浏览器时通过extra_info传递账户脚本
+	fingerprintExtraInfoKey = "fingerprint_scripts"
+)
+
+// injectedScript 按顺序注入的脚本
+type injectedScript struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// fingerprintBundle 发送给渲染进程的账户脚本
+type fingerprintBundle struct {
+	Account string           `json:"account"`
+	Scripts []injectedScript `json:"scripts"`
+}
+
+// InjectionStatus 框架最近一次文档开始注入的结果
+type InjectionStatus struct {
+	WindowId int32     `json:"window_id"`
+	FrameId  string    `json:"frame_id"`
+	URL      string    `json:"url"`
+	Account  string    `json:"account"`
+	Injected bool      `json:"injected"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// injectionKey 窗口内框架的标识
+func injectionKey(windowId int32, frameId string) string {
+	return fmt.Sprintf("%d/%s", windowId, frameId)
+}
+
+// fingerprintPayload 编码账户的脚本
+func (h *EventHandler) fingerprintPayload(account string) string {
+	data, err := json.Marshal(fingerprintBundle{Account: account, Scripts: h.fingerprintScripts(account)})
+	if err != nil {
+		fmt.Printf("指纹脚本编码失败 - 账户: %s, 错误: %v\n", account, err)
+		return ""
+	}
+	return string(data)
+}
+
+// fingerprintExtraInfo 创建浏览器时随extra_info传递的账户脚本，渲染进程创建第一个JS上下文前即可获得
+func (h *EventHandler) fingerprintExtraInfo(account string) *cef.ICefDictionaryValue {
+	extraInfo := cef.DictionaryValueRef.New()
+	extraInfo.SetString(fingerprintExtraInfoKey, h.fingerprintPayload(account))
+	return extraInfo
+}
+
+// sendFingerprintScripts 将窗口账户的脚本发送给渲染进程，之后创建的JS上下文使用新账户的脚本
+func (h *EventHandler) sendFingerprintScripts(window cef.IBrowserWindow) {
+	account := h.getWindowAccount(window)
+	payload := h.fingerprintPayload(account)
+	cef.RunOnMainThread(func() {
+		browser := window.Chromium().Browser()
+		if browser == nil || !browser.IsValid() || payload == "" {
+			return
+		}
+		message := cef.ProcessMessageRef.New(fingerprintScriptsMessage)
+		message.ArgumentList().SetString(0, payload)
+		browser.MainFrame().SendProcessMessage(consts.PID_RENDER, message)
+	})
+}
+
+// onInjectionReported 记录渲染进程回报的注入结果
+func (h *EventHandler) onInjectionReported(frame *cef.ICefFrame, message *cef.ICefProcessMessage, window cef.IBrowserWindow) bool {
+	if message.Name() != fingerprintInjectedMessage {
+		return false
+	}
+	args := message.ArgumentList()
+	status := InjectionStatus{
+		WindowId: window.Id(),
+		FrameId:  frame.Identifier(),
+		Account:  args.GetString(0),
+		URL:      args.GetString(1),
+		Error:    args.GetString(2),
+		Time:     time.Now(),
+	}
+	status.Injected = status.Error == ""
+	if !status.Injected {
+		fmt.Printf("文档开始时注入指纹脚本失败 - 窗口: %d, URL: %s, 错误: %s\n", status.WindowId, status.URL, status.Error)
+	}
+	h.lock.Lock()
+	h.injections[injectionKey(status.WindowId, status.FrameId)] = status
+	h.lock.Unlock()
+	return true
+}
+
+// documentInjected 框架当前文档是否已在文档开始时按窗口账户注入成功
+func (h *EventHandler) documentInjected(window cef.IBrowserWindow, frame *cef.ICefFrame, account string) bool {
+	h.lock.RLock()
+	status, ok := h.injections[injectionKey(window.Id(), frame.Identifier())]
+	h.lock.RUnlock()
+	return ok && status.Injected && status.Account == account && status.URL == frame.Url()
+}
+
+// removeInjections 窗口关闭时删除窗口内框架的注入结果
+func (h *EventHandler) removeInjections(windowId int32) {
+	prefix := injectionKey(windowId, "")
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for key := range h.injections {
+		if strings.HasPrefix(key, prefix) {
+			delete(h.injections, key)
+		}
+	}
+}
+
+// GetInjectionStatus 获取各框架最近一次文档开始注入的结果（用于诊断）
+func (h *EventHandler) GetInjectionStatus() []InjectionStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	result := make([]InjectionStatus, 0, len(h.injections))
+	for _, status := range h.injections {
+		result = append(result, status)
+	}
+	return result
+}
+
+// SetupRenderProcess 设置渲染进程的注入回调，需要在所有进程中、cef.Run之前调用
+func (h *EventHandler) SetupRenderProcess(app *cef.TCEFApplication) {
+	app.SetOnBrowserCreated(func(browser *cef.ICefBrowser, extraInfo *cef.ICefDictionaryValue) {
+		if extraInfo != nil && extraInfo.IsValid() {
+			h.storeRenderBundle(browser.Identifier(), extraInfo.GetString(fingerprintExtraInfoKey))
+			h.storeRenderRestore(browser.Identifier(), extraInfo.GetString(sessionRestoreExtraInfoKey))
+		}
+	})
+	app.SetOnBrowserDestroyed(func(browser *cef.ICefBrowser) {
+		h.lock.Lock()
+		delete(h.renderBundles, browser.Identifier())
+		delete(h.renderRestores, browser.Identifier())
+		h.lock.Unlock()
+	})
+	app.SetOnProcessMessageReceived(func(browser *cef.ICefBrowser, frame *cef.ICefFrame, sourceProcess consts.CefProcessId, message *cef.ICefProcessMessage) bool {
+		if message.Name() != fingerprintScriptsMessage {
+			return false
+		}
+		h.storeRenderBundle(browser.Identifier(), message.ArgumentList().GetString(0))
+		return true
+	})
+	app.SetOnContextCreated(func(browser *cef.ICefBrowser, frame *cef.ICefFrame, context *cef.ICefV8Context, enableInfraProcess bool) bool {
+		// 从会话快照恢复的窗口先写回本地存储，再注入指纹脚本
+		h.restoreOnContextCreated(browser, frame, context)
+		h.injectOnContextCreated(browser, frame, context)
+		// 返回false继续执行Energy默认的上下文初始化（IPC绑定）
+		return false
+	})
+}
+
+// storeRenderBundle 渲染进程保存浏览器的账户脚本
+func (h *EventHandler) storeRenderBundle(browserId int32, payload string) {
+	if payload == "" {
+		return
+	}
+	var bundle fingerprintBundle
+	if err := json.Unmarshal([]byte(payload), &bundle); err != nil {
+		fmt.Printf("指纹脚本解析失败: %v\n", err)
+		return
+	}
+	h.lock.Lock()
+	h.renderBundles[browserId] = bundle
+	h.lock.Unlock()
+}
+
+// renderBundle 获取浏览器的账户脚本，尚未收到时使用默认配置生成的脚本
+func (h *EventHandler) renderBundle(browserId int32) fingerprintBundle {
+	h.lock.RLock()
+	bundle, ok := h.renderBundles[browserId]
+	h.lock.RUnlock()
+	if ok {
+		return bundle
+	}
+	bundle = fingerprintBundle{Scripts: h.fingerprintScripts("")}
+	h.lock.Lock()
+	h.renderBundles[browserId] = bundle
+	h.lock.Unlock()
+	return bundle
+}
+
+// injectOnContextCreated 在框架的JS上下文创建后、页面脚本执行前注入账户脚本，并回报注入结果
+// 内置页面和不在白名单内的页面不注入
+func (h *EventHandler) injectOnContextCreated(browser *cef.ICefBrowser, frame *cef.ICefFrame, context *cef.ICefV8Context) {
+	frameURL := frame.Url()
+	if isInternalPage(frameURL) {
+		return
+	}
+	bundle := h.renderBundle(browser.Identifier())
+	if frameURL != "" && frameURL != "about:blank" && !h.whitelistValidator.IsURLAllowed(frameURL, bundle.Account) {
+		return
+	}
+	var errs []string
+	for _, script := range bundle.Scripts {
+		if _, exception, ok := context.Eval(script.Source, "", 0); !ok {
+			message := "执行失败"
+			if exception != nil {
+				message = exception.Message()
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s", script.Name, message))
+		}
+	}
+	report := cef.ProcessMessageRef.New(fingerprintInjectedMessage)
+	args := report.ArgumentList()
+	args.SetString(0, bundle.Account)
+	args.SetString(1, frameURL)
+	args.SetString(2, strings.Join(errs, "; "))
+	frame.SendProcessMessage(consts.PID_BROWSER, report)
+}
+
+// setupInjectionEvents 设置浏览器进程接收注入结果和本地存储写回结果的事件
+func (h *EventHandler) setupInjectionEvents(event *cef.BrowserEvent) {
+	event.SetOnBrowseProcessMessageReceived(func(sender lcl.IObject, browser *cef.ICefBrowser, frame *cef.ICefFrame, sourceProcess consts.CefProcessId, message *cef.ICefProcessMessage, window cef.IBrowserWindow) bool {
+		return h.onInjectionReported(frame, message, window) || h.onSessionRestored(message, window)
+	})
+}
+
+// injectMissingFrames 枚举浏览器的所有框架，对未确认文档开始注入的框架补充注入
+func (h *EventHandler) injectMissingFrames(browser *cef.ICefBrowser, account string, window cef.IBrowserWindow) {
+	identifiers := browser.GetFrameIdentifiers()
+	if identifiers == nil {
+		return
+	}
+	defer identifiers.Free()
+	for i := int32(0); i < identifiers.Count(); i++ {
+		if frame := browser.GetFrameById(identifiers.Strings(i)); frame != nil {
+			h.injectMissingFrame(browser, frame, account, window)
+		}
+	}
+}
+
+// injectMissingFrame 框架未确认文档开始注入时补充注入，内置页面和不在白名单内的页面不注入
+func (h *EventHandler) injectMissingFrame(browser *cef.ICefBrowser, frame *cef.ICefFrame, account string, window cef.IBrowserWindow) {
+	if !frame.IsValid() {
+		return
+	}
+	frameURL := frame.Url()
+	if isInternalPage(frameURL) || h.documentInjected(window, frame, account) {
+		return
+	}
+	if frameURL != "" && frameURL != "about:blank" && !h.whitelistValidator.IsURLAllowed(frameURL, account) {
+		return
+	}
+	fmt.Println("未确认文档开始时的指纹注入，补充注入:", frameURL)
+	h.injectFingerprintScripts(browser, account, frame)
+}
+
+// workerScriptRule 在通过网络加载的Worker、共享Worker和Service Worker的脚本前插入Worker指纹伪装脚本
+// 不改变Worker的地址，伪装脚本重复执行时只生效一次；blob:和data:地址不经过网络请求，由页面中的Worker注入脚本覆盖
+func (h *EventHandler) workerScriptRule(request *cef.ICefRequest, window cef.IBrowserWindow) (filter.Rule, bool) {
+	switch request.ResourceType() {
+	case consts.RT_WORKER, consts.RT_SHARED_WORKER, consts.RT_SERVICE_WORKER:
+	default:
+		return filter.Rule{}, false
+	}
+	account := h.getWindowAccount(window)
+	if !h.whitelistValidator.IsURLAllowed(request.URL(), account) {
+		return filter.Rule{}, false
+	}
+	workerScript := h.scriptGenerator.GenerateWorkerScript(account)
+	return filter.Rule{
+		Name: "worker_fingerprint",
+		Handler: func(response *filter.Response, body []byte) []byte {
+			if response.Status != 200 {
+				return nil
+			}
+			result := make([]byte, 0, len(workerScript)+1+len(body))
+			result = append(append(append(result, workerScript...), '\n'), body...)
+			return result
+		},
+	}, true
+}
@@ -0,0 +1,41 @@
+// Package browser IPC调用方校验
+// 页面中的任何脚本（包括白名单站点上的第三方脚本）都可以发送IPC消息，涉及账户或窗口的命令需要校验发送消息的框架
+package browser
+
+import (
+	"fmt"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/cef/ipc/context"
+)
+
+// ipcSender 获取发送IPC消息的窗口和框架，窗口或框架已关闭时返回nil
+func ipcSender(ctx context.IContext) (cef.IBrowserWindow, *cef.ICefFrame) {
+	window := cef.BrowserWindow.GetWindowInfo(ctx.BrowserId())
+	if window == nil {
+		return nil, nil
+	}
+	browser := window.Browser()
+	if browser == nil || !browser.IsValid() {
+		return window, nil
+	}
+	frame := browser.GetFrameById(ctx.FrameId())
+	if frame == nil || !frame.IsValid() {
+		return window, nil
+	}
+	return window, frame
+}
+
+// internalIPCSender 检查IPC消息是否来自内置静态资源服务器的页面，不是时输出日志并拒绝
+func internalIPCSender(ctx context.IContext, event string) (cef.IBrowserWindow, *cef.ICefFrame, bool) {
+	window, frame := ipcSender(ctx)
+	if window == nil || frame == nil {
+		fmt.Printf("拒绝IPC命令%s: 无法确定发送消息的页面\n", event)
+		return nil, nil, false
+	}
+	if !isInternalPage(frame.Url()) {
+		fmt.Printf("拒绝IPC命令%s: 只接受内置页面的调用，发送页面: %s\n", event, frame.Url())
+		return nil, nil, false
+	}
+	return window, frame, true
+}
@@ -0,0 +1,107 @@
+// Package browser 导航状态跟踪
+// 按浏览器ID记录被阻止后的重定向，在滑动时间窗口内检测重定向循环
+package browser
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRedirectWindow 重定向循环检测的时间窗口
+	defaultRedirectWindow = 5 * time.Second
+	// defaultMaxRedirects 时间窗口内允许的最大重定向次数
+	defaultMaxRedirects = 3
+)
+
+// NavigationState 单个浏览器的导航状态（用于诊断）
+type NavigationState struct {
+	BrowserId       int32       `json:"browser_id"`
+	LastBlockedURL  string      `json:"last_blocked_url"`
+	LastRedirectURL string      `json:"last_redirect_url"`
+	RecentRedirects []time.Time `json:"recent_redirects"` // 时间窗口内的重定向时间
+	LoopDetected    bool        `json:"loop_detected"`
+}
+
+// NavigationTracker 按浏览器跟踪导航状态
+type NavigationTracker struct {
+	lock         sync.Mutex
+	window       time.Duration
+	maxRedirects int
+	states       map[int32]*NavigationState
+	now          func() time.Time
+}
+
+// NewNavigationTracker 创建新的导航状态跟踪器实例
+func NewNavigationTracker(window time.Duration, maxRedirects int) *NavigationTracker {
+	return &NavigationTracker{
+		window:       window,
+		maxRedirects: maxRedirects,
+		states:       make(map[int32]*NavigationState),
+		now:          time.Now,
+	}
+}
+
+// AllowRedirect 记录一次被阻止后的重定向，检测到循环时返回false
+// 以下情况视为循环：重定向目标就是被阻止的URL、被阻止的URL正是上次重定向的目标、时间窗口内重定向次数超限
+func (t *NavigationTracker) AllowRedirect(browserId int32, blockedURL, redirectURL string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	state, ok := t.states[browserId]
+	if !ok {
+		state = &NavigationState{BrowserId: browserId}
+		t.states[browserId] = state
+	}
+	now := t.now()
+	state.RecentRedirects = pruneBefore(state.RecentRedirects, now.Add(-t.window))
+	if len(state.RecentRedirects) == 0 {
+		state.LoopDetected = false
+	}
+
+	loop := redirectURL == blockedURL ||
+		(state.LastRedirectURL != "" && blockedURL == state.LastRedirectURL) ||
+		len(state.RecentRedirects) >= t.maxRedirects
+	state.LastBlockedURL = blockedURL
+	if loop {
+		state.LoopDetected = true
+		return false
+	}
+	state.LastRedirectURL = redirectURL
+	state.RecentRedirects = append(state.RecentRedirects, now)
+	return true
+}
+
+// Remove 浏览器关闭时删除其导航状态
+func (t *NavigationTracker) Remove(browserId int32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.states, browserId)
+}
+
+// Snapshot 获取所有浏览器导航状态的副本
+func (t *NavigationTracker) Snapshot() []NavigationState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	cutoff := t.now().Add(-t.window)
+	result := make([]NavigationState, 0, len(t.states))
+	for _, state := range t.states {
+		snapshot := *state
+		snapshot.RecentRedirects = pruneBefore(append([]time.Time(nil), state.RecentRedirects...), cutoff)
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BrowserId < result[j].BrowserId
+	})
+	return result
+}
+
+// pruneBefore 移除早于cutoff的时间
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,189 @@
+package browser
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时间
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestTracker(window time.Duration, maxRedirects int) (*NavigationTracker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tracker := NewNavigationTracker(window, maxRedirects)
+	tracker.now = clock.Now
+	return tracker, clock
+}
+
+func TestNavigationTracker_RedirectToBlockedURL(t *testing.T) {
+	tracker, _ := newTestTracker(5*time.Second, 3)
+	if tracker.AllowRedirect(1, "https://a.com/", "https://a.com/") {
+		t.Fatal("redirect to the blocked URL allowed")
+	}
+	if states := tracker.Snapshot(); len(states) != 1 || !states[0].LoopDetected {
+		t.Fatalf("loop not recorded: %+v", states)
+	}
+}
+
+func TestNavigationTracker_BlockedRedirectTarget(t *testing.T) {
+	tracker, _ := newTestTracker(5*time.Second, 3)
+	if !tracker.AllowRedirect(1, "https://blocked.com/", "https://home.com/") {
+		t.Fatal("first redirect rejected")
+	}
+	// 重定向目标本身也被阻止，再次重定向会形成循环
+	if tracker.AllowRedirect(1, "https://home.com/", "https://other.com/") {
+		t.Fatal("redirect from the previous redirect target allowed")
+	}
+	// 其他浏览器不受影响
+	if !tracker.AllowRedirect(2, "https://home.com/", "https://other.com/") {
+		t.Fatal("redirect in another browser rejected")
+	}
+}
+
+func TestNavigationTracker_MaxRedirectsInWindow(t *testing.T) {
+	tracker, clock := newTestTracker(5*time.Second, 3)
+	for i := 0; i < 3; i++ {
+		if !tracker.AllowRedirect(1, fmt.Sprintf("https://blocked%d.com/", i), fmt.Sprintf("https://home%d.com/", i)) {
+			t.Fatalf("redirect %d rejected", i)
+		}
+		clock.Advance(time.Second)
+	}
+	if tracker.AllowRedirect(1, "https://blocked3.com/", "https://home3.com/") {
+		t.Fatal("redirect over the limit allowed")
+	}
+
+	// 第一次重定向在t=0，t=5s时恰好滑出时间窗口
+	clock.Advance(2 * time.Second)
+	if !tracker.AllowRedirect(1, "https://blocked4.com/", "https://home4.com/") {
+		t.Fatal("redirect rejected after the oldest one left the window")
+	}
+	if tracker.AllowRedirect(1, "https://blocked5.com/", "https://home5.com/") {
+		t.Fatal("redirect allowed while the window is full again")
+	}
+}
+
+func TestNavigationTracker_LoopResetsAfterWindow(t *testing.T) {
+	tracker, clock := newTestTracker(5*time.Second, 1)
+	tracker.AllowRedirect(1, "https://blocked.com/", "https://home.com/")
+	if tracker.AllowRedirect(1, "https://blocked2.com/", "https://home2.com/") {
+		t.Fatal("redirect over the limit allowed")
+	}
+	clock.Advance(6 * time.Second)
+	states := tracker.Snapshot()
+	if len(states[0].RecentRedirects) != 0 {
+		t.Fatalf("expired redirects in snapshot: %v", states[0].RecentRedirects)
+	}
+	if !tracker.AllowRedirect(1, "https://blocked2.com/", "https://home2.com/") {
+		t.Fatal("redirect rejected after the window expired")
+	}
+	if states = tracker.Snapshot(); states[0].LoopDetected {
+		t.Fatal("loop flag not reset after the window expired")
+	}
+}
+
+func TestNavigationTracker_SnapshotAndRemove(t *testing.T) {
+	tracker, clock := newTestTracker(5*time.Second, 3)
+	tracker.AllowRedirect(2, "https://b.com/", "https://home.com/")
+	clock.Advance(time.Second)
+	tracker.AllowRedirect(1, "https://a.com/", "https://home.com/")
+
+	states := tracker.Snapshot()
+	if len(states) != 2 || states[0].BrowserId != 1 || states[1].BrowserId != 2 {
+		t.Fatalf("snapshot not sorted by browser: %+v", states)
+	}
+	// 快照是副本，修改不影响跟踪器
+	states[0].RecentRedirects[0] = time.Time{}
+	if tracker.Snapshot()[0].RecentRedirects[0].IsZero() {
+		t.Fatal("snapshot shares redirect times with the tracker")
+	}
+	if states[0].LastBlockedURL != "https://a.com/" || states[0].LastRedirectURL != "https://home.com/" {
+		t.Fatalf("unexpected state: %+v", states[0])
+	}
+
+	// 只有时间窗口内的重定向出现在快照中
+	clock.Advance(4500 * time.Millisecond)
+	states = tracker.Snapshot()
+	if len(states[0].RecentRedirects) != 1 || len(states[1].RecentRedirects) != 0 {
+		t.Fatalf("snapshot not pruned: %+v", states)
+	}
+
+	tracker.Remove(1)
+	if states = tracker.Snapshot(); len(states) != 1 || states[0].BrowserId != 2 {
+		t.Fatalf("state not removed: %+v", states)
+	}
+}
+
+func TestPruneBefore(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{base, base.Add(time.Second), base.Add(2 * time.Second)}
+	tests := []struct {
+		cutoff time.Time
+		want   int
+	}{
+		{cutoff: base.Add(-time.Second), want: 3},
+		{cutoff: base, want: 2}, // 等于cutoff的时间被移除
+		{cutoff: base.Add(1500 * time.Millisecond), want: 1},
+		{cutoff: base.Add(time.Hour), want: 0},
+	}
+	for _, tt := range tests {
+		if got := pruneBefore(times, tt.cutoff); len(got) != tt.want {
+			t.Errorf("pruneBefore(%v) kept %d, want %d", tt.cutoff, len(got), tt.want)
+		}
+	}
+}
+
+// TestNavigationTracker_Concurrent 需要配合-race运行
+func TestNavigationTracker_Concurrent(t *testing.T) {
+	const (
+		browsers   = 4
+		goroutines = 8
+		attempts   = 100
+		limit      = 3
+	)
+	tracker, clock := newTestTracker(time.Hour, limit)
+	var allowed [browsers]int64
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < attempts; i++ {
+				browserId := int32(i % browsers)
+				blocked := fmt.Sprintf("https://blocked-%d-%d.com/", g, i)
+				redirect := fmt.Sprintf("https://home-%d-%d.com/", g, i)
+				if tracker.AllowRedirect(browserId, blocked, redirect) {
+					atomic.AddInt64(&allowed[browserId], 1)
+				}
+				if i%10 == 0 {
+					tracker.Snapshot()
+					clock.Advance(time.Millisecond)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	// 时间窗口覆盖整个测试，每个浏览器最多允许limit次重定向
+	for browserId, count := range allowed {
+		if count != limit {
+			t.Errorf("browser %d allowed %d redirects, want %d", browserId, count, limit)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package browser 弹出窗口策略
+// 处理window.open、target=_blank等新窗口请求
+package browser
+
+import (
+	"cef/internal/security"
+	"fmt"
+	"net/url"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+// 弹出窗口处理模式
+const (
+	PopupModeSameWindow = "same_window" // 在当前窗口打开
+	PopupModeNewWindow  = "new_window"  // 在新的受控窗口打开
+	PopupModeBlock      = "block"       // 阻止弹出
+	PopupModeWhitelist  = "whitelist"   // 仅允许白名单内的目标在新窗口打开
+)
+
+// handleBeforePopup 根据配置的弹出策略处理新窗口请求
+// 返回true表示阻止CEF/Energy的默认弹出行为
+func (h *EventHandler) handleBeforePopup(popupWindow cef.IBrowserWindow, browser *cef.ICefBrowser, frame *cef.ICefFrame, targetURL string, window cef.IBrowserWindow) bool {
+	account := h.getWindowAccount(window)
+	// 目标不在访问白名单中，一律阻止
+	if targetURL != "" && targetURL != "about:blank" && !h.whitelistValidator.IsURLAllowed(targetURL, account) {
+		h.whitelistValidator.LogBlockedAccess(targetURL)
+		return true
+	}
+
+	popupConfig := h.browserConfig(account).Popup
+	mode := popupConfig.Mode
+	if mode == PopupModeWhitelist {
+		if isPopupTargetAllowed(targetURL, popupConfig.AllowedTargets) {
+			mode = PopupModeNewWindow
+		} else {
+			mode = PopupModeBlock
+		}
+	}
+
+	switch mode {
+	case PopupModeBlock:
+		fmt.Println("阻止弹出窗口:", targetURL)
+		return true
+	case PopupModeSameWindow:
+		fmt.Println("弹出窗口在当前窗口打开:", targetURL)
+		if targetURL != "" {
+			browser.MainFrame().LoadUrl(targetURL)
+		}
+		return true
+	default:
+		// 新窗口由Energy创建，共享同一套事件处理，记录打开者的账户供子窗口继承
+		// 子窗口的浏览器使用打开者的请求上下文，与打开者处于同一账户会话
+		if popupWindow != nil {
+			popupWindow.SetCreateBrowserExtraInfo("", browser.GetRequestContext(), h.fingerprintExtraInfo(account))
+			h.bindPendingWindow(popupWindow, account)
+			h.setupChromiumEvents(popupWindow)
+		}
+		fmt.Println("弹出窗口在新窗口打开:", targetURL)
+		return false
+	}
+}
+
+// isPopupTargetAllowed 检查弹出目标是否在允许列表中
+// 支持精确匹配、子域名匹配和IP网段匹配
+func isPopupTargetAllowed(targetURL string, allowedTargets []string) bool {
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		return false
+	}
+	for _, target := range allowedTargets {
+		if security.MatchHost(parsedURL.Hostname(), target) {
+			return true
+		}
+	}
+	return false
+}
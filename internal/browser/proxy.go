@@ -0,0 +1,255 @@
+// Package browser 账户代理
+// 按窗口的账户选择代理，每个请求上下文只在创建后、账户变化或代理不可用时设置代理偏好
+package browser
+
+import (
+	"cef/internal/config"
+	"cef/internal/proxy"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/cef/ipc"
+)
+
+// proxyAssignment 请求上下文当前使用的代理
+type proxyAssignment struct {
+	context *cef.ICefRequestContext
+	account string
+	server  *config.ProxyServer // 为空表示不使用代理
+}
+
+// globalProxyContext 全局请求上下文的代理分配标识
+const globalProxyContext = "global"
+
+// proxyContextKey 获取请求上下文的代理分配标识，代理偏好设置在请求上下文上，分配按请求上下文记录
+// 全局请求上下文为global，账户会话的请求上下文为account:账户，其他请求上下文不设置代理时返回false
+func (h *EventHandler) proxyContextKey(requestContext *cef.ICefRequestContext) (key, owner string, ok bool) {
+	if requestContext.IsGlobal() {
+		return globalProxyContext, "", true
+	}
+	if owner = h.sessionManager.Owner(requestContext); owner != "" {
+		return "account:" + owner, owner, true
+	}
+	return "", "", false
+}
+
+// applyWindowProxy 为窗口的请求上下文设置代理
+// 未启用会话隔离时所有窗口共用全局请求上下文，按窗口账户的配置选择代理；
+// 启用会话隔离时按请求上下文所属的账户选择，全局请求上下文始终使用默认配置。
+// 全局请求上下文中识别到账户后窗口会切换到账户会话的新窗口，不在即将被替换的全局请求上下文上设置该账户的代理
+// 请求上下文已按同一账户设置过代理时跳过，需要在UI线程中调用
+func (h *EventHandler) applyWindowProxy(window cef.IBrowserWindow) {
+	browser := window.Chromium().Browser()
+	if browser == nil || !browser.IsValid() {
+		return
+	}
+	requestContext := browser.GetRequestContext()
+	if requestContext == nil {
+		return
+	}
+	account := h.getWindowAccount(window)
+	if h.sessionManager.Enabled() {
+		_, owner, ok := h.proxyContextKey(requestContext)
+		if !ok {
+			return
+		}
+		account = owner
+	}
+	h.applyContextProxy(requestContext, account, false)
+}
+
+// applyContextProxy 为请求上下文设置账户的代理
+// reselect为true时即使已设置过也重新选择代理（用于代理可用性变化后切换），选择结果不变时不重复设置
+func (h *EventHandler) applyContextProxy(requestContext *cef.ICefRequestContext, account string, reselect bool) {
+	key, _, tracked := h.proxyContextKey(requestContext)
+	if !tracked {
+		return
+	}
+	h.lock.RLock()
+	assignment, ok := h.proxyAssignments[key]
+	h.lock.RUnlock()
+	sameContext := ok && assignment.account == account
+	if sameContext && !reselect {
+		return
+	}
+
+	cfg := h.browserConfig(account)
+	previous := assignment.server
+	assignment = proxyAssignment{context: requestContext, account: account}
+	if server, selected := h.proxySelector.Select(account, cfg.Proxy.Selection, proxy.Servers(*cfg), h.proxyAvailable()); selected {
+		assignment.server = &server
+	}
+	if sameContext && previous != nil && assignment.server != nil && proxy.Key(*previous) == proxy.Key(*assignment.server) {
+		return
+	}
+	var preference map[string]string
+	switch {
+	case assignment.server != nil || cfg.Proxy.Mode == proxy.ModeDirect:
+		preference = proxy.Preference(assignment.server, cfg.Proxy.Bypass)
+	case ok && previous != nil:
+		// 之前的账户使用了代理，恢复系统代理设置
+		preference = map[string]string{"mode": proxy.ModeSystem}
+	}
+	// 未配置代理时保持Chromium默认的代理设置
+	if preference != nil && !setProxyPreference(requestContext, preference) {
+		return
+	}
+	h.lock.Lock()
+	h.proxyAssignments[key] = assignment
+	h.lock.Unlock()
+	if assignment.server != nil {
+		fmt.Printf("设置代理 - 账户: %s, 代理: %s (%s)\n", account, assignment.server.Name, assignment.server.Type)
+	} else {
+		fmt.Printf("设置代理 - 账户: %s, 不使用代理\n", account)
+	}
+	h.emitProxyStatus()
+}
+
+// proxyAvailable 获取代理可用性判断，未启用健康检查时返回nil（所有代理都视为可用）
+func (h *EventHandler) proxyAvailable() func(config.ProxyServer) bool {
+	if h.proxyHealth == nil {
+		return nil
+	}
+	return h.proxyHealth.Healthy
+}
+
+// proxyServers 获取需要健康检查的代理：默认配置和已设置代理的账户配置中的代理
+func (h *EventHandler) proxyServers() []config.ProxyServer {
+	servers := proxy.Servers(*h.browserConfig())
+	h.lock.RLock()
+	accounts := make([]string, 0, len(h.proxyAssignments))
+	for _, assignment := range h.proxyAssignments {
+		if assignment.account != "" {
+			accounts = append(accounts, assignment.account)
+		}
+	}
+	h.lock.RUnlock()
+	for _, account := range accounts {
+		servers = append(servers, proxy.Servers(*h.browserConfig(account))...)
+	}
+	return servers
+}
+
+// onProxyHealthChange 代理可用性变化后切换受影响的请求上下文
+// 代理不可用时使用它的请求上下文切换到备用代理；代理恢复后固定选择的请求上下文切换回原代理
+func (h *EventHandler) onProxyHealthChange(server config.ProxyServer, status proxy.Status) {
+	h.emitProxyStatus()
+	cef.RunOnMainThread(func() {
+		h.lock.RLock()
+		assignments := make([]proxyAssignment, 0, len(h.proxyAssignments))
+		for _, assignment := range h.proxyAssignments {
+			assignments = append(assignments, assignment)
+		}
+		h.lock.RUnlock()
+		for _, assignment := range assignments {
+			if assignment.server == nil {
+				continue
+			}
+			failed := !status.Healthy && proxy.Key(*assignment.server) == proxy.Key(server)
+			recovered := status.Healthy && h.browserConfig(assignment.account).Proxy.Selection != proxy.SelectionRoundRobin
+			if failed || recovered {
+				h.applyContextProxy(assignment.context, assignment.account, true)
+			}
+		}
+	})
+}
+
+// proxyCredentials 获取代理认证信息，优先使用窗口请求上下文当前代理的认证信息
+func (h *EventHandler) proxyCredentials(window cef.IBrowserWindow, host string, port int32) (string, string) {
+	account := h.getWindowAccount(window)
+	if browser := window.Chromium().Browser(); browser != nil && browser.IsValid() {
+		if requestContext := browser.GetRequestContext(); requestContext != nil {
+			if key, _, tracked := h.proxyContextKey(requestContext); tracked {
+				h.lock.RLock()
+				assignment, ok := h.proxyAssignments[key]
+				h.lock.RUnlock()
+				if ok && assignment.server != nil && proxy.Match(*assignment.server, host, port) {
+					return assignment.server.Username, assignment.server.Password
+				}
+			}
+		}
+	}
+	for _, server := range proxy.Servers(*h.browserConfig(account)) {
+		if proxy.Match(server, host, port) {
+			return server.Username, server.Password
+		}
+	}
+	proxyConfig := h.browserConfig(account).Proxy
+	return proxyConfig.Username, proxyConfig.Password
+}
+
+// ProxyStatus 请求上下文当前使用的代理及其健康状态
+type ProxyStatus struct {
+	Context   string `json:"context"` // 请求上下文标识，global或account:账户
+	Account   string `json:"account"` // 选择代理时使用的账户配置，为空表示默认配置
+	Server    string `json:"server"`
+	Type      string `json:"type"`
+	Healthy   bool   `json:"healthy"`
+	LatencyMs int64  `json:"latency_ms"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// GetProxyStatus 获取每个请求上下文当前使用的代理及其健康状态（用于展示当前出口）
+func (h *EventHandler) GetProxyStatus() []ProxyStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	result := make([]ProxyStatus, 0, len(h.proxyAssignments))
+	for key, assignment := range h.proxyAssignments {
+		if assignment.server == nil {
+			continue
+		}
+		status := ProxyStatus{
+			Context: key,
+			Account: assignment.account,
+			Server:  assignment.server.Name,
+			Type:    assignment.server.Type,
+			Healthy: true,
+		}
+		if h.proxyHealth != nil {
+			if health, ok := h.proxyHealth.Status(*assignment.server); ok {
+				status.Healthy = health.Healthy
+				status.LatencyMs = health.Latency.Milliseconds()
+				status.LastError = health.LastError
+			}
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Context < result[j].Context })
+	return result
+}
+
+// registerProxyIPC 注册代理状态相关的IPC事件
+// 前端可以通过ipc.emit("getProxyStatus", function(status){...})获取当前代理，
+// 并通过ipc.on("proxyStatus", function(status){...})接收代理切换通知
+func (h *EventHandler) registerProxyIPC() {
+	ipc.On("getProxyStatus", func() string {
+		data, _ := json.Marshal(h.GetProxyStatus())
+		return string(data)
+	})
+}
+
+// emitProxyStatus 通知前端代理状态变化
+func (h *EventHandler) emitProxyStatus() {
+	data, err := json.Marshal(h.GetProxyStatus())
+	if err != nil {
+		return
+	}
+	ipc.Emit("proxyStatus", string(data))
+}
+
+// setProxyPreference 设置请求上下文的代理偏好
+func setProxyPreference(requestContext *cef.ICefRequestContext, preference map[string]string) bool {
+	proxyDict := cef.DictionaryValueRef.New()
+	for key, value := range preference {
+		proxyDict.SetString(key, value)
+	}
+	value := cef.ValueRef.New()
+	value.SetDictionary(proxyDict)
+	if errMsg, ok := requestContext.SetPreference("proxy", value); !ok {
+		fmt.Printf("设置代理失败: %s\n", errMsg)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+// Package browser 响应过滤
+// 将filter包的流式过滤器接入CEF ResponseFilter，按URL规则检查或改写响应内容
+package browser
+
+import (
+	"cef/internal/config"
+	"cef/internal/filter"
+	"unsafe"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/consts"
+)
+
+// GetResponseFilters 获取响应过滤规则集合（用于注册检查或改写响应的规则）
+func (h *EventHandler) GetResponseFilters() *filter.Registry {
+	return h.responseFilters
+}
+
+// getResponseFilter 获取请求的响应过滤器，没有匹配的规则时返回nil
+func (h *EventHandler) getResponseFilter(browser *cef.ICefBrowser, request *cef.ICefRequest, response *cef.ICefResponse, window cef.IBrowserWindow) *cef.ICefResponseFilter {
+	filterResponse := &filter.Response{
+		BrowserId:       browser.Identifier(),
+		PageURL:         browser.MainFrame().Url(),
+		URL:             request.URL(),
+		MimeType:        response.MimeType(),
+		ContentEncoding: response.GetHeaderByName("Content-Encoding"),
+		Status:          response.Status(),
+	}
+	rules := h.responseFilters.Match(filterResponse)
+	if detectionRules := h.accountDetector.MatchRules(DetectionSourceResponse, filterResponse.PageURL, filterResponse.URL); len(detectionRules) > 0 {
+		rules = append(rules, h.accountDetectionRule(detectionRules, window))
+	}
+	if workerRule, ok := h.workerScriptRule(request, window); ok {
+		rules = append(rules, workerRule)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return newResponseFilter(filter.NewStreamFilter(filterResponse, rules, 0))
+}
+
+// accountDetectionRule 创建从响应内容中识别窗口账户的过滤规则，只检查不修改内容
+func (h *EventHandler) accountDetectionRule(detectionRules []config.AccountDetectionRule, window cef.IBrowserWindow) filter.Rule {
+	return filter.Rule{
+		Name: "account_detection",
+		Handler: func(response *filter.Response, body []byte) []byte {
+			if account := h.accountDetector.ExtractFromBody(detectionRules, body); account != "" {
+				h.onAccountDetected(window, account)
+			}
+			return nil
+		},
+	}
+}
+
+// newResponseFilter 将流式过滤器包装为CEF ResponseFilter
+func newResponseFilter(streamFilter *filter.StreamFilter) *cef.ICefResponseFilter {
+	responseFilter := cef.ResponseFilterRef.New()
+	responseFilter.InitFilter(func() bool {
+		return true
+	})
+	responseFilter.Filter(func(dataIn uintptr, dataInSize uint32, dataInRead *uint32, dataOut uintptr, dataOutSize uint32, dataOutWritten *uint32) consts.TCefResponseFilterStatus {
+		// dataIn为空表示响应已经没有更多输入
+		var in []byte
+		if dataIn != 0 {
+			if in = bytesAt(dataIn, dataInSize); in == nil {
+				in = []byte{}
+			}
+		}
+		read, written, done := streamFilter.Filter(in, bytesAt(dataOut, dataOutSize))
+		*dataInRead = uint32(read)
+		*dataOutWritten = uint32(written)
+		if done {
+			return consts.RESPONSE_FILTER_DONE
+		}
+		return consts.RESPONSE_FILTER_NEED_MORE_DATA
+	})
+	return responseFilter
+}
+
+// bytesAt 将CEF传入的内存地址转换为切片，不复制数据
+func bytesAt(address uintptr, size uint32) []byte {
+	if address == 0 || size == 0 {
+		return nil
+	}
+	return unsafe.Slice(*(**byte)(unsafe.Pointer(&address)), size)
+}
@@ -0,0 +1,69 @@
+// Package browser 响应改写
+// 将配置中启用的改写规则注册到响应过滤，并统计每条规则的生效次数
+package browser
+
+import (
+	"cef/internal/audit"
+	"cef/internal/filter"
+	"fmt"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+// rewriteRulePrefix 改写规则在响应过滤规则集合中的名称前缀
+const rewriteRulePrefix = "rewrite:"
+
+// registerRewriteRules 注册配置中启用的改写规则，配置错误的规则会被跳过
+func (h *EventHandler) registerRewriteRules() {
+	for _, cfg := range h.browserConfig().Rewrites {
+		if !cfg.Enabled {
+			continue
+		}
+		if cfg.Name == "" {
+			fmt.Println("响应改写规则缺少名称，已跳过")
+			continue
+		}
+		rule, err := filter.NewRewriteRule(cfg, h.onRewriteApplied)
+		if err != nil {
+			fmt.Printf("响应改写规则加载失败: %v\n", err)
+			continue
+		}
+		rule.Name = rewriteRulePrefix + cfg.Name
+		h.responseFilters.Register(rule)
+	}
+}
+
+// onRewriteApplied 记录改写规则的生效次数并写入审计日志
+func (h *EventHandler) onRewriteApplied(rule string, response *filter.Response, changes int) {
+	h.lock.Lock()
+	h.rewriteCounts[rule]++
+	count := h.rewriteCounts[rule]
+	h.lock.Unlock()
+
+	fmt.Printf("响应改写规则生效 - 规则: %s, URL: %s, 修改数: %d\n", rule, response.URL, changes)
+	record := audit.Record{
+		Event: audit.EventRewrite,
+		URL:   response.URL,
+		Rule:  rule,
+		Detail: map[string]interface{}{
+			"changes": changes,
+			"count":   count,
+		},
+	}
+	if window := cef.BrowserWindow.GetWindowInfo(response.BrowserId); window != nil {
+		record.Account = h.getWindowAccount(window)
+		record.WindowId = window.Id()
+	}
+	audit.Log(record)
+}
+
+// GetRewriteCounts 获取每条改写规则的生效次数
+func (h *EventHandler) GetRewriteCounts() map[string]int64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	counts := make(map[string]int64, len(h.rewriteCounts))
+	for rule, count := range h.rewriteCounts {
+		counts[rule] = count
+	}
+	return counts
+}
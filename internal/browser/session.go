@@ -0,0 +1,294 @@
+// Package browser 账户会话隔离
+// 为每个账户创建独立的请求上下文和缓存目录，避免Cookie、本地存储和缓存在账户之间泄漏
+package browser
+
+import (
+	"cef/internal/config"
+	"cef/internal/cookies"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/energye/energy/v2/cef"
+)
+
+// 会话保留模式
+const (
+	SessionModeKeepAlive  = "keep_alive" // 会话数据保存到磁盘，关闭窗口后保留
+	SessionModeDisposable = "disposable" // 会话数据仅保存在内存，账户的窗口全部关闭后释放
+)
+
+const (
+	defaultSessionCacheDir = "cache"
+	// cookieMigrateTimeout 迁移Cookie的最长等待时间
+	cookieMigrateTimeout = 2 * time.Second
+)
+
+// accountSession 单个账户的会话
+type accountSession struct {
+	account   string
+	cachePath string
+	context   *cef.ICefRequestContext
+	windows   map[int32]struct{} // 使用该会话的窗口
+}
+
+// SessionManager 账户会话管理器
+type SessionManager struct {
+	lock          sync.Mutex
+	browserConfig func(...string) *config.BrowserConfig
+	sessions      map[string]*accountSession
+}
+
+// NewSessionManager 创建新的账户会话管理器实例
+func NewSessionManager(browserConfig func(...string) *config.BrowserConfig) *SessionManager {
+	return &SessionManager{
+		browserConfig: browserConfig,
+		sessions:      make(map[string]*accountSession),
+	}
+}
+
+// Enabled 是否启用账户会话隔离
+func (m *SessionManager) Enabled() bool {
+	return m.browserConfig().Session.Isolation
+}
+
+// RootCachePath 获取缓存根目录（所有请求上下文的缓存目录都必须位于其中）
+func (m *SessionManager) RootCachePath() string {
+	dir := m.browserConfig().Session.CacheDir
+	if dir == "" {
+		dir = defaultSessionCacheDir
+	}
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	return dir
+}
+
+// DefaultCachePath 获取未绑定账户时使用的全局请求上下文缓存目录
+func (m *SessionManager) DefaultCachePath() string {
+	return filepath.Join(m.RootCachePath(), "default")
+}
+
+// CachePath 获取账户的缓存目录，disposable模式下返回空（仅使用内存）
+func (m *SessionManager) CachePath(account string) string {
+	if m.browserConfig().Session.Mode == SessionModeDisposable {
+		return ""
+	}
+	return filepath.Join(m.RootCachePath(), "accounts", SanitizeFileName(account))
+}
+
+// RequestContext 获取账户的请求上下文，不存在时创建
+// 需要在UI线程中调用
+func (m *SessionManager) RequestContext(account string) *cef.ICefRequestContext {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if session, ok := m.sessions[account]; ok {
+		return session.context
+	}
+
+	cachePath := m.CachePath(account)
+	if cachePath != "" {
+		if err := os.MkdirAll(cachePath, 0750); err != nil {
+			fmt.Printf("创建账户缓存目录失败: %v\n", err)
+			return nil
+		}
+	}
+	settings := cef.TCefRequestContextSettings{
+		CachePath:             cachePath,
+		PersistSessionCookies: 0,
+	}
+	if cachePath != "" {
+		settings.PersistSessionCookies = 1
+	}
+	context := cef.RequestContextRef.New(settings, nil)
+	if context == nil {
+		fmt.Printf("创建账户请求上下文失败 - 账户: %s\n", account)
+		return nil
+	}
+	m.sessions[account] = &accountSession{
+		account:   account,
+		cachePath: cachePath,
+		context:   context,
+		windows:   make(map[int32]struct{}),
+	}
+	fmt.Printf("创建账户会话 - 账户: %s, 缓存目录: %s\n", account, cachePath)
+	return context
+}
+
+// Attach 记录窗口正在使用账户的会话
+func (m *SessionManager) Attach(windowId int32, account string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if session, ok := m.sessions[account]; ok {
+		session.windows[windowId] = struct{}{}
+	}
+}
+
+// Detach 窗口关闭时解除与会话的关联
+// disposable模式下账户的窗口全部关闭后释放会话
+func (m *SessionManager) Detach(windowId int32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	disposable := m.browserConfig().Session.Mode == SessionModeDisposable
+	for account, session := range m.sessions {
+		if _, ok := session.windows[windowId]; !ok {
+			continue
+		}
+		delete(session.windows, windowId)
+		if disposable && len(session.windows) == 0 {
+			m.release(session)
+			delete(m.sessions, account)
+		}
+	}
+}
+
+// Owner 获取使用请求上下文的账户，不是账户会话的请求上下文时返回空
+// 需要在UI线程中调用
+func (m *SessionManager) Owner(context *cef.ICefRequestContext) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for account, session := range m.sessions {
+		if session.context != nil && session.context.IsValid() && session.context.IsSame(context) {
+			return account
+		}
+	}
+	return ""
+}
+
+// Accounts 获取已创建会话的账户
+func (m *SessionManager) Accounts() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	accounts := make([]string, 0, len(m.sessions))
+	for account := range m.sessions {
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// Close 释放所有会话，keep_alive模式下先将Cookie写入磁盘
+func (m *SessionManager) Close() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for account, session := range m.sessions {
+		m.release(session)
+		delete(m.sessions, account)
+	}
+}
+
+// release 释放会话的请求上下文
+func (m *SessionManager) release(session *accountSession) {
+	if session.context == nil || !session.context.IsValid() {
+		return
+	}
+	if session.cachePath != "" {
+		session.context.GetCookieManager(nil).FlushStore(nil)
+	}
+	session.context.Free()
+	fmt.Printf("释放账户会话 - 账户: %s\n", session.account)
+}
+
+// sessionSwitch 窗口识别到账户后的会话切换方式
+type sessionSwitch int
+
+const (
+	sessionSwitchNone    sessionSwitch = iota // 窗口已使用账户的会话
+	sessionSwitchMigrate                      // 窗口使用全局请求上下文，迁移登录产生的Cookie后切换
+	sessionSwitchRefuse                       // 窗口使用其他账户或未知的请求上下文，不迁移也不切换
+)
+
+// decideSessionSwitch 按窗口当前请求上下文的归属决定会话切换方式
+// owner为使用当前请求上下文的账户，global表示当前为全局请求上下文。
+// 只有全局请求上下文中的Cookie是新登录产生的，其他账户会话中的Cookie属于那个账户，迁移会把A的登录状态交给B并清空A的会话
+func decideSessionSwitch(owner string, global bool, account string) sessionSwitch {
+	switch {
+	case owner == account:
+		return sessionSwitchNone
+	case owner == "" && global:
+		return sessionSwitchMigrate
+	default:
+		return sessionSwitchRefuse
+	}
+}
+
+// migrateCookies 将全局请求上下文中的Cookie移动到账户的请求上下文
+// 只有allowed允许的Cookie（账户白名单域名下的Cookie）写入目标上下文，源上下文中的Cookie全部删除，
+// 下一个账户登录时不会带上前一个账户的登录状态，迁移完成或超时后调用done
+func migrateCookies(from, to *cef.ICefRequestContext, allowed func(cookies.Cookie) bool, done func(count int)) {
+	source := from.GetCookieManager(nil)
+	target := to.GetCookieManager(nil)
+	finished := make(chan struct{}, 1)
+	var count int32
+	visitor := cef.CookieVisitorRef.New()
+	visitor.SetOnVisit(func(cookie *cef.TCefCookie, deleteCookie, result *bool) {
+		if allowed(fromCefCookie(cookie)) {
+			target.SetCookie(cookie.Url, cookie.Name, cookie.Value, cookie.Domain, cookie.Path,
+				cookie.Secure, cookie.Httponly, cookie.HasExpires, cookie.Creation, cookie.LastAccess, cookie.Expires,
+				cookie.SameSite, cookie.Priority, nil)
+			atomic.AddInt32(&count, 1)
+		}
+		*deleteCookie = true
+		*result = true
+		if cookie.Count+1 >= cookie.Total {
+			select {
+			case finished <- struct{}{}:
+			default:
+			}
+		}
+	})
+	source.VisitAllCookies(visitor)
+
+	// 没有Cookie时不会回调访问器，超时后按已迁移的数量结束
+	go func() {
+		select {
+		case <-finished:
+		case <-time.After(cookieMigrateTimeout):
+		}
+		done(int(atomic.LoadInt32(&count)))
+	}()
+}
+
+// switchAccountSession 检测到账户后将窗口切换到该账户的会话
+// CEF不支持更换已创建浏览器的请求上下文，因此将登录产生的Cookie移动到账户会话，
+// 在绑定账户会话的新窗口中打开当前页面，原窗口返回默认页面供下一个账户登录；
+// 窗口已使用其他账户的会话时不切换，避免其他账户的登录状态被移动到该账户
+func (h *EventHandler) switchAccountSession(window cef.IBrowserWindow, account string) {
+	if account == "" || !h.sessionManager.Enabled() {
+		return
+	}
+	cef.RunOnMainThread(func() {
+		browser := window.Chromium().Browser()
+		if browser == nil || !browser.IsValid() {
+			return
+		}
+		current := browser.GetRequestContext()
+		if current == nil {
+			return
+		}
+		owner := h.sessionManager.Owner(current)
+		switch decideSessionSwitch(owner, current.IsGlobal(), account) {
+		case sessionSwitchNone:
+			return
+		case sessionSwitchRefuse:
+			fmt.Printf("拒绝账户会话切换 - 窗口: %d, 账户: %s, 窗口当前会话: %s\n", window.Id(), account, owner)
+			return
+		}
+		context := h.sessionManager.RequestContext(account)
+		if context == nil {
+			return
+		}
+		targetURL := browser.MainFrame().Url()
+		migrateCookies(current, context, h.cookieAllowed(account), func(count int) {
+			fmt.Printf("账户会话切换 - 账户: %s, 迁移Cookie: %d\n", account, count)
+			cef.RunOnMainThread(func() {
+				h.openAccountWindow(account, targetURL, context)
+				// 原窗口不再属于该账户，按默认配置等待下一个账户登录
+				h.unbindWindowAccount(window.Id())
+				browser.MainFrame().LoadUrl(h.browserConfig().App.DefaultURL)
+			})
+		})
+	})
+}
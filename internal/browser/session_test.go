@@ -0,0 +1,26 @@
+package browser
+
+import "testing"
+
+func TestDecideSessionSwitch(t *testing.T) {
+	tests := []struct {
+		name    string
+		owner   string
+		global  bool
+		account string
+		want    sessionSwitch
+	}{
+		{name: "login in global context", owner: "", global: true, account: "b@example.com", want: sessionSwitchMigrate},
+		{name: "already in own session", owner: "b@example.com", account: "b@example.com", want: sessionSwitchNone},
+		// 账户A的窗口中识别到账户B时不能把A的Cookie移动到B
+		{name: "switch from A to B", owner: "a@example.com", account: "b@example.com", want: sessionSwitchRefuse},
+		{name: "unknown context", owner: "", global: false, account: "b@example.com", want: sessionSwitchRefuse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decideSessionSwitch(tt.owner, tt.global, tt.account); got != tt.want {
+				t.Errorf("decideSessionSwitch(%q, %v, %q) = %v, want %v", tt.owner, tt.global, tt.account, got, tt.want)
+			}
+		})
+	}
+}
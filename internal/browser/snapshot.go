@@ -0,0 +1,617 @@
+// Package browser 账户会话快照
+// 将账户的Cookie和白名单源的localStorage、IndexedDB保存为加密快照，恢复时Cookie在窗口创建前写回，
+// localStorage和IndexedDB由渲染进程在对应源的文档开始时、页面脚本执行前写回，
+// 使操作人员可以在其他机器上或清除缓存后继续使用账户的登录状态
+package browser
+
+import (
+	"cef/internal/audit"
+	"cef/internal/cookies"
+	"cef/internal/snapshot"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/energye/energy/v2/cef"
+	"github.com/energye/energy/v2/cef/ipc"
+	"github.com/energye/energy/v2/cef/ipc/context"
+	"github.com/energye/energy/v2/cef/ipc/target"
+	"github.com/energye/energy/v2/consts"
+)
+
+// snapshotKeyEnv 未配置快照口令时读取的环境变量，避免将口令写入配置文件
+const snapshotKeyEnv = "CEF_SNAPSHOT_KEY"
+
+const (
+	// storageTimeout 读取localStorage和IndexedDB的最长等待时间
+	storageTimeout = 15 * time.Second
+	// snapshotWorldName 读取IndexedDB的隔离脚本环境名称，与页面脚本互不可见
+	snapshotWorldName = "session_snapshot"
+	// restoreWindow 恢复的窗口打开后写回本地存储的期限，期限内没有打开的源不再写回
+	restoreWindow = 2 * time.Minute
+	// sessionRestoreExtraInfoKey 创建浏览器时通过extra_info传递待写回的本地存储
+	sessionRestoreExtraInfoKey = "session_restore"
+	// sessionRestoredMessage 渲染进程回报给浏览器进程的本地存储写回结果
+	sessionRestoredMessage = "session_restored"
+)
+
+// SessionSnapshotResult 会话快照保存或恢复的结果，通过IPC返回给前端
+type SessionSnapshotResult struct {
+	Account string `json:"account"`
+	Path    string `json:"path,omitempty"`
+	Cookies int    `json:"cookies"` // 保存或恢复的Cookie数量
+	Origins int    `json:"origins"` // 保存或恢复了localStorage或IndexedDB的源数量
+	Error   string `json:"error,omitempty"`
+}
+
+// sessionRestore 浏览器进程中等待渲染进程回报的本地存储写回，各map在回报时修改，需要持有锁
+type sessionRestore struct {
+	result   SessionSnapshotResult
+	origins  map[string]bool   // 需要写回的源
+	restored map[string]int    // 已写回的源 -> 写入的键数量
+	failed   map[string]string // 写回失败的源 -> 错误，之后写回成功时删除
+	done     func(SessionSnapshotResult)
+}
+
+// sessionRestorePayload 随extra_info传递给渲染进程的本地存储
+type sessionRestorePayload struct {
+	Origins []snapshot.OriginStorage `json:"origins"`
+	Expires time.Time                `json:"expires"`
+}
+
+// renderRestore 渲染进程中尚未写回的本地存储
+type renderRestore struct {
+	origins map[string]snapshot.OriginStorage // 源 -> 待写回的localStorage和IndexedDB
+	expires time.Time
+}
+
+// snapshotPath 获取账户的快照文件路径
+func (h *EventHandler) snapshotPath(account string) string {
+	dir := h.browserConfig(account).Session.SnapshotDir
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	return filepath.Join(dir, SanitizeFileName(account)+snapshot.FileExt)
+}
+
+// snapshotKey 获取快照口令，未配置时使用环境变量
+func (h *EventHandler) snapshotKey(account string) string {
+	if key := h.browserConfig(account).Session.SnapshotKey; key != "" {
+		return key
+	}
+	return os.Getenv(snapshotKeyEnv)
+}
+
+// SnapshotSession 保存账户的会话快照：白名单域名下的Cookie，以及这些域名和账户窗口当前页面的源的本地存储
+// 本地存储通过账户的窗口读取，账户没有打开的窗口时只保存Cookie；IndexedDB只能从账户窗口中已打开该源的框架读取，
+// 没有打开的源只保存localStorage，完成后在后台goroutine中调用done
+func (h *EventHandler) SnapshotSession(account string, done func(SessionSnapshotResult)) {
+	result := SessionSnapshotResult{Account: account, Path: h.snapshotPath(account)}
+	fail := func(err error) {
+		result.Error = err.Error()
+		fmt.Printf("保存会话快照失败 - 账户: %s, 错误: %v\n", account, err)
+		done(result)
+	}
+	key := h.snapshotKey(account)
+	if account == "" || key == "" {
+		go fail(errors.New("账户为空或未配置会话快照口令"))
+		return
+	}
+	cef.RunOnMainThread(func() {
+		requestContext := h.accountRequestContext(account)
+		if requestContext == nil {
+			go fail(fmt.Errorf("账户%s的请求上下文不可用", account))
+			return
+		}
+		visitCookies(requestContext, func(all []cookies.Cookie) {
+			saved := cookies.Filter(all, h.cookieAllowed(account))
+			cef.RunOnMainThread(func() {
+				h.captureOrigins(account, h.snapshotOrigins(account, saved), func(origins []snapshot.OriginStorage) {
+					err := snapshot.Save(result.Path, &snapshot.Snapshot{
+						Version:   snapshot.Version,
+						Account:   account,
+						CreatedAt: time.Now(),
+						Cookies:   saved,
+						Origins:   origins,
+					}, key)
+					if err != nil {
+						fail(err)
+						return
+					}
+					result.Cookies, result.Origins = len(saved), len(origins)
+					logSessionSnapshot("snapshot", result)
+					done(result)
+				})
+			})
+		})
+	})
+}
+
+// RestoreSession 从快照恢复账户的会话并打开账户窗口
+// Cookie在窗口创建前写入账户的请求上下文，窗口直接打开targetURL；本地存储随extra_info传递给渲染进程，
+// 在restoreWindow期限内各源的文档开始时写回，页面中已存在的localStorage键和IndexedDB记录不覆盖。
+// 不在白名单内和已过期的数据不会恢复，所有源写回或期限结束后在后台goroutine中调用done
+func (h *EventHandler) RestoreSession(account, targetURL string, done func(SessionSnapshotResult)) {
+	result := SessionSnapshotResult{Account: account, Path: h.snapshotPath(account)}
+	fail := func(err error) {
+		result.Error = err.Error()
+		fmt.Printf("恢复会话快照失败 - 账户: %s, 错误: %v\n", account, err)
+		done(result)
+	}
+	saved, err := snapshot.Load(result.Path, account, h.snapshotKey(account))
+	if err != nil {
+		go fail(err)
+		return
+	}
+	if targetURL == "" {
+		targetURL = h.browserConfig(account).App.DefaultURL
+	}
+	now := time.Now()
+	allowed := h.cookieAllowed(account)
+	restoreCookies := cookies.Filter(saved.Cookies, func(cookie cookies.Cookie) bool {
+		return !cookie.Expired(now) && allowed(cookie)
+	})
+	restore := sessionRestore{
+		origins:  make(map[string]bool),
+		restored: make(map[string]int),
+		failed:   make(map[string]string),
+		done:     done,
+	}
+	payload := sessionRestorePayload{Expires: now.Add(restoreWindow)}
+	for _, storage := range saved.Origins {
+		if parsedURL, err := url.Parse(storage.Origin); err == nil && (len(storage.LocalStorage) > 0 || len(storage.IndexedDB) > 0) && h.whitelistValidator.IsDomainAllowed(parsedURL.Hostname(), account) {
+			restore.origins[storage.Origin] = true
+			payload.Origins = append(payload.Origins, storage)
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		go fail(err)
+		return
+	}
+
+	cef.RunOnMainThread(func() {
+		var context *cef.ICefRequestContext
+		if h.sessionManager.Enabled() {
+			if context = h.sessionManager.RequestContext(account); context == nil {
+				go fail(fmt.Errorf("账户%s的请求上下文不可用", account))
+				return
+			}
+		}
+		requestContext := context
+		if requestContext == nil {
+			requestContext = cef.RequestContextRef.Global()
+		}
+		manager := requestContext.GetCookieManager(nil)
+		for _, cookie := range restoreCookies {
+			if setCookie(manager, cookie, now) {
+				result.Cookies++
+			}
+		}
+		manager.FlushStore(nil)
+
+		extraInfo := h.fingerprintExtraInfo(account)
+		if len(payload.Origins) > 0 {
+			extraInfo.SetString(sessionRestoreExtraInfoKey, string(data))
+		}
+		window := h.createAccountWindow(account, targetURL, context, extraInfo)
+		if window == nil {
+			go fail(fmt.Errorf("创建账户%s的窗口失败", account))
+			return
+		}
+		restore.result = result
+		h.lock.Lock()
+		h.pendingRestores[window] = restore
+		h.lock.Unlock()
+		if len(payload.Origins) == 0 {
+			h.finishSessionRestore(window)
+			return
+		}
+		time.AfterFunc(restoreWindow, func() {
+			cef.RunOnMainThread(func() {
+				h.finishSessionRestore(window)
+			})
+		})
+	})
+}
+
+// onSessionRestored 记录渲染进程回报的本地存储写回结果，所有源写回后完成恢复
+func (h *EventHandler) onSessionRestored(message *cef.ICefProcessMessage, window cef.IBrowserWindow) bool {
+	if message.Name() != sessionRestoredMessage {
+		return false
+	}
+	args := message.ArgumentList()
+	origin, restored, errMessage := args.GetString(0), int(args.GetInt(1)), args.GetString(2)
+	h.lock.Lock()
+	restore, ok := h.pendingRestores[window]
+	complete := false
+	if ok && restore.origins[origin] {
+		if errMessage != "" {
+			restore.failed[origin] = errMessage
+		} else {
+			delete(restore.failed, origin)
+			restore.restored[origin] = restored
+		}
+		complete = len(restore.restored) == len(restore.origins)
+	}
+	h.lock.Unlock()
+	if errMessage != "" {
+		fmt.Printf("写回本地存储失败 - 窗口: %d, 源: %s, 错误: %s\n", window.Id(), origin, errMessage)
+	}
+	if complete {
+		h.finishSessionRestore(window)
+	}
+	return true
+}
+
+// finishSessionRestore 结束窗口的会话恢复，记录审计日志并调用done，只执行一次
+func (h *EventHandler) finishSessionRestore(window cef.IBrowserWindow) {
+	h.lock.Lock()
+	restore, ok := h.pendingRestores[window]
+	delete(h.pendingRestores, window)
+	h.lock.Unlock()
+	if !ok {
+		return
+	}
+	result := restore.result
+	result.Origins = len(restore.restored)
+	if len(restore.failed) > 0 {
+		result.Error = fmt.Sprintf("%d个源的本地存储恢复失败", len(restore.failed))
+	}
+	if pending := len(restore.origins) - len(restore.restored) - len(restore.failed); pending > 0 {
+		fmt.Printf("%d个源在恢复期限内没有打开，未写回本地存储 - 账户: %s\n", pending, result.Account)
+	}
+	logSessionSnapshot("restore", result)
+	go restore.done(result)
+}
+
+// storeRenderRestore 渲染进程保存浏览器待写回的本地存储
+func (h *EventHandler) storeRenderRestore(browserId int32, payload string) {
+	if payload == "" {
+		return
+	}
+	var restore sessionRestorePayload
+	if err := json.Unmarshal([]byte(payload), &restore); err != nil {
+		fmt.Printf("会话快照本地存储解析失败: %v\n", err)
+		return
+	}
+	origins := make(map[string]snapshot.OriginStorage, len(restore.Origins))
+	for _, storage := range restore.Origins {
+		origins[storage.Origin] = storage
+	}
+	h.lock.Lock()
+	h.renderRestores[browserId] = renderRestore{origins: origins, expires: restore.Expires}
+	h.lock.Unlock()
+}
+
+// restoreOnContextCreated 框架的JS上下文创建后、页面脚本执行前写回该源的本地存储，并回报写回结果
+// 每个源在渲染进程中成功写回一次，写回失败（如沙箱框架无法访问localStorage）时保留到该源的下一个文档。
+// 回报的数量为写入的localStorage键数量，IndexedDB在页面中异步写回，单条记录的写入结果不回报
+func (h *EventHandler) restoreOnContextCreated(browser *cef.ICefBrowser, frame *cef.ICefFrame, context *cef.ICefV8Context) {
+	origin := frameOrigin(frame.Url())
+	if origin == "" {
+		return
+	}
+	h.lock.Lock()
+	restore, ok := h.renderRestores[browser.Identifier()]
+	if ok && time.Now().After(restore.expires) {
+		delete(h.renderRestores, browser.Identifier())
+		ok = false
+	}
+	storage, found := restore.origins[origin]
+	h.lock.Unlock()
+	if !ok || !found {
+		return
+	}
+
+	restored := int32(0)
+	errMessage := ""
+	if len(storage.LocalStorage) > 0 {
+		restored, errMessage = evalRestoreScript(context, snapshot.RestoreScript(storage.LocalStorage))
+	}
+	if errMessage == "" && len(storage.IndexedDB) > 0 {
+		_, errMessage = evalRestoreScript(context, snapshot.IndexedDBRestoreScript(storage.IndexedDB))
+	}
+	if errMessage == "" {
+		h.lock.Lock()
+		delete(restore.origins, origin)
+		h.lock.Unlock()
+	}
+
+	report := cef.ProcessMessageRef.New(sessionRestoredMessage)
+	args := report.ArgumentList()
+	args.SetString(0, origin)
+	args.SetInt(1, restored)
+	args.SetString(2, errMessage)
+	frame.SendProcessMessage(consts.PID_BROWSER, report)
+}
+
+// evalRestoreScript 执行写回脚本，返回脚本的整数结果，执行失败时返回错误信息
+func evalRestoreScript(context *cef.ICefV8Context, script string) (int32, string) {
+	value, exception, success := context.Eval(script, "", 0)
+	if !success {
+		if exception != nil {
+			return 0, exception.Message()
+		}
+		return 0, "执行失败"
+	}
+	if value != nil && value.IsInt() {
+		return value.GetIntValue(), ""
+	}
+	return 0, ""
+}
+
+// frameOrigin 获取http(s)页面的源，其他页面返回空
+func frameOrigin(frameURL string) string {
+	parsedURL, err := url.Parse(frameURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return ""
+	}
+	return parsedURL.Scheme + "://" + parsedURL.Host
+}
+
+// snapshotOrigins 获取需要保存本地存储的源：Cookie所属的域名和账户窗口当前页面的源
+func (h *EventHandler) snapshotOrigins(account string, saved []cookies.Cookie) []string {
+	origins := make(map[string]bool)
+	for _, cookie := range saved {
+		origins["https://"+cookie.Host()] = true
+	}
+	for _, window := range h.accountWindows(account) {
+		browser := window.Chromium().Browser()
+		if browser == nil || !browser.IsValid() {
+			continue
+		}
+		parsedURL, err := url.Parse(browser.MainFrame().Url())
+		if err != nil || parsedURL.Host == "" || !h.whitelistValidator.IsDomainAllowed(parsedURL.Hostname(), account) {
+			continue
+		}
+		origins[parsedURL.Scheme+"://"+parsedURL.Host] = true
+	}
+	result := make([]string, 0, len(origins))
+	for origin := range origins {
+		result = append(result, origin)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// captureOrigins 通过账户的窗口读取各源的localStorage和IndexedDB，只保留有数据的源
+// localStorage通过DOMStorage读取，IndexedDB在账户窗口中第一个该源的框架的隔离脚本环境中读取，
+// 需要在UI线程中调用，完成或超时后在后台goroutine中调用done
+func (h *EventHandler) captureOrigins(account string, origins []string, done func([]snapshot.OriginStorage)) {
+	windows := h.accountWindows(account)
+	if len(windows) == 0 || len(origins) == 0 {
+		if len(origins) > 0 {
+			fmt.Printf("账户没有打开的窗口，会话快照只保存Cookie - 账户: %s\n", account)
+		}
+		go done(nil)
+		return
+	}
+	window := windows[0]
+
+	var lock sync.Mutex
+	storages := make([]snapshot.OriginStorage, len(origins))
+	finished := make(chan struct{})
+	remaining := 0
+	complete := func() {
+		if remaining--; remaining == 0 {
+			close(finished)
+		}
+	}
+	h.devTools.call(window, "DOMStorage.enable", nil, nil)
+	index := make(map[string]int, len(origins))
+	for i, origin := range origins {
+		i := i
+		index[origin] = i
+		storages[i] = snapshot.OriginStorage{Origin: origin, LocalStorage: make(map[string]string)}
+		params := cef.DictionaryValueRef.New()
+		params.SetDictionary("storageId", localStorageId(origin))
+		if h.devTools.call(window, "DOMStorage.getDOMStorageItems", params, func(success bool, result *cef.ICefDictionaryValue) {
+			if success {
+				entries := result.GetList("entries")
+				lock.Lock()
+				for j := uint32(0); entries != nil && j < entries.Size(); j++ {
+					if entry := entries.GetList(j); entry != nil && entry.Size() >= 2 {
+						storages[i].LocalStorage[entry.GetString(0)] = entry.GetString(1)
+					}
+				}
+				lock.Unlock()
+			}
+			complete()
+		}) {
+			remaining++
+		}
+	}
+	// 回调都在UI线程中执行，嵌套调用在外层调用完成前计数，计数不会提前归零
+	captured := make(map[string]bool)
+	for _, window := range windows {
+		window := window
+		if h.devTools.call(window, "Page.getFrameTree", nil, func(success bool, result *cef.ICefDictionaryValue) {
+			if success {
+				for _, frame := range devToolsFrames(result.GetDictionary("frameTree"), nil) {
+					i, ok := index[frame.origin]
+					if !ok || captured[frame.origin] {
+						continue
+					}
+					captured[frame.origin] = true
+					if h.captureIndexedDB(window, frame.id, func(databases []snapshot.IndexedDBDatabase) {
+						lock.Lock()
+						storages[i].IndexedDB = databases
+						lock.Unlock()
+						complete()
+					}) {
+						remaining++
+					}
+				}
+			}
+			complete()
+		}) {
+			remaining++
+		}
+	}
+	if remaining == 0 {
+		close(finished)
+	}
+
+	go func() {
+		select {
+		case <-finished:
+		case <-time.After(storageTimeout):
+			fmt.Printf("读取本地存储超时 - 账户: %s\n", account)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		var result []snapshot.OriginStorage
+		for _, storage := range storages {
+			if len(storage.LocalStorage) > 0 || len(storage.IndexedDB) > 0 {
+				result = append(result, storage)
+			}
+		}
+		done(result)
+	}()
+}
+
+// captureIndexedDB 在框架的隔离脚本环境中读取该源的所有IndexedDB数据库，调用成功时返回true，完成后在UI线程中调用done
+func (h *EventHandler) captureIndexedDB(window cef.IBrowserWindow, frameId string, done func([]snapshot.IndexedDBDatabase)) bool {
+	params := cef.DictionaryValueRef.New()
+	params.SetString("frameId", frameId)
+	params.SetString("worldName", snapshotWorldName)
+	return h.devTools.call(window, "Page.createIsolatedWorld", params, func(success bool, result *cef.ICefDictionaryValue) {
+		if !success {
+			done(nil)
+			return
+		}
+		evalParams := cef.DictionaryValueRef.New()
+		evalParams.SetString("expression", snapshot.IndexedDBCaptureScript())
+		evalParams.SetInt("contextId", result.GetInt("executionContextId"))
+		evalParams.SetBool("awaitPromise", true)
+		evalParams.SetBool("returnByValue", true)
+		if !h.devTools.call(window, "Runtime.evaluate", evalParams, func(success bool, result *cef.ICefDictionaryValue) {
+			var databases []snapshot.IndexedDBDatabase
+			if success {
+				// 脚本抛出异常时没有value
+				if value := result.GetDictionary("result"); value != nil && value.GetString("value") != "" {
+					if err := json.Unmarshal([]byte(value.GetString("value")), &databases); err != nil {
+						fmt.Printf("解析IndexedDB失败: %v\n", err)
+					}
+				}
+			}
+			done(databases)
+		}) {
+			done(nil)
+		}
+	})
+}
+
+// devToolsFrame DevTools框架树中的框架
+type devToolsFrame struct {
+	id     string
+	origin string
+}
+
+// devToolsFrames 按文档顺序展开Page.getFrameTree返回的框架树
+func devToolsFrames(tree *cef.ICefDictionaryValue, frames []devToolsFrame) []devToolsFrame {
+	if tree == nil {
+		return frames
+	}
+	if frame := tree.GetDictionary("frame"); frame != nil {
+		frames = append(frames, devToolsFrame{id: frame.GetString("id"), origin: frame.GetString("securityOrigin")})
+	}
+	children := tree.GetList("childFrames")
+	for i := uint32(0); children != nil && i < children.Size(); i++ {
+		frames = devToolsFrames(children.GetDictionary(i), frames)
+	}
+	return frames
+}
+
+// accountWindows 获取使用账户请求上下文的窗口
+// 未启用会话隔离时所有窗口共用全局请求上下文，账户没有窗口时使用主窗口
+func (h *EventHandler) accountWindows(account string) []cef.IBrowserWindow {
+	var windowIds []int32
+	for windowId, windowAccount := range h.GetWindowAccounts() {
+		if windowAccount == account {
+			windowIds = append(windowIds, windowId)
+		}
+	}
+	sort.Slice(windowIds, func(i, j int) bool { return windowIds[i] < windowIds[j] })
+	var windows []cef.IBrowserWindow
+	for _, windowId := range windowIds {
+		if window := cef.BrowserWindow.GetWindowInfo(windowId); window != nil {
+			windows = append(windows, window)
+		}
+	}
+	if len(windows) == 0 && !h.sessionManager.Enabled() {
+		if window := cef.BrowserWindow.MainWindow(); window != nil {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// localStorageId 生成DevTools DOMStorage使用的localStorage标识
+func localStorageId(origin string) *cef.ICefDictionaryValue {
+	storageId := cef.DictionaryValueRef.New()
+	storageId.SetString("securityOrigin", origin)
+	storageId.SetBool("isLocalStorage", true)
+	return storageId
+}
+
+// logSessionSnapshot 记录会话快照保存或恢复的审计日志
+func logSessionSnapshot(action string, result SessionSnapshotResult) {
+	fmt.Printf("会话快照 - 账户: %s, 操作: %s, Cookie: %d, 本地存储源: %d, 文件: %s\n", result.Account, action, result.Cookies, result.Origins, result.Path)
+	audit.Log(audit.Record{
+		Event:   audit.EventSession,
+		Account: result.Account,
+		Rule:    action,
+		Detail:  result,
+	})
+}
+
+// registerSnapshotIPC 注册会话快照的IPC命令，只接受内置页面的调用，结果只发送给调用的框架
+// 内置页面通过ipc.emit("snapshotSession", [account])保存快照，结果通过ipc.on("sessionSnapshotted", ...)接收；
+// 通过ipc.emit("restoreSession", [account, url])恢复并打开账户窗口，结果通过ipc.on("sessionRestored", ...)接收
+func (h *EventHandler) registerSnapshotIPC() {
+	ipc.On("snapshotSession", func(ctx context.IContext) {
+		_, frame, ok := internalIPCSender(ctx, "snapshotSession")
+		if !ok {
+			return
+		}
+		args := ctx.ArgumentList()
+		if args == nil || args.Size() < 1 {
+			return
+		}
+		sender := frame.Target()
+		h.SnapshotSession(args.GetStringByIndex(0), func(result SessionSnapshotResult) {
+			emitSnapshotResult("sessionSnapshotted", sender, result)
+		})
+	})
+	ipc.On("restoreSession", func(ctx context.IContext) {
+		_, frame, ok := internalIPCSender(ctx, "restoreSession")
+		if !ok {
+			return
+		}
+		args := ctx.ArgumentList()
+		if args == nil || args.Size() < 1 {
+			return
+		}
+		sender := frame.Target()
+		h.RestoreSession(args.GetStringByIndex(0), args.GetStringByIndex(1), func(result SessionSnapshotResult) {
+			emitSnapshotResult("sessionRestored", sender, result)
+		})
+	})
+}
+
+// emitSnapshotResult 将会话快照结果发送给调用的框架
+func emitSnapshotResult(name string, sender target.ITarget, result SessionSnapshotResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	ipc.EmitTarget(name, sender, string(data))
+}
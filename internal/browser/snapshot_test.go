@@ -0,0 +1,22 @@
+package browser
+
+import "testing"
+
+func TestFrameOrigin(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://ad.oceanengine.com/pages/index.html?a=1#top", "https://ad.oceanengine.com"},
+		{"http://localhost:8080/", "http://localhost:8080"},
+		{"about:blank", ""},
+		{"data:text/html,hello", ""},
+		{"file:///tmp/index.html", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := frameOrigin(tt.url); got != tt.want {
+			t.Errorf("frameOrigin(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package browser Chromium命令行开关配置
+// 开关集合以命名配置的形式声明，启动时按已知开关列表校验并输出日志
+package browser
+
+import (
+	"cef/internal/audit"
+	"cef/internal/config"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/energye/energy/v2/cef/process"
+)
+
+// SwitchProfileStrict 未选择开关配置时使用的配置名称，应只包含不降低安全性的开关
+const SwitchProfileStrict = "strict"
+
+// knownSwitches 允许配置的开关，值表示该开关本身是否会降低安全性
+// --disable-features是否降低安全性取决于关闭的功能，由weakensSecurity判断
+var knownSwitches = map[string]bool{
+	"--disable-web-security":                      true,
+	"--allow-running-insecure-content":            true,
+	"--disable-site-isolation-trials":             true,
+	"--allow-websocket-upgrade-on-any-port":       true,
+	"--disable-features":                          false,
+	"--enable-features":                           false,
+	"--disable-extensions":                        false,
+	"--disable-plugins":                           false,
+	"--disable-default-apps":                      false,
+	"--disable-background-timer-throttling":       false,
+	"--disable-background-networking":             false,
+	"--disable-renderer-backgrounding":            false,
+	"--disable-sync":                              false,
+	"--disable-translate":                         false,
+	"--enable-websockets":                         false,
+	"--enable-experimental-web-platform-features": false,
+	"--no-proxy-server":                           false,
+}
+
+// securityFeatures 通过--disable-features关闭后会降低安全性的Chromium功能
+var securityFeatures = map[string]bool{
+	"siteisolation":                       true,
+	"siteperprocess":                      true,
+	"isolateorigins":                      true,
+	"strictoriginisolation":               true,
+	"blockinsecureprivatenetworkrequests": true,
+	"samesitebydefaultcookies":            true,
+	"cookieswithoutsamesitemustbesecure":  true,
+}
+
+// resolveSwitchProfile 获取配置选择的开关集合
+// 开关配置在配置文件的switches.profiles中声明；选择的配置不存在时回退到strict，未知开关被忽略
+func resolveSwitchProfile(browserConfig *config.BrowserConfig) (string, []config.CommandLineSwitch) {
+	// Viper会将配置键转为小写，配置名称统一按小写查找
+	name := strings.ToLower(strings.TrimSpace(browserConfig.Switches.Profile))
+	if name == "" {
+		name = SwitchProfileStrict
+	}
+	switches, ok := browserConfig.Switches.Profiles[name]
+	if !ok {
+		fmt.Printf("命令行开关配置不存在: %s，可用配置: %s，使用%s配置\n", name, strings.Join(availableSwitchProfiles(browserConfig), ", "), SwitchProfileStrict)
+		name = SwitchProfileStrict
+		switches = browserConfig.Switches.Profiles[SwitchProfileStrict]
+	}
+
+	result := make([]config.CommandLineSwitch, 0, len(switches))
+	for _, item := range switches {
+		item = normalizeSwitch(item)
+		if _, known := knownSwitches[item.Name]; !known {
+			fmt.Printf("忽略未知的命令行开关: %s\n", item.Name)
+			continue
+		}
+		result = append(result, item)
+	}
+	return name, result
+}
+
+// applySwitchProfile 将开关配置添加到应用命令行并输出日志，降低安全性的开关写入审计日志
+func applySwitchProfile(addSwitch func(name, value string), browserConfig *config.BrowserConfig) {
+	name, switches := resolveSwitchProfile(browserConfig)
+	fmt.Printf("使用命令行开关配置: %s（%d个开关）\n", name, len(switches))
+	var weakening []string
+	for _, item := range switches {
+		if weakensSecurity(item) {
+			fmt.Printf("  %s %s [降低安全性]\n", item.Name, item.Value)
+			weakening = append(weakening, strings.TrimSuffix(item.Name+"="+item.Value, "="))
+		} else {
+			fmt.Printf("  %s %s\n", item.Name, item.Value)
+		}
+		addSwitch(item.Name, item.Value)
+	}
+	// 每个子进程都会执行初始化，只在主进程中记录一次
+	if len(weakening) > 0 && process.Args.IsMain() {
+		audit.Log(audit.Record{
+			Event: audit.EventSwitches,
+			Rule:  name,
+			Detail: map[string]interface{}{
+				"weakening_switches": weakening,
+			},
+		})
+	}
+}
+
+// weakensSecurity 检查开关是否会降低安全性，--disable-features按关闭的功能判断
+func weakensSecurity(item config.CommandLineSwitch) bool {
+	if knownSwitches[item.Name] {
+		return true
+	}
+	if item.Name != "--disable-features" {
+		return false
+	}
+	for _, feature := range strings.Split(item.Value, ",") {
+		if securityFeatures[strings.ToLower(strings.TrimSpace(feature))] {
+			return true
+		}
+	}
+	return false
+}
+
+// availableSwitchProfiles 获取所有可用的开关配置名称
+func availableSwitchProfiles(browserConfig *config.BrowserConfig) []string {
+	result := make([]string, 0, len(browserConfig.Switches.Profiles))
+	for name := range browserConfig.Switches.Profiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// normalizeSwitch 统一开关格式：名称小写并带--前缀，名称中以=写入的值拆分到Value
+func normalizeSwitch(item config.CommandLineSwitch) config.CommandLineSwitch {
+	name := strings.TrimSpace(item.Name)
+	if i := strings.Index(name, "="); i >= 0 {
+		if item.Value == "" {
+			item.Value = name[i+1:]
+		}
+		name = name[:i]
+	}
+	item.Name = "--" + strings.TrimLeft(strings.ToLower(name), "-")
+	return item
+}
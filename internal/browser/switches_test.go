@@ -0,0 +1,47 @@
+package browser
+
+import (
+	"cef/internal/config"
+	"testing"
+)
+
+func TestWeakensSecurity(t *testing.T) {
+	tests := []struct {
+		item config.CommandLineSwitch
+		want bool
+	}{
+		{config.CommandLineSwitch{Name: "--disable-web-security"}, true},
+		{config.CommandLineSwitch{Name: "--disable-extensions"}, false},
+		{config.CommandLineSwitch{Name: "--disable-features", Value: "TranslateUI,BackgroundSync"}, false},
+		{config.CommandLineSwitch{Name: "--disable-features", Value: "VizDisplayCompositor,SiteIsolation"}, true},
+		{config.CommandLineSwitch{Name: "--disable-features", Value: "TranslateUI, isolateorigins"}, true},
+		{config.CommandLineSwitch{Name: "--enable-features", Value: "SiteIsolation"}, false},
+	}
+	for _, tt := range tests {
+		if got := weakensSecurity(tt.item); got != tt.want {
+			t.Errorf("weakensSecurity(%s=%s) = %v, want %v", tt.item.Name, tt.item.Value, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSwitchProfile(t *testing.T) {
+	cfg := &config.BrowserConfig{}
+	cfg.Switches.Profiles = map[string][]config.CommandLineSwitch{
+		"strict": {{Name: "--disable-sync"}},
+		"compat": {{Name: "Disable-Web-Security"}, {Name: "--disable-features=SiteIsolation"}, {Name: "--unknown-switch"}},
+	}
+
+	cfg.Switches.Profile = "Compat"
+	name, switches := resolveSwitchProfile(cfg)
+	if name != "compat" || len(switches) != 2 {
+		t.Fatalf("resolveSwitchProfile() = %s, %+v", name, switches)
+	}
+	if switches[0].Name != "--disable-web-security" || switches[1].Name != "--disable-features" || switches[1].Value != "SiteIsolation" {
+		t.Fatalf("switches not normalized: %+v", switches)
+	}
+
+	cfg.Switches.Profile = "missing"
+	if name, switches = resolveSwitchProfile(cfg); name != SwitchProfileStrict || len(switches) != 1 {
+		t.Fatalf("missing profile did not fall back to strict: %s, %+v", name, switches)
+	}
+}
@@ -104,6 +104,8 @@ func (l *Loader) LoadWhitelistConfig() error {
 	v.SetDefault("allowed_domains", []string{"google.com", "agent.oceanengine.com", "accounts.google.com"})
 	v.SetDefault("blocked_message", "访问被限制：该网站不在允许访问列表中")
 	v.SetDefault("redirect_blocked_to", "https://agent.oceanengine.com/")
+	v.SetDefault("blocked_action", "redirect")
+	v.SetDefault("out_of_schedule_msg", "访问被限制：当前不在该网站允许访问的时间段内")
 
 	// 读取配置文件
 	if err := v.ReadConfig(bytes.NewReader(configData)); err != nil {
@@ -121,6 +123,11 @@ func (l *Loader) LoadWhitelistConfig() error {
 	l.whitelistConfig.NotAllowedDomains = v.GetStringSlice("not_allowed_domains")
 	l.whitelistConfig.BlockedMessage = v.GetString("blocked_message")
 	l.whitelistConfig.RedirectBlockedTo = v.GetString("redirect_blocked_to")
+	l.whitelistConfig.BlockedAction = v.GetString("blocked_action")
+	l.whitelistConfig.OutOfScheduleMsg = v.GetString("out_of_schedule_msg")
+	if err := unmarshalKeyByJSON(v, "rules", &l.whitelistConfig.Rules); err != nil {
+		return fmt.Errorf("解析白名单规则失败: %v", err)
+	}
 
 	fmt.Printf("白名单配置加载完成: 允许域名数量=%d，不允许域名数量=%d\n", len(l.whitelistConfig.AllowedDomains), len(l.whitelistConfig.NotAllowedDomains))
 
@@ -195,6 +202,20 @@ func (l *Loader) GetAllowedEmailsConfigLoader() func() *AllowedEmailsConfig {
 	}
 }
 
+// unmarshalKeyByJSON 按json标签解析嵌套配置项
+// Viper的UnmarshalKey使用mapstructure标签，无法识别带下划线的json字段名
+func unmarshalKeyByJSON(v *viper.Viper, key string, result interface{}) error {
+	value := v.Get(key)
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
 // setDefaultBrowserConfig 设置浏览器配置的默认值
 func (l *Loader) setDefaultBrowserConfig(v *viper.Viper) {
 	v.SetDefault("basic.user_agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
@@ -251,6 +272,19 @@ func (l *Loader) setDefaultBrowserConfig(v *viper.Viper) {
 	v.SetDefault("headers.pragma", "no-cache")
 	v.SetDefault("headers.x_sw_cache", "7")
 
+	v.SetDefault("popup.mode", "new_window")
+
+	v.SetDefault("download.dir", "downloads")
+	v.SetDefault("download.max_size_mb", 500)
+
+	v.SetDefault("certificate.mode", "strict")
+
+	v.SetDefault("switches.profile", "compat-oceanengine")
+
+	v.SetDefault("session.mode", "keep_alive")
+	v.SetDefault("session.cache_dir", "cache")
+	v.SetDefault("session.snapshot_dir", "snapshots")
+
 	//v.SetDefault("proxy.mode", "fixed_servers")
 	//v.SetDefault("proxy.url", "111.198.26.17:13128")
 	//v.SetDefault("proxy.username", "xy_liuliang_tool_01")
@@ -314,9 +348,61 @@ func (l *Loader) setFallbackBrowserConfig(v *viper.Viper) {
 	l.browserConfig.Headers.Pragma = v.GetString("headers.pragma")
 	l.browserConfig.Headers.XSwCache = v.GetString("headers.x_sw_cache")
 
+	if err := unmarshalKeyByJSON(v, "header_rules.rules", &l.browserConfig.HeaderRules.Rules); err != nil {
+		fmt.Printf("请求头改写规则解析失败: %v\n", err)
+	}
+	l.browserConfig.HeaderRules.MultiValue = v.GetStringMapString("header_rules.multi_value")
+	l.browserConfig.HeaderRules.Order = v.GetString("header_rules.order")
+	l.browserConfig.HeaderRules.CustomOrder = v.GetStringSlice("header_rules.custom_order")
+
 	l.browserConfig.Proxy.Mode = v.GetString("proxy.mode")
 	l.browserConfig.Proxy.Url = v.GetString("proxy.url")
 	l.browserConfig.Proxy.Username = v.GetString("proxy.username")
 	l.browserConfig.Proxy.Password = v.GetString("proxy.password")
 	l.browserConfig.Proxy.Debug = v.GetBool("proxy.debug")
+	l.browserConfig.Proxy.Type = v.GetString("proxy.type")
+	l.browserConfig.Proxy.Bypass = v.GetStringSlice("proxy.bypass")
+	l.browserConfig.Proxy.Selection = v.GetString("proxy.selection")
+	if err := unmarshalKeyByJSON(v, "proxy.pool", &l.browserConfig.Proxy.Pool); err != nil {
+		fmt.Printf("代理池配置解析失败: %v\n", err)
+	}
+	l.browserConfig.Proxy.HealthCheck.Enabled = v.GetBool("proxy.health_check.enabled")
+	l.browserConfig.Proxy.HealthCheck.ProbeURL = v.GetString("proxy.health_check.probe_url")
+	l.browserConfig.Proxy.HealthCheck.IntervalSec = v.GetInt("proxy.health_check.interval_sec")
+	l.browserConfig.Proxy.HealthCheck.TimeoutSec = v.GetInt("proxy.health_check.timeout_sec")
+	l.browserConfig.Proxy.HealthCheck.FailureThreshold = v.GetInt("proxy.health_check.failure_threshold")
+
+	l.browserConfig.Popup.Mode = v.GetString("popup.mode")
+	l.browserConfig.Popup.AllowedTargets = v.GetStringSlice("popup.allowed_targets")
+
+	l.browserConfig.Download.Dir = v.GetString("download.dir")
+	l.browserConfig.Download.AllowedMimeTypes = v.GetStringSlice("download.allowed_mime_types")
+	l.browserConfig.Download.AllowedExtensions = v.GetStringSlice("download.allowed_extensions")
+	l.browserConfig.Download.MaxSizeMB = v.GetInt64("download.max_size_mb")
+
+	l.browserConfig.Certificate.Mode = v.GetString("certificate.mode")
+	l.browserConfig.Certificate.AllowedErrorHosts = v.GetStringSlice("certificate.allowed_error_hosts")
+	l.browserConfig.Certificate.CustomCAFile = v.GetString("certificate.custom_ca_file")
+	if err := unmarshalKeyByJSON(v, "certificate.pins", &l.browserConfig.Certificate.Pins); err != nil {
+		fmt.Printf("证书固定配置解析失败: %v\n", err)
+	}
+
+	l.browserConfig.Switches.Profile = v.GetString("switches.profile")
+	if err := unmarshalKeyByJSON(v, "switches.profiles", &l.browserConfig.Switches.Profiles); err != nil {
+		fmt.Printf("命令行开关配置解析失败: %v\n", err)
+	}
+
+	l.browserConfig.Session.Isolation = v.GetBool("session.isolation")
+	l.browserConfig.Session.Mode = v.GetString("session.mode")
+	l.browserConfig.Session.CacheDir = v.GetString("session.cache_dir")
+	l.browserConfig.Session.SnapshotDir = v.GetString("session.snapshot_dir")
+	l.browserConfig.Session.SnapshotKey = v.GetString("session.snapshot_key")
+
+	if err := unmarshalKeyByJSON(v, "account_detection", &l.browserConfig.AccountDetection); err != nil {
+		fmt.Printf("账户识别规则解析失败: %v\n", err)
+	}
+
+	if err := unmarshalKeyByJSON(v, "rewrites", &l.browserConfig.Rewrites); err != nil {
+		fmt.Printf("响应改写规则解析失败: %v\n", err)
+	}
 }
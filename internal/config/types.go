@@ -120,13 +120,150 @@ type BrowserConfig struct {
 		XSwCache               string `json:"x_sw_cache"`
 	} `json:"headers"`
 
+	// 请求头改写规则，在请求头配置之后执行
+	HeaderRules struct {
+		Rules       []HeaderRule      `json:"rules"`        // 按顺序执行的规则
+		MultiValue  map[string]string `json:"multi_value"`  // 请求头名称 -> 多值策略: keep/dedupe/first/last/join，默认dedupe
+		Order       string            `json:"order"`        // 请求头顺序: 为空或original保持原顺序，chrome_120/chrome_124按对应版本Chrome的顺序
+		CustomOrder []string          `json:"custom_order"` // 自定义请求头顺序，配置后忽略order，"*"表示其他请求头的位置
+	} `json:"header_rules"`
+
+	// 代理配置，账户的配置可单独指定代理或代理池
 	Proxy struct {
-		Mode     string `json:"mode,omitempty"`
-		Url      string `json:"url,omitempty"`
-		Username string `json:"username,omitempty"`
-		Password string `json:"password,omitempty"`
-		Debug    bool   `json:"debug,omitempty"`
+		Mode        string           `json:"mode,omitempty"`      // direct/system/fixed_servers/pac_script，为空时按代理类型推断
+		Type        string           `json:"type,omitempty"`      // http/https/socks5/pac，默认http
+		Url         string           `json:"url,omitempty"`       // 代理地址host:port，pac类型为PAC脚本URL
+		Username    string           `json:"username,omitempty"`  // 代理认证用户名
+		Password    string           `json:"password,omitempty"`  // 代理认证密码
+		Bypass      []string         `json:"bypass,omitempty"`    // 不使用代理的域名，如*.example.com、<local>
+		Pool        []ProxyServer    `json:"pool,omitempty"`      // 代理池，配置后忽略url
+		Selection   string           `json:"selection,omitempty"` // 代理池选择方式: sticky/round_robin，默认sticky
+		HealthCheck ProxyHealthCheck `json:"health_check"`        // 代理健康检查，不可用时切换到备用代理
+		Debug       bool             `json:"debug,omitempty"`
 	} `json:"proxy"`
+
+	// 弹出窗口配置
+	Popup struct {
+		Mode           string   `json:"mode"`            // same_window/new_window/block/whitelist
+		AllowedTargets []string `json:"allowed_targets"` // whitelist模式下允许弹出的域名
+	} `json:"popup"`
+
+	// 下载配置
+	Download struct {
+		Dir               string   `json:"dir"`                // 下载根目录，按账户分子目录
+		AllowedMimeTypes  []string `json:"allowed_mime_types"` // 允许的MIME类型，为空不限制
+		AllowedExtensions []string `json:"allowed_extensions"` // 允许的文件扩展名，为空不限制
+		MaxSizeMB         int64    `json:"max_size_mb"`        // 单个文件大小上限（MB），0不限制
+	} `json:"download"`
+
+	// 证书校验配置
+	Certificate struct {
+		Mode              string           `json:"mode"`                // strict/permissive，默认strict
+		AllowedErrorHosts []string         `json:"allowed_error_hosts"` // 允许忽略证书错误的域名
+		Pins              []CertificatePin `json:"pins"`                // SPKI固定配置，只增加拒绝条件，不放行证书错误；正常连接在页面加载完成后才校验
+		CustomCAFile      string           `json:"custom_ca_file"`      // 自定义企业根证书（PEM）路径
+	} `json:"certificate"`
+
+	// Chromium命令行开关配置
+	Switches struct {
+		Profile  string                         `json:"profile"`  // 使用的开关配置名称，如strict、compat-oceanengine
+		Profiles map[string][]CommandLineSwitch `json:"profiles"` // 开关配置名称（小写） -> 开关列表
+	} `json:"switches"`
+
+	// 账户会话隔离配置
+	Session struct {
+		Isolation   bool   `json:"isolation"`    // 是否为每个账户使用独立的请求上下文（Cookie、存储和缓存）
+		Mode        string `json:"mode"`         // keep_alive/disposable，默认keep_alive
+		CacheDir    string `json:"cache_dir"`    // 缓存根目录，账户缓存保存在accounts子目录
+		SnapshotDir string `json:"snapshot_dir"` // 会话快照目录，每个账户一个加密文件
+		SnapshotKey string `json:"snapshot_key"` // 会话快照加密口令，为空时使用环境变量CEF_SNAPSHOT_KEY
+	} `json:"session"`
+
+	// 账户识别规则，为空时使用内置的巨量引擎规则
+	AccountDetection []AccountDetectionRule `json:"account_detection"`
+
+	// 响应改写规则，按顺序对匹配的响应依次执行
+	Rewrites []RewriteRule `json:"rewrites"`
+}
+
+// RewriteRule 响应改写规则
+// 匹配URL和内容类型后依次执行文本替换、JSON字段修改和HTML片段插入
+type RewriteRule struct {
+	Name             string               `json:"name"`               // 规则名称
+	Enabled          bool                 `json:"enabled"`            // 是否启用
+	URLPattern       string               `json:"url_pattern"`        // 响应URL正则，为空匹配所有URL
+	ContentTypes     []string             `json:"content_types"`      // 内容类型，支持text/*形式的通配，为空不限制
+	Replacements     []RewriteReplacement `json:"replacements"`       // 文本替换
+	JSONEdits        []RewriteJSONEdit    `json:"json_edits"`         // JSON字段修改，仅对JSON内容生效
+	InsertBeforeHead string               `json:"insert_before_head"` // 插入到</head>之前的HTML片段
+	InsertBeforeBody string               `json:"insert_before_body"` // 插入到</body>之前的HTML片段
+}
+
+// RewriteReplacement 文本替换
+type RewriteReplacement struct {
+	Find    string `json:"find"`    // 查找的文本或正则
+	Replace string `json:"replace"` // 替换内容，正则替换时支持$1形式的引用
+	Regex   bool   `json:"regex"`   // find是否为正则
+}
+
+// RewriteJSONEdit JSON字段修改
+type RewriteJSONEdit struct {
+	Path   string      `json:"path"`   // 字段路径，如data.menus[2].visible
+	Action string      `json:"action"` // set/delete，默认set
+	Value  interface{} `json:"value"`  // set时设置的值
+}
+
+// AccountDetectionRule 账户识别规则
+// 从匹配的响应、Cookie或页面元素中提取账户，提取结果可再用正则截取
+type AccountDetectionRule struct {
+	Name        string `json:"name"`         // 规则名称
+	Source      string `json:"source"`       // 提取来源: response/cookie/dom
+	HostPattern string `json:"host_pattern"` // 页面域名，支持子域名匹配，为空不限制
+	URLPattern  string `json:"url_pattern"`  // 响应URL正则，仅response来源使用
+	JSONPath    string `json:"json_path"`    // 响应JSON中账户字段的路径，如data.user.email
+	Regex       string `json:"regex"`        // 提取账户的正则，有捕获组时取第一个捕获组
+	CookieName  string `json:"cookie_name"`  // Cookie名称，仅cookie来源使用
+	Selector    string `json:"selector"`     // CSS选择器，仅dom来源使用
+	Attribute   string `json:"attribute"`    // 元素属性名，为空时取元素文本
+}
+
+// HeaderRule 请求头改写规则，请求头名称不区分大小写
+type HeaderRule struct {
+	HostPattern string `json:"host_pattern"` // 请求域名，支持子域名匹配，为空匹配所有请求
+	Action      string `json:"action"`       // set/append/remove/replace
+	Name        string `json:"name"`         // 请求头名称
+	Value       string `json:"value"`        // set/append的值，replace的替换内容（支持$1形式的引用）
+	Pattern     string `json:"pattern"`      // replace使用的正则
+}
+
+// ProxyHealthCheck 代理健康检查配置
+type ProxyHealthCheck struct {
+	Enabled          bool   `json:"enabled"`           // 是否启用后台健康检查，默认关闭，启用后会定期通过每个代理访问探测地址
+	ProbeURL         string `json:"probe_url"`         // 通过代理访问的探测地址，返回2xx/3xx视为可用
+	IntervalSec      int    `json:"interval_sec"`      // 检查间隔（秒），默认60
+	TimeoutSec       int    `json:"timeout_sec"`       // 单次探测超时（秒），默认10
+	FailureThreshold int    `json:"failure_threshold"` // 连续失败多少次后视为不可用，默认2
+}
+
+// ProxyServer 代理池中的代理
+type ProxyServer struct {
+	Name     string `json:"name"`     // 代理名称，用于日志和状态展示
+	Type     string `json:"type"`     // http/https/socks5/pac，默认http
+	Url      string `json:"url"`      // 代理地址host:port，pac类型为PAC脚本URL
+	Username string `json:"username"` // 代理认证用户名
+	Password string `json:"password"` // 代理认证密码
+}
+
+// CommandLineSwitch Chromium命令行开关
+type CommandLineSwitch struct {
+	Name  string `json:"name"`  // 开关名称，如--disable-extensions
+	Value string `json:"value"` // 开关值，无值开关留空
+}
+
+// CertificatePin 域名的SPKI固定配置
+type CertificatePin struct {
+	Host       string   `json:"host"`        // 域名，支持子域名匹配
+	SPKISha256 []string `json:"spki_sha256"` // 证书链中任一公钥的SHA256（base64）
 }
 
 // WhitelistConfig 网站白名单配置结构
@@ -135,6 +272,26 @@ type WhitelistConfig struct {
 	NotAllowedDomains []string `json:"not_allowed_domains"` // 不允许访问的域名列表
 	BlockedMessage    string   `json:"blocked_message"`     // 访问被阻止时的提示消息
 	RedirectBlockedTo string   `json:"redirect_blocked_to"` // 被阻止时重定向的URL
+	BlockedAction     string   `json:"blocked_action"`      // 默认的阻止处理方式: redirect/page/cancel
+	OutOfScheduleMsg  string   `json:"out_of_schedule_msg"` // 不在允许访问时间段内的提示消息
+
+	Rules []WhitelistRule `json:"rules"` // 按域名单独配置的规则
+}
+
+// WhitelistRule 单个域名的访问规则
+type WhitelistRule struct {
+	Domain        string `json:"domain"`         // 规则匹配的域名，支持子域名匹配
+	BlockedAction string `json:"blocked_action"` // 该域名被阻止时的处理方式，为空使用默认值
+
+	Schedule *AccessSchedule `json:"schedule,omitempty"` // 允许访问的时间段，为空不限制
+}
+
+// AccessSchedule 允许访问的时间段配置
+type AccessSchedule struct {
+	Days           []string `json:"days"`            // 允许访问的星期，如mon、tue或monday，为空表示每天
+	TimeRanges     []string `json:"time_ranges"`     // 允许访问的时间范围，如09:00-18:00，支持跨零点，起止不能相同，全天使用00:00-24:00
+	Timezone       string   `json:"timezone"`        // 时区，如Asia/Shanghai，为空使用本地时区
+	BlockedMessage string   `json:"blocked_message"` // 不在时间段内的提示消息，为空使用全局配置
 }
 
 // AppConfig 应用程序全局配置
@@ -150,6 +307,18 @@ type ExternalConfig struct {
 		Long string `json:"long"`
 		Pro  string `json:"pro"`
 	} `json:"aegisAddr"`
+
+	Audit AuditConfig `json:"audit"` // 访问审计日志配置
+}
+
+// AuditConfig 访问审计日志配置
+type AuditConfig struct {
+	Dir              string `json:"dir"`                // 审计日志目录
+	MaxSizeMB        int64  `json:"max_size_mb"`        // 单个日志文件大小上限（MB），超出后轮转
+	RetentionDays    int    `json:"retention_days"`     // 轮转后日志文件保留天数，0表示不清理
+	Upload           bool   `json:"upload"`             // 是否批量上报到aegis
+	BatchSize        int    `json:"batch_size"`         // 每批上报的记录数
+	FlushIntervalSec int    `json:"flush_interval_sec"` // 上报间隔（秒）
 }
 
 // AllowedEmailsConfig 允许登陆的邮箱列表
@@ -0,0 +1,207 @@
+// Package cookies Cookie导入导出
+// 在JSON和Netscape cookies.txt格式之间转换Cookie，并按域名过滤
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导入导出格式
+const (
+	FormatJSON     = "json"
+	FormatNetscape = "netscape"
+)
+
+// SameSite取值
+const (
+	SameSiteUnspecified   = "unspecified"
+	SameSiteNoRestriction = "no_restriction"
+	SameSiteLax           = "lax"
+	SameSiteStrict        = "strict"
+)
+
+// Priority取值
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+// netscapeHttpOnlyPrefix Netscape格式中HttpOnly Cookie的行前缀（curl等工具的约定）
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// Cookie 导入导出的Cookie
+type Cookie struct {
+	Domain   string `json:"domain"` // 以"."开头时对子域名生效，否则仅对该主机生效
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"http_only"`
+	Expires  int64  `json:"expires,omitempty"`   // 过期时间（Unix秒），0表示会话Cookie
+	SameSite string `json:"same_site,omitempty"` // unspecified/no_restriction/lax/strict
+	Priority string `json:"priority,omitempty"`  // low/medium/high
+}
+
+// Host 获取Cookie所属的主机名（去掉开头的"."）
+func (c Cookie) Host() string {
+	return strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+}
+
+// HostOnly 是否仅对设置该Cookie的主机生效
+func (c Cookie) HostOnly() bool {
+	return !strings.HasPrefix(c.Domain, ".")
+}
+
+// URL 获取设置Cookie时使用的地址
+func (c Cookie) URL() string {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	path := c.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return scheme + "://" + c.Host() + path
+}
+
+// Expired 在指定时间是否已过期，会话Cookie不会过期
+func (c Cookie) Expired(now time.Time) bool {
+	return c.Expires > 0 && c.Expires <= now.Unix()
+}
+
+// Filter 保留allowed返回true的Cookie
+func Filter(cookies []Cookie, allowed func(Cookie) bool) []Cookie {
+	result := make([]Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		if allowed(cookie) {
+			result = append(result, cookie)
+		}
+	}
+	return result
+}
+
+// Marshal 将Cookie编码为指定格式
+func Marshal(cookies []Cookie, format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case FormatJSON, "":
+		if cookies == nil {
+			cookies = []Cookie{}
+		}
+		return json.MarshalIndent(cookies, "", "  ")
+	case FormatNetscape:
+		return marshalNetscape(cookies), nil
+	}
+	return nil, fmt.Errorf("不支持的Cookie格式: %s", format)
+}
+
+// Unmarshal 解析指定格式的Cookie，format为空时按内容自动识别
+func Unmarshal(data []byte, format string) ([]Cookie, error) {
+	if format == "" {
+		format = DetectFormat(data)
+	}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		var cookies []Cookie
+		if err := json.Unmarshal(data, &cookies); err != nil {
+			return nil, fmt.Errorf("解析JSON Cookie失败: %v", err)
+		}
+		for i, cookie := range cookies {
+			if cookie.Name == "" || cookie.Domain == "" {
+				return nil, fmt.Errorf("第%d个Cookie缺少名称或域名", i+1)
+			}
+			if cookies[i].Path == "" {
+				cookies[i].Path = "/"
+			}
+		}
+		return cookies, nil
+	case FormatNetscape:
+		return unmarshalNetscape(data)
+	}
+	return nil, fmt.Errorf("不支持的Cookie格式: %s", format)
+}
+
+// DetectFormat 按内容识别Cookie格式，以"["开头的为JSON，否则为Netscape
+func DetectFormat(data []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return FormatJSON
+	}
+	return FormatNetscape
+}
+
+// marshalNetscape 编码为Netscape cookies.txt格式：
+// domain、includeSubdomains、path、secure、expires、name、value，以制表符分隔
+func marshalNetscape(cookies []Cookie) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Netscape HTTP Cookie File\n")
+	for _, cookie := range cookies {
+		if cookie.HttpOnly {
+			buf.WriteString(netscapeHttpOnlyPrefix)
+		}
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			cookie.Domain, netscapeBool(!cookie.HostOnly()), cookie.Path, netscapeBool(cookie.Secure),
+			cookie.Expires, cookie.Name, cookie.Value)
+	}
+	return buf.Bytes()
+}
+
+// unmarshalNetscape 解析Netscape cookies.txt格式，忽略空行和注释
+func unmarshalNetscape(data []byte) ([]Cookie, error) {
+	var cookies []Cookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, netscapeHttpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, netscapeHttpOnlyPrefix)
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// 值为空时部分工具省略最后一列
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("第%d行格式错误: 应为7列，实际为%d列", lineNo, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行过期时间错误: %v", lineNo, err)
+		}
+		domain := fields[0]
+		if strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+		cookies = append(cookies, Cookie{
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Expires:  expires,
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// netscapeBool Netscape格式的布尔值
+func netscapeBool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,66 @@
+package cookies
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var sample = []Cookie{
+	{Domain: ".oceanengine.com", Name: "sessionid", Value: "abc", Path: "/", Secure: true, HttpOnly: true, Expires: 1893456000},
+	{Domain: "ad.oceanengine.com", Name: "csrftoken", Value: "x=y", Path: "/pages", Expires: 0},
+	{Domain: ".example.com", Name: "empty", Value: "", Path: "/"},
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatNetscape} {
+		data, err := Marshal(sample, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := DetectFormat(data); got != format {
+			t.Errorf("%s: 识别格式 = %s", format, got)
+		}
+		got, err := Unmarshal(data, "")
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, sample) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", format, got, sample)
+		}
+	}
+}
+
+func TestUnmarshal_Netscape(t *testing.T) {
+	data := "# Netscape HTTP Cookie File\r\n" +
+		"\r\n" +
+		"example.com\tTRUE\t/\tFALSE\t0\ta\t1\r\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/app\tTRUE\t1700000000\tb\t2\r\n"
+	got, err := Unmarshal([]byte(data), FormatNetscape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cookie{
+		{Domain: ".example.com", Name: "a", Value: "1", Path: "/"},
+		{Domain: "www.example.com", Name: "b", Value: "2", Path: "/app", Secure: true, HttpOnly: true, Expires: 1700000000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+	if got[1].URL() != "https://www.example.com/app" || !got[1].HostOnly() || got[0].HostOnly() {
+		t.Errorf("URL/HostOnly错误: %s", got[1].URL())
+	}
+
+	for _, invalid := range []string{"example.com\tTRUE\t/\tFALSE\n", "example.com\tTRUE\t/\tFALSE\tnever\ta\t1\n"} {
+		if _, err := Unmarshal([]byte(invalid), FormatNetscape); err == nil {
+			t.Errorf("%q: 格式错误应返回错误", invalid)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	got := Filter(sample, func(cookie Cookie) bool { return strings.HasSuffix(cookie.Host(), "oceanengine.com") })
+	if len(got) != 2 {
+		t.Errorf("过滤后数量 = %d, want 2", len(got))
+	}
+}
@@ -0,0 +1,265 @@
+// Package filter 响应内容过滤
+// 跨多次回调缓冲响应内容，按URL规则交给Go代码检查或改写，并按输出缓冲区大小分批写出
+package filter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxBufferSize 默认的最大缓冲大小，超出后不再缓冲，原样输出
+const DefaultMaxBufferSize = 8 * 1024 * 1024
+
+// Response 被过滤的响应信息
+type Response struct {
+	BrowserId       int32
+	PageURL         string // 所在页面的URL
+	URL             string // 响应的URL
+	MimeType        string
+	ContentEncoding string
+	Status          int32
+}
+
+// Handler 检查或改写响应内容
+// 返回nil表示不修改，否则返回改写后的内容
+type Handler func(response *Response, body []byte) []byte
+
+// Rule 响应过滤规则
+type Rule struct {
+	Name    string
+	Match   func(response *Response) bool
+	Handler Handler
+}
+
+// URLRule 创建按URL正则和MIME类型匹配的规则，mimeTypes为空时不限制类型
+func URLRule(name, urlPattern string, mimeTypes []string, handler Handler) (Rule, error) {
+	re, err := regexp.Compile(urlPattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("响应过滤规则URL正则错误: %v", err)
+	}
+	return Rule{
+		Name: name,
+		Match: func(response *Response) bool {
+			return re.MatchString(response.URL) && MatchMimeType(response.MimeType, mimeTypes)
+		},
+		Handler: handler,
+	}, nil
+}
+
+// MatchMimeType 检查MIME类型是否在列表中，支持text/*形式的通配，列表为空时始终匹配
+func MatchMimeType(mimeType string, mimeTypes []string) bool {
+	if len(mimeTypes) == 0 {
+		return true
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	for _, item := range mimeTypes {
+		item = strings.ToLower(item)
+		if item == mimeType || (strings.HasSuffix(item, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(item, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry 响应过滤规则集合
+type Registry struct {
+	lock  sync.RWMutex
+	rules []Rule
+}
+
+// NewRegistry 创建新的响应过滤规则集合实例
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 添加规则，同名规则会被替换
+func (r *Registry) Register(rule Rule) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := range r.rules {
+		if r.rules[i].Name == rule.Name {
+			r.rules[i] = rule
+			return
+		}
+	}
+	r.rules = append(r.rules, rule)
+}
+
+// Unregister 删除规则
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := range r.rules {
+		if r.rules[i].Name == name {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return
+		}
+	}
+}
+
+// Match 获取与响应匹配的规则
+func (r *Registry) Match(response *Response) []Rule {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	var result []Rule
+	for _, rule := range r.rules {
+		if rule.Match == nil || rule.Match(response) {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// StreamFilter 单个响应的流式过滤状态
+// Filter按CEF ResponseFilter的约定调用：输入结束时in为nil，返回done为false时会被再次调用
+type StreamFilter struct {
+	response      *Response
+	rules         []Rule
+	maxBufferSize int
+
+	input       bytes.Buffer // 缓冲的输入
+	output      []byte       // 等待写出的输出
+	passthrough bool         // 超出缓冲上限后不再处理，原样输出
+	processed   bool         // 输入结束后已完成处理
+}
+
+// NewStreamFilter 创建新的流式过滤器实例，maxBufferSize不大于0时使用默认值
+func NewStreamFilter(response *Response, rules []Rule, maxBufferSize int) *StreamFilter {
+	if maxBufferSize <= 0 {
+		maxBufferSize = DefaultMaxBufferSize
+	}
+	return &StreamFilter{
+		response:      response,
+		rules:         rules,
+		maxBufferSize: maxBufferSize,
+	}
+}
+
+// Filter 处理一次过滤回调
+// 返回读取的输入字节数、写入out的字节数，以及是否已完成全部输出
+func (f *StreamFilter) Filter(in []byte, out []byte) (read, written int, done bool) {
+	written = f.drain(out)
+
+	if in == nil {
+		// 输入结束，处理缓冲的内容后分批写出
+		if !f.processed {
+			f.processed = true
+			if f.passthrough {
+				f.output = append(f.output, f.input.Bytes()...)
+			} else {
+				f.output = append(f.output, f.process(f.input.Bytes())...)
+			}
+			f.input.Reset()
+			written += f.drain(out[written:])
+		}
+		return 0, written, len(f.output) == 0
+	}
+
+	if !f.passthrough && f.input.Len()+len(in) > f.maxBufferSize {
+		// 超出缓冲上限，放弃处理，已缓冲的内容先写出
+		fmt.Printf("响应内容超出缓冲上限，跳过过滤: %s\n", f.response.URL)
+		f.passthrough = true
+		f.output = append(f.output, f.input.Bytes()...)
+		f.input.Reset()
+		written += f.drain(out[written:])
+	}
+
+	if f.passthrough {
+		// 待写出的内容全部写出后才直接复制输入，未读取的输入由调用方下次再传入
+		if len(f.output) == 0 {
+			read = copy(out[written:], in)
+			written += read
+		}
+		return read, written, false
+	}
+
+	f.input.Write(in)
+	return len(in), written, false
+}
+
+// drain 将待写出的输出复制到out
+func (f *StreamFilter) drain(out []byte) int {
+	n := copy(out, f.output)
+	f.output = f.output[n:]
+	return n
+}
+
+// process 解码内容后依次交给匹配的规则处理，内容被修改时按原编码重新编码
+func (f *StreamFilter) process(body []byte) []byte {
+	decoded, gzipped, err := decodeBody(body, f.response.ContentEncoding)
+	if err != nil {
+		fmt.Printf("响应内容解码失败 - URL: %s, 错误: %v\n", f.response.URL, err)
+		return body
+	}
+
+	modified := false
+	for _, rule := range f.rules {
+		if result := f.runHandler(rule, decoded); result != nil {
+			decoded = result
+			modified = true
+		}
+	}
+	if !modified {
+		return body
+	}
+	if gzipped {
+		encoded, err := encodeGzip(decoded)
+		if err != nil {
+			fmt.Printf("响应内容编码失败 - URL: %s, 错误: %v\n", f.response.URL, err)
+			return body
+		}
+		return encoded
+	}
+	return decoded
+}
+
+// runHandler 执行规则，规则panic时不影响响应输出
+func (f *StreamFilter) runHandler(rule Rule, body []byte) (result []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("响应过滤规则执行失败 - 规则: %s, 错误: %v\n", rule.Name, r)
+			result = nil
+		}
+	}()
+	return rule.Handler(f.response, body)
+}
+
+// decodeBody 解码gzip内容
+// 网络层通常已按Content-Encoding解码，这里以gzip文件头判断内容是否仍被压缩
+func decodeBody(body []byte, contentEncoding string) ([]byte, bool, error) {
+	isGzip := len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b
+	if !isGzip {
+		return body, false, nil
+	}
+	if contentEncoding != "" && !strings.EqualFold(contentEncoding, "gzip") {
+		return body, false, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, err
+	}
+	return decoded, true, nil
+}
+
+// encodeGzip 使用gzip压缩内容
+func encodeGzip(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
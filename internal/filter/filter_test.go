@@ -0,0 +1,270 @@
+package filter
+
+import (
+	"bytes"
+	"cef/internal/config"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+// driveFilter 模拟CEF调用ResponseFilter：按chunkSize分批传入输入，输出缓冲区大小为outSize
+// 未被读取的输入会在下一次调用时与新数据一起传入，输入结束后以nil调用直到完成
+func driveFilter(t *testing.T, f *StreamFilter, body []byte, chunkSize, outSize int) []byte {
+	t.Helper()
+	var result []byte
+	var pending []byte
+	out := make([]byte, outSize)
+	for calls := 0; ; calls++ {
+		if calls > 100000 {
+			t.Fatal("过滤器未结束")
+		}
+		if len(pending) == 0 && len(body) > 0 {
+			n := chunkSize
+			if n > len(body) {
+				n = len(body)
+			}
+			pending, body = append(pending, body[:n]...), body[n:]
+		}
+		if len(pending) == 0 {
+			read, written, done := f.Filter(nil, out)
+			if read != 0 {
+				t.Fatalf("输入结束后读取了%d字节", read)
+			}
+			if written > outSize {
+				t.Fatalf("写入%d字节超出输出缓冲区%d", written, outSize)
+			}
+			result = append(result, out[:written]...)
+			if done {
+				return result
+			}
+			continue
+		}
+		read, written, done := f.Filter(pending, out)
+		if read > len(pending) {
+			t.Fatalf("读取%d字节超出输入%d", read, len(pending))
+		}
+		if written > outSize {
+			t.Fatalf("写入%d字节超出输出缓冲区%d", written, outSize)
+		}
+		if done {
+			t.Fatal("输入未结束时过滤器返回完成")
+		}
+		result = append(result, out[:written]...)
+		pending = pending[read:]
+	}
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gunzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func replaceRule(old, new string) Rule {
+	return Rule{
+		Name: "replace",
+		Handler: func(response *Response, body []byte) []byte {
+			if !bytes.Contains(body, []byte(old)) {
+				return nil
+			}
+			return bytes.ReplaceAll(body, []byte(old), []byte(new))
+		},
+	}
+}
+
+func TestStreamFilter_Rewrite(t *testing.T) {
+	body := []byte(strings.Repeat(`{"email":"a@example.com","name":"foo"}`, 100))
+	want := bytes.ReplaceAll(body, []byte("foo"), []byte("barbaz"))
+	sizes := []struct{ chunk, out int }{{1, 1}, {7, 3}, {64, 4096}, {len(body), 16}, {4096, 4096}}
+	for _, size := range sizes {
+		f := NewStreamFilter(&Response{URL: "https://example.com/user-info"}, []Rule{replaceRule("foo", "barbaz")}, 0)
+		if got := driveFilter(t, f, body, size.chunk, size.out); !bytes.Equal(got, want) {
+			t.Errorf("chunk=%d out=%d: 输出不一致，长度%d，期望%d", size.chunk, size.out, len(got), len(want))
+		}
+	}
+}
+
+func TestStreamFilter_InspectAcrossChunks(t *testing.T) {
+	body := []byte(`{"data":{"email":"someone@example.com"}}`)
+	var seen []byte
+	rule := Rule{
+		Name: "inspect",
+		Handler: func(response *Response, body []byte) []byte {
+			seen = append([]byte(nil), body...)
+			return nil
+		},
+	}
+	f := NewStreamFilter(&Response{}, []Rule{rule}, 0)
+	if got := driveFilter(t, f, body, 5, 8); !bytes.Equal(got, body) {
+		t.Errorf("未修改的内容应原样输出: %q", got)
+	}
+	if !bytes.Equal(seen, body) {
+		t.Errorf("规则应收到完整内容: %q", seen)
+	}
+}
+
+func TestStreamFilter_Gzip(t *testing.T) {
+	plain := []byte(strings.Repeat("<html><head></head><body>foo</body></html>", 50))
+	body := gzipBytes(t, plain)
+	f := NewStreamFilter(&Response{ContentEncoding: "gzip"}, []Rule{replaceRule("foo", "bar")}, 0)
+	got := driveFilter(t, f, body, 13, 10)
+	if want := bytes.ReplaceAll(plain, []byte("foo"), []byte("bar")); !bytes.Equal(gunzipBytes(t, got), want) {
+		t.Error("gzip内容改写后应重新压缩")
+	}
+
+	// 未修改时输出原始的压缩内容
+	f = NewStreamFilter(&Response{}, []Rule{replaceRule("missing", "bar")}, 0)
+	if got := driveFilter(t, f, body, 13, 10); !bytes.Equal(got, body) {
+		t.Error("gzip内容未修改时应原样输出")
+	}
+}
+
+func TestStreamFilter_Passthrough(t *testing.T) {
+	body := []byte(strings.Repeat("foo-", 1000))
+	called := false
+	rule := Rule{
+		Name: "never",
+		Handler: func(response *Response, body []byte) []byte {
+			called = true
+			return []byte("changed")
+		},
+	}
+	f := NewStreamFilter(&Response{}, []Rule{rule}, 100)
+	if got := driveFilter(t, f, body, 30, 7); !bytes.Equal(got, body) {
+		t.Errorf("超出缓冲上限时应原样输出，长度%d，期望%d", len(got), len(body))
+	}
+	if called {
+		t.Error("超出缓冲上限时不应执行规则")
+	}
+}
+
+func TestStreamFilter_EmptyBody(t *testing.T) {
+	f := NewStreamFilter(&Response{}, []Rule{replaceRule("foo", "bar")}, 0)
+	if got := driveFilter(t, f, nil, 10, 10); len(got) != 0 {
+		t.Errorf("空内容应输出为空: %q", got)
+	}
+}
+
+func TestStreamFilter_HandlerPanic(t *testing.T) {
+	body := []byte("hello")
+	rule := Rule{
+		Name: "panic",
+		Handler: func(response *Response, body []byte) []byte {
+			panic("boom")
+		},
+	}
+	f := NewStreamFilter(&Response{}, []Rule{rule, replaceRule("hello", "world")}, 0)
+	if got := driveFilter(t, f, body, 2, 2); string(got) != "world" {
+		t.Errorf("规则panic后应继续执行其他规则: %q", got)
+	}
+}
+
+func TestURLRule(t *testing.T) {
+	rule, err := URLRule("json", `/user-info`, []string{"application/json", "text/*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url      string
+		mimeType string
+		want     bool
+	}{
+		{url: "https://a.com/api/user-info", mimeType: "application/json", want: true},
+		{url: "https://a.com/api/user-info", mimeType: "application/json; charset=utf-8", want: true},
+		{url: "https://a.com/api/user-info", mimeType: "text/html", want: true},
+		{url: "https://a.com/api/user-info", mimeType: "image/png", want: false},
+		{url: "https://a.com/api/other", mimeType: "application/json", want: false},
+	}
+	for _, tt := range tests {
+		if got := rule.Match(&Response{URL: tt.url, MimeType: tt.mimeType}); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.url, tt.mimeType, got, tt.want)
+		}
+	}
+	if _, err = URLRule("bad", "(", nil, nil); err == nil {
+		t.Error("错误的正则应返回错误")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Rule{Name: "a"})
+	registry.Register(Rule{Name: "b", Match: func(response *Response) bool { return response.URL == "x" }})
+	registry.Register(Rule{Name: "a", Match: func(response *Response) bool { return false }})
+	if got := registry.Match(&Response{URL: "x"}); len(got) != 1 || got[0].Name != "b" {
+		t.Errorf("同名规则应被替换: %v", got)
+	}
+	registry.Unregister("b")
+	if got := registry.Match(&Response{URL: "x"}); len(got) != 0 {
+		t.Errorf("删除后不应再匹配: %v", got)
+	}
+}
+
+func TestNewRewriteRule(t *testing.T) {
+	applied := 0
+	rule, err := NewRewriteRule(config.RewriteRule{
+		Name:         "menu",
+		URLPattern:   `/menu`,
+		ContentTypes: []string{"application/json", "text/html"},
+		Replacements: []config.RewriteReplacement{
+			{Find: "foo", Replace: "bar"},
+			{Find: `id=(\d+)`, Replace: "id=x$1", Regex: true},
+		},
+		JSONEdits: []config.RewriteJSONEdit{
+			{Path: "data.menus[1]", Action: JSONEditDelete},
+			{Path: "data.banner", Value: "<b>hi</b>"},
+			{Path: "data.missing.field", Value: 1},
+		},
+		InsertBeforeHead: "<style></style>",
+	}, func(name string, response *Response, changes int) {
+		applied += changes
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Match(&Response{URL: "https://a.com/menu", MimeType: "application/json"}) {
+		t.Fatal("规则应匹配URL和内容类型")
+	}
+
+	got := rule.Handler(&Response{}, []byte(`{"data":{"menus":["foo","hide","id=7"],"total":10000000000000001}}`))
+	want := `{"data":{"banner":"<b>hi</b>","menus":["bar","id=x7"],"total":10000000000000001}}`
+	if string(got) != want {
+		t.Errorf("JSON改写结果不一致:\n%s\n%s", got, want)
+	}
+	if applied != 4 {
+		t.Errorf("修改数 = %d, want 4", applied)
+	}
+
+	got = rule.Handler(&Response{}, []byte("<html><HEAD></HEAD><body>foo</body></html>"))
+	if want := "<html><HEAD><style></style></HEAD><body>bar</body></html>"; string(got) != want {
+		t.Errorf("HTML改写结果不一致: %s", got)
+	}
+	if got = rule.Handler(&Response{}, []byte("plain")); got != nil {
+		t.Errorf("未修改时应返回nil: %q", got)
+	}
+
+	if _, err = NewRewriteRule(config.RewriteRule{Name: "bad", Replacements: []config.RewriteReplacement{{Find: "(", Regex: true}}}, nil); err == nil {
+		t.Error("错误的替换正则应返回错误")
+	}
+}
@@ -0,0 +1,184 @@
+// Package filter JSON路径
+// 支持data.list[0].email形式的简单路径，用于读取和修改响应JSON中的字段
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment 路径中的一段，key为空时表示数组下标
+type jsonPathSegment struct {
+	key   string
+	index int
+}
+
+// parseJSONPath 解析JSON路径，允许以$.开头
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil, fmt.Errorf("JSON路径为空")
+	}
+	var segments []jsonPathSegment
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []string
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+			for _, item := range strings.Split(part[i:], "[")[1:] {
+				if !strings.HasSuffix(item, "]") {
+					return nil, fmt.Errorf("JSON路径格式错误: %s", path)
+				}
+				indexes = append(indexes, strings.TrimSuffix(item, "]"))
+			}
+		}
+		if key != "" {
+			segments = append(segments, jsonPathSegment{key: key})
+		}
+		for _, item := range indexes {
+			index, err := strconv.Atoi(item)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("JSON路径下标错误: %s", path)
+			}
+			segments = append(segments, jsonPathSegment{index: index})
+		}
+	}
+	return segments, nil
+}
+
+// JSONPathGet 读取JSON路径对应的值，data为json.Unmarshal得到的对象
+func JSONPathGet(data interface{}, path string) (interface{}, bool) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+	current := data
+	for _, segment := range segments {
+		child, ok := jsonChild(current, segment)
+		if !ok {
+			return nil, false
+		}
+		current = child
+	}
+	return current, true
+}
+
+// JSONPathSet 设置JSON路径对应的值，路径的上级必须已存在，最后一段为对象字段时可新增
+func JSONPathSet(data interface{}, path string, value interface{}) bool {
+	parent, last, ok := jsonParent(data, path)
+	if !ok {
+		return false
+	}
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if last.key == "" {
+			return false
+		}
+		node[last.key] = value
+		return true
+	case []interface{}:
+		if last.key != "" || last.index >= len(node) {
+			return false
+		}
+		node[last.index] = value
+		return true
+	}
+	return false
+}
+
+// JSONPathDelete 删除JSON路径对应的对象字段，数组元素会被删除并保持其余元素顺序
+// 返回修改后的根对象（删除数组元素时上级数组会被替换）
+func JSONPathDelete(data interface{}, path string) (interface{}, bool) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return data, false
+	}
+	parentPath, last := segments[:len(segments)-1], segments[len(segments)-1]
+	parent := data
+	for _, segment := range parentPath {
+		child, ok := jsonChild(parent, segment)
+		if !ok {
+			return data, false
+		}
+		parent = child
+	}
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := node[last.key]; last.key == "" || !ok {
+			return data, false
+		}
+		delete(node, last.key)
+		return data, true
+	case []interface{}:
+		if last.key != "" || last.index >= len(node) {
+			return data, false
+		}
+		removed := append(append([]interface{}(nil), node[:last.index]...), node[last.index+1:]...)
+		if len(parentPath) == 0 {
+			return removed, true
+		}
+		return data, setSegments(data, parentPath, removed)
+	}
+	return data, false
+}
+
+// jsonParent 获取路径最后一段的上级节点
+func jsonParent(data interface{}, path string) (interface{}, jsonPathSegment, bool) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, jsonPathSegment{}, false
+	}
+	parent := data
+	for _, segment := range segments[:len(segments)-1] {
+		child, ok := jsonChild(parent, segment)
+		if !ok {
+			return nil, jsonPathSegment{}, false
+		}
+		parent = child
+	}
+	return parent, segments[len(segments)-1], true
+}
+
+// setSegments 按已解析的路径设置值
+func setSegments(data interface{}, segments []jsonPathSegment, value interface{}) bool {
+	parent := data
+	for _, segment := range segments[:len(segments)-1] {
+		child, ok := jsonChild(parent, segment)
+		if !ok {
+			return false
+		}
+		parent = child
+	}
+	last := segments[len(segments)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last.key] = value
+		return last.key != ""
+	case []interface{}:
+		if last.key != "" || last.index >= len(node) {
+			return false
+		}
+		node[last.index] = value
+		return true
+	}
+	return false
+}
+
+// jsonChild 获取节点的子节点
+func jsonChild(node interface{}, segment jsonPathSegment) (interface{}, bool) {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if segment.key == "" {
+			return nil, false
+		}
+		child, ok := value[segment.key]
+		return child, ok
+	case []interface{}:
+		if segment.key != "" || segment.index >= len(value) {
+			return nil, false
+		}
+		return value[segment.index], true
+	}
+	return nil, false
+}
@@ -0,0 +1,147 @@
+// Package filter 响应改写
+// 将配置的改写规则转换为过滤规则：文本替换、JSON字段修改和HTML片段插入
+package filter
+
+import (
+	"bytes"
+	"cef/internal/config"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// JSON字段修改方式
+const (
+	JSONEditSet    = "set"
+	JSONEditDelete = "delete"
+)
+
+// RewriteApplied 改写规则修改响应后的回调，changes为本次生效的修改数
+type RewriteApplied func(rule string, response *Response, changes int)
+
+// rewriteReplacement 已编译的文本替换
+type rewriteReplacement struct {
+	find    []byte
+	replace []byte
+	re      *regexp.Regexp
+}
+
+// NewRewriteRule 根据配置创建响应改写规则，正则在创建时编译
+func NewRewriteRule(cfg config.RewriteRule, onApplied RewriteApplied) (Rule, error) {
+	replacements := make([]rewriteReplacement, 0, len(cfg.Replacements))
+	for _, item := range cfg.Replacements {
+		if item.Find == "" {
+			continue
+		}
+		replacement := rewriteReplacement{find: []byte(item.Find), replace: []byte(item.Replace)}
+		if item.Regex {
+			re, err := regexp.Compile(item.Find)
+			if err != nil {
+				return Rule{}, fmt.Errorf("响应改写规则%s替换正则错误: %v", cfg.Name, err)
+			}
+			replacement.re = re
+		}
+		replacements = append(replacements, replacement)
+	}
+	for _, edit := range cfg.JSONEdits {
+		if _, err := parseJSONPath(edit.Path); err != nil {
+			return Rule{}, fmt.Errorf("响应改写规则%s的%v", cfg.Name, err)
+		}
+		if edit.Action != "" && edit.Action != JSONEditSet && edit.Action != JSONEditDelete {
+			return Rule{}, fmt.Errorf("响应改写规则%s不支持的JSON修改方式: %s", cfg.Name, edit.Action)
+		}
+	}
+
+	handler := func(response *Response, body []byte) []byte {
+		changes := 0
+		for _, replacement := range replacements {
+			body, changes = replacement.apply(body, changes)
+		}
+		if len(cfg.JSONEdits) > 0 {
+			body, changes = applyJSONEdits(body, cfg.JSONEdits, changes)
+		}
+		body, changes = insertBefore(body, "</head>", cfg.InsertBeforeHead, changes)
+		body, changes = insertBefore(body, "</body>", cfg.InsertBeforeBody, changes)
+		if changes == 0 {
+			return nil
+		}
+		if onApplied != nil {
+			onApplied(cfg.Name, response, changes)
+		}
+		return body
+	}
+	return URLRule(cfg.Name, cfg.URLPattern, cfg.ContentTypes, handler)
+}
+
+// apply 执行文本替换，返回替换后的内容和累计修改数
+func (r rewriteReplacement) apply(body []byte, changes int) ([]byte, int) {
+	if r.re != nil {
+		if !r.re.Match(body) {
+			return body, changes
+		}
+		return r.re.ReplaceAll(body, r.replace), changes + 1
+	}
+	if !bytes.Contains(body, r.find) {
+		return body, changes
+	}
+	return bytes.ReplaceAll(body, r.find, r.replace), changes + 1
+}
+
+// applyJSONEdits 修改JSON字段，内容不是JSON或没有字段被修改时返回原内容
+func applyJSONEdits(body []byte, edits []config.RewriteJSONEdit, changes int) ([]byte, int) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return body, changes
+	}
+	edited := 0
+	for _, edit := range edits {
+		var ok bool
+		if edit.Action == JSONEditDelete {
+			data, ok = JSONPathDelete(data, edit.Path)
+		} else {
+			ok = JSONPathSet(data, edit.Path, edit.Value)
+		}
+		if ok {
+			edited++
+		}
+	}
+	if edited == 0 {
+		return body, changes
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		fmt.Printf("响应改写JSON编码失败: %v\n", err)
+		return body, changes
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), changes + edited
+}
+
+// insertBefore 在最后一个标签之前插入片段，标签大小写不敏感
+func insertBefore(body []byte, tag, snippet string, changes int) ([]byte, int) {
+	if snippet == "" {
+		return body, changes
+	}
+	index := lastIndexFold(body, []byte(tag))
+	if index < 0 {
+		return body, changes
+	}
+	result := make([]byte, 0, len(body)+len(snippet))
+	result = append(result, body[:index]...)
+	result = append(result, snippet...)
+	result = append(result, body[index:]...)
+	return result, changes + 1
+}
+
+// lastIndexFold 大小写不敏感地查找最后一次出现的位置
+func lastIndexFold(body, tag []byte) int {
+	for i := len(body) - len(tag); i >= 0; i-- {
+		if bytes.EqualFold(body[i:i+len(tag)], tag) {
+			return i
+		}
+	}
+	return -1
+}
@@ -4,14 +4,20 @@ package fingerprint
 
 import (
 	"cef/internal/config"
+	"cef/internal/headers"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 // Generator 指纹脚本生成器
 type Generator struct {
 	browserConfig             func(...string) *config.BrowserConfig
 	allowedEmailsConfigLoader func() *config.AllowedEmailsConfig
+
+	lock  sync.Mutex
+	cache map[string]map[string]string // 账户 -> 脚本类型 -> 已生成的脚本
 }
 
 // NewGenerator 创建新的脚本生成器实例
@@ -19,11 +25,47 @@ func NewGenerator(browserConfig func(...string) *config.BrowserConfig, allowedEm
 	return &Generator{
 		browserConfig:             browserConfig,
 		allowedEmailsConfigLoader: allowedEmailsConfigLoader,
+		cache:                     make(map[string]map[string]string),
+	}
+}
+
+// Invalidate 清除账户已生成的脚本缓存，账户的配置变化后下次注入时重新生成
+func (g *Generator) Invalidate(account string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.cache, account)
+}
+
+// cached 获取缓存的脚本，不存在时生成并缓存
+func (g *Generator) cached(kind string, generate func(account ...string) string, account ...string) string {
+	key := ""
+	if len(account) > 0 {
+		key = account[0]
+	}
+	g.lock.Lock()
+	if script, ok := g.cache[key][kind]; ok {
+		g.lock.Unlock()
+		return script
 	}
+	g.lock.Unlock()
+
+	script := generate(account...)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.cache[key] == nil {
+		g.cache[key] = make(map[string]string)
+	}
+	g.cache[key][kind] = script
+	return script
 }
 
 // GenerateBasicScript 根据配置文件参数创建完整的浏览器指纹伪装脚本
 func (g *Generator) GenerateBasicScript(account ...string) string {
+	return g.cached("basic", g.generateBasicScript, account...)
+}
+
+// generateBasicScript 生成基础指纹伪装脚本
+func (g *Generator) generateBasicScript(account ...string) string {
 	// 从配置中提取主语言
 	primaryLanguage := g.extractPrimaryLanguage(account...)
 	// 从配置中生成语言数组
@@ -55,6 +97,11 @@ if (!window.__fingerprintConfig) {
 
 // GenerateAdvancedScript 创建高级指纹伪装脚本（Canvas、WebGL、音频等）
 func (g *Generator) GenerateAdvancedScript(account ...string) string {
+	return g.cached("advanced", g.generateAdvancedScript, account...)
+}
+
+// generateAdvancedScript 生成高级指纹伪装脚本
+func (g *Generator) generateAdvancedScript(account ...string) string {
 	return `
 (function() {
     // ========== Canvas指纹伪装 ==========
@@ -512,6 +559,212 @@ func (g *Generator) GenerateLoginScript() string {
 })();`
 }
 
+// GenerateWorkerScript 创建Worker中使用的指纹伪装脚本，由响应过滤或页面中的Worker注入脚本插入到Worker脚本前
+// Worker（包括共享Worker和Service Worker）没有DOM，只覆盖WorkerNavigator上与页面一致的属性，重复执行时只生效一次
+func (g *Generator) GenerateWorkerScript(account ...string) string {
+	return g.cached("worker", g.generateWorkerScript, account...)
+}
+
+// generateWorkerScript 生成Worker指纹伪装脚本
+func (g *Generator) generateWorkerScript(account ...string) string {
+	browserConfig := g.browserConfig(account...)
+	workerConfig, _ := json.Marshal(map[string]interface{}{
+		"userAgent":           browserConfig.Basic.UserAgent,
+		"platform":            browserConfig.Basic.Platform,
+		"uaPlatform":          headers.PlatformFromUserAgent(browserConfig.Basic.UserAgent),
+		"language":            g.extractPrimaryLanguage(account...),
+		"languages":           g.languages(account...),
+		"hardwareConcurrency": browserConfig.Hardware.CPUCores,
+		"deviceMemory":        browserConfig.Hardware.DeviceMemory,
+	})
+	return `
+(function() {
+    if (self.__fingerprintWorkerPatched) {
+        return;
+    }
+    Object.defineProperty(self, '__fingerprintWorkerPatched', { value: true });
+    const config = ` + string(workerConfig) + `;
+    const proto = Object.getPrototypeOf(self.navigator);
+    const define = function(name, value) {
+        if (value === '' || value === 0 || value === null) {
+            return;
+        }
+        try {
+            Object.defineProperty(proto, name, {
+                get: function() { return value; },
+                configurable: true
+            });
+        } catch (e) {}
+    };
+    define('userAgent', config.userAgent);
+    define('appVersion', config.userAgent.replace(/^Mozilla\//, ''));
+    define('platform', config.platform);
+    define('language', config.language);
+    define('languages', Object.freeze(config.languages.slice()));
+    define('hardwareConcurrency', config.hardwareConcurrency);
+    if ('deviceMemory' in proto) {
+        define('deviceMemory', config.deviceMemory);
+    }
+    const uaData = self.navigator.userAgentData;
+    if (uaData) {
+        define('userAgentData', {
+            brands: uaData.brands,
+            mobile: uaData.mobile,
+            platform: config.uaPlatform,
+            getHighEntropyValues: function(hints) {
+                return uaData.getHighEntropyValues(hints).then(function(values) {
+                    return Object.assign({}, values, { platform: config.uaPlatform });
+                });
+            },
+            toJSON: function() {
+                return { brands: uaData.brands, mobile: uaData.mobile, platform: config.uaPlatform };
+            }
+        });
+    }
+})();
+`
+}
+
+// GenerateWorkerHookScript 创建页面中使用的Worker注入脚本
+// blob:和data:地址的Worker、SharedWorker不经过网络请求，响应过滤无法插入伪装脚本，由该脚本包装构造函数：
+// blob:地址拼接伪装脚本和原Blob生成新的blob:地址，data:地址解码后拼接伪装脚本生成新的data:地址（保持不透明源）；
+// 网络地址不做处理，由响应过滤覆盖。同一文档中相同地址的SharedWorker复用包装后的地址，不同文档之间不共享
+func (g *Generator) GenerateWorkerHookScript(account ...string) string {
+	return g.cached("worker_hook", g.generateWorkerHookScript, account...)
+}
+
+// generateWorkerHookScript 生成Worker注入脚本
+func (g *Generator) generateWorkerHookScript(account ...string) string {
+	workerScript, _ := json.Marshal(g.GenerateWorkerScript(account...))
+	return `
+(function() {
+    if (window.__fingerprintWorkerHooked) {
+        return;
+    }
+    Object.defineProperty(window, '__fingerprintWorkerHooked', { value: true });
+    const workerScript = ` + string(workerScript) + `;
+    const NativeURL = window.URL;
+    const nativeCreateObjectURL = NativeURL.createObjectURL;
+    const nativeRevokeObjectURL = NativeURL.revokeObjectURL;
+    const blobs = new Map();          // 页面创建的blob:地址 -> Blob
+    const sharedWrapped = new Map();  // SharedWorker的原地址 -> 包装后的地址
+
+    const disguise = function(target, native) {
+        Object.defineProperty(target, 'toString', {
+            value: function() { return native.toString(); }
+        });
+        return target;
+    };
+    NativeURL.createObjectURL = disguise(function createObjectURL(object) {
+        const url = nativeCreateObjectURL.apply(this, arguments);
+        if (object instanceof Blob) {
+            blobs.set(url, object);
+        }
+        return url;
+    }, nativeCreateObjectURL);
+    NativeURL.revokeObjectURL = disguise(function revokeObjectURL(url) {
+        const key = String(url);
+        blobs.delete(key);
+        if (sharedWrapped.has(key)) {
+            nativeRevokeObjectURL.call(NativeURL, sharedWrapped.get(key));
+            sharedWrapped.delete(key);
+        }
+        return nativeRevokeObjectURL.apply(this, arguments);
+    }, nativeRevokeObjectURL);
+
+    // 解码data:地址的脚本内容，无法解码时返回null
+    const decodeDataURL = function(url) {
+        const comma = url.indexOf(',');
+        if (comma < 0) {
+            return null;
+        }
+        try {
+            const body = decodeURIComponent(url.slice(comma + 1));
+            if (!/;base64$/i.test(url.slice(0, comma))) {
+                return body;
+            }
+            const binary = atob(body.replace(/\s/g, ''));
+            const bytes = new Uint8Array(binary.length);
+            for (let i = 0; i < binary.length; i++) {
+                bytes[i] = binary.charCodeAt(i);
+            }
+            return new TextDecoder().decode(bytes);
+        } catch (e) {
+            return null;
+        }
+    };
+
+    // 生成先执行伪装脚本再执行原脚本的地址，不是blob:或data:地址、或无法包装时返回null
+    const wrapScriptURL = function(url, options) {
+        const scheme = url.slice(0, 5).toLowerCase();
+        if (scheme === 'data:') {
+            const source = decodeDataURL(url);
+            return source === null ? null : 'data:text/javascript;charset=utf-8,' + encodeURIComponent(workerScript + '\n' + source);
+        }
+        if (scheme !== 'blob:') {
+            return null;
+        }
+        let parts;
+        if (blobs.has(url)) {
+            parts = [workerScript, '\n', blobs.get(url)];
+        } else {
+            // 其他上下文创建的Blob只能在Worker中按地址加载，原地址需要在Worker启动前保持有效
+            const isModule = options && typeof options === 'object' && options.type === 'module';
+            parts = [workerScript, '\n', isModule ? 'import ' + JSON.stringify(url) + ';' : 'importScripts(' + JSON.stringify(url) + ');'];
+        }
+        return nativeCreateObjectURL.call(NativeURL, new Blob(parts, { type: 'text/javascript' }));
+    };
+
+    const wrapConstructor = function(name) {
+        const NativeWorker = window[name];
+        if (typeof NativeWorker !== 'function') {
+            return;
+        }
+        const shared = name === 'SharedWorker';
+        const WrappedWorker = function(scriptURL, options) {
+            if (!new.target) {
+                return NativeWorker.apply(this, arguments);
+            }
+            const url = String(scriptURL);
+            const cached = shared && sharedWrapped.has(url);
+            const wrappedURL = cached ? sharedWrapped.get(url) : wrapScriptURL(url, options);
+            if (wrappedURL) {
+                const isBlob = wrappedURL.slice(0, 5) === 'blob:';
+                try {
+                    const worker = Reflect.construct(NativeWorker, [wrappedURL].concat(Array.prototype.slice.call(arguments, 1)), new.target);
+                    if (isBlob && shared) {
+                        sharedWrapped.set(url, wrappedURL);
+                    } else if (isBlob) {
+                        // 创建Worker时已解析blob:地址，立即释放
+                        nativeRevokeObjectURL.call(NativeURL, wrappedURL);
+                    }
+                    return worker;
+                } catch (e) {
+                    // 包装后的地址被拒绝时使用原地址创建
+                    if (isBlob && !cached) {
+                        nativeRevokeObjectURL.call(NativeURL, wrappedURL);
+                    }
+                }
+            }
+            return Reflect.construct(NativeWorker, arguments, new.target);
+        };
+        WrappedWorker.prototype = NativeWorker.prototype;
+        Object.setPrototypeOf(WrappedWorker, NativeWorker);
+        Object.defineProperty(WrappedWorker, 'name', { value: name });
+        Object.defineProperty(WrappedWorker, 'length', { value: NativeWorker.length });
+        disguise(WrappedWorker, NativeWorker);
+        Object.defineProperty(window, name, {
+            value: WrappedWorker,
+            writable: true,
+            configurable: true
+        });
+    };
+    wrapConstructor('Worker');
+    wrapConstructor('SharedWorker');
+})();
+`
+}
+
 // UpdateConfig 更新配置（运行时热更新）
 //func (g *Generator) UpdateConfig(newConfig *config.BrowserConfig) {
 //	g.browserConfig = newConfig
@@ -554,8 +807,18 @@ func (g *Generator) extractPrimaryLanguage(account ...string) string {
 
 // generateLanguagesArray 生成语言数组的JavaScript代码
 func (g *Generator) generateLanguagesArray(account ...string) string {
-	languages := strings.Split(g.browserConfig(account...).Basic.AcceptLanguage, ",")
 	var jsArray []string
+	for _, lang := range g.languages(account...) {
+		jsArray = append(jsArray, "'"+lang+"'")
+	}
+
+	return "[" + strings.Join(jsArray, ", ") + "]"
+}
+
+// languages 从AcceptLanguage配置中提取语言标签
+func (g *Generator) languages(account ...string) []string {
+	languages := strings.Split(g.browserConfig(account...).Basic.AcceptLanguage, ",")
+	var result []string
 
 	for _, lang := range languages {
 		// 清理语言标签（移除质量值，如 "zh;q=0.9" -> "zh"）
@@ -563,8 +826,8 @@ func (g *Generator) generateLanguagesArray(account ...string) string {
 		if strings.Contains(lang, ";") {
 			lang = strings.Split(lang, ";")[0]
 		}
-		jsArray = append(jsArray, "'"+lang+"'")
+		result = append(result, lang)
 	}
 
-	return "[" + strings.Join(jsArray, ", ") + "]"
+	return result
 }
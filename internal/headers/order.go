@@ -0,0 +1,96 @@
+// Package headers 请求头顺序
+// 请求头默认保持原顺序，也可以按配置的Chrome版本顺序重新排列，排序结果只取决于请求头本身
+package headers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OrderOriginal 保持请求头的原顺序
+const OrderOriginal = "original"
+
+// OtherHeaders 顺序中表示未列出的请求头的位置，未配置时未列出的请求头排在最后
+const OtherHeaders = "*"
+
+// chrome120Order Chrome 120的请求头顺序，导航、子资源和XHR请求的请求头按此顺序出现
+var chrome120Order = []string{
+	"Host",
+	"Connection",
+	"Content-Length",
+	"Pragma",
+	"Cache-Control",
+	SecChUa,
+	SecChUaMobile,
+	SecChUaFullVersionList,
+	SecChUaArch,
+	SecChUaBitness,
+	SecChUaPlatform,
+	"Upgrade-Insecure-Requests",
+	"Origin",
+	"Content-Type",
+	OtherHeaders,
+	"User-Agent",
+	"Accept",
+	"Sec-Fetch-Site",
+	"Sec-Fetch-Mode",
+	"Sec-Fetch-User",
+	"Sec-Fetch-Dest",
+	"Referer",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Cookie",
+}
+
+// ChromeOrders 各Chrome版本的请求头顺序
+var ChromeOrders = map[string][]string{
+	"chrome_120": chrome120Order,
+	// Chrome 124开始发送Priority请求头，位于最后
+	"chrome_124": append(append([]string(nil), chrome120Order...), "Priority"),
+}
+
+// ResolveOrder 获取配置的请求头顺序，custom不为空时优先使用，返回nil表示保持原顺序
+func ResolveOrder(name string, custom []string) ([]string, error) {
+	if len(custom) > 0 {
+		return custom, nil
+	}
+	if name == "" || name == OrderOriginal {
+		return nil, nil
+	}
+	order, ok := ChromeOrders[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的请求头顺序: %s", name)
+	}
+	return order, nil
+}
+
+// Reorder 按顺序重新排列请求头，order为空时返回原列表
+// 同名请求头和未列出的请求头保持相对顺序，未列出的请求头位于"*"的位置
+func (l List) Reorder(order []string) List {
+	if len(order) == 0 {
+		return l
+	}
+	ranks := make(map[string]int, len(order))
+	other := len(order)
+	for i, name := range order {
+		if name == OtherHeaders {
+			other = i
+			continue
+		}
+		if _, exists := ranks[strings.ToLower(name)]; !exists {
+			ranks[strings.ToLower(name)] = i
+		}
+	}
+	rank := func(name string) int {
+		if i, ok := ranks[strings.ToLower(name)]; ok {
+			return i
+		}
+		return other
+	}
+	result := append(List(nil), l...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return rank(result[i].Name) < rank(result[j].Name)
+	})
+	return result
+}
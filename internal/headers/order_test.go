@@ -0,0 +1,124 @@
+package headers
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func lines(list List) []string {
+	var result []string
+	for _, header := range list {
+		result = append(result, header.Name+": "+header.Value)
+	}
+	return result
+}
+
+func TestList_PreservesOriginalOrder(t *testing.T) {
+	m := newFakeMultiMap(
+		"User-Agent", "ua",
+		"X-B", "1",
+		"Accept", "*/*",
+		"x-a", "2",
+		"Cookie", "a=1",
+		"DNT", "1",
+	)
+	ruleSet, err := NewRuleSet(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := ResolveOrder(OrderOriginal, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"User-Agent", "ua", "X-B", "1", "Accept", "*/*", "x-a", "2", "Cookie", "a=1", "DNT", "1"}
+	for i := 0; i < 20; i++ {
+		ruleSet.Apply("example.com", FromMultiMap(m)).Reorder(order).WriteTo(m)
+		if got := m.pairs(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("第%d次: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestList_ReorderChrome(t *testing.T) {
+	list := List{
+		{"Cookie", "a=1"},
+		{"Accept-Language", "zh-CN"},
+		{"X-Custom", "1"},
+		{"User-Agent", "ua"},
+		{"sec-fetch-dest", "document"},
+		{"Sec-Fetch-Mode", "navigate"},
+		{"Accept", "text/html"},
+		{"Sec-CH-UA-Platform", `"Windows"`},
+		{"X-Another", "2"},
+		{"Sec-Fetch-Site", "none"},
+		{"Sec-CH-UA", `"Chromium";v="120"`},
+		{"Upgrade-Insecure-Requests", "1"},
+		{"Accept-Encoding", "gzip"},
+		{"Sec-CH-UA-Mobile", "?0"},
+		{"Cookie", "b=2"},
+	}
+	order, err := ResolveOrder("chrome_120", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`Sec-CH-UA: "Chromium";v="120"`,
+		"Sec-CH-UA-Mobile: ?0",
+		`Sec-CH-UA-Platform: "Windows"`,
+		"Upgrade-Insecure-Requests: 1",
+		"X-Custom: 1",
+		"X-Another: 2",
+		"User-Agent: ua",
+		"Accept: text/html",
+		"Sec-Fetch-Site: none",
+		"Sec-Fetch-Mode: navigate",
+		"sec-fetch-dest: document",
+		"Accept-Encoding: gzip",
+		"Accept-Language: zh-CN",
+		"Cookie: a=1",
+		"Cookie: b=2",
+	}
+	if got := lines(list.Reorder(order)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	// 各请求头名称不同时，任意输入顺序的输出都一致
+	var unique List
+	var uniqueWant []string
+	for _, header := range list {
+		if header.Name != "X-Another" && header.Value != "b=2" {
+			unique = append(unique, header)
+		}
+	}
+	for _, line := range want {
+		if line != "X-Another: 2" && line != "Cookie: b=2" {
+			uniqueWant = append(uniqueWant, line)
+		}
+	}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		random.Shuffle(len(unique), func(a, b int) { unique[a], unique[b] = unique[b], unique[a] })
+		if got := lines(unique.Reorder(order)); !reflect.DeepEqual(got, uniqueWant) {
+			t.Fatalf("第%d次: got %q", i, got)
+		}
+	}
+}
+
+func TestList_ReorderCustom(t *testing.T) {
+	list := List{{"A", "1"}, {"B", "2"}, {"C", "3"}, {"D", "4"}}
+	order, err := ResolveOrder("chrome_120", []string{"d", OtherHeaders, "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lines(list.Reorder(order)), []string{"D: 4", "A: 1", "C: 3", "B: 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// 未配置"*"时未列出的请求头排在最后
+	if got, want := lines(list.Reorder([]string{"c"})), []string{"C: 3", "A: 1", "B: 2", "D: 4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := ResolveOrder("firefox", nil); err == nil {
+		t.Error("不支持的顺序应返回错误")
+	}
+}
@@ -0,0 +1,337 @@
+// Package headers 请求头配置
+// 按配置的客户端提示和Sec-Fetch值生成请求头：Sec-Fetch按资源类型和发起方推导，
+// 高熵客户端提示只发送给通过Accept-CH请求过的源
+package headers
+
+import (
+	"cef/internal/config"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ResourceType 请求的资源类型，取值与CEF的cef_resource_type_t一致
+type ResourceType int32
+
+// 资源类型
+const (
+	ResourceMainFrame ResourceType = iota
+	ResourceSubFrame
+	ResourceStylesheet
+	ResourceScript
+	ResourceImage
+	ResourceFont
+	ResourceSubResource
+	ResourceObject
+	ResourceMedia
+	ResourceWorker
+	ResourceSharedWorker
+	ResourcePrefetch
+	ResourceFavicon
+	ResourceXHR
+	ResourcePing
+	ResourceServiceWorker
+	ResourceCSPReport
+	ResourcePluginResource
+	_
+	ResourceNavigationPreloadMainFrame
+	ResourceNavigationPreloadSubFrame
+)
+
+// 客户端提示请求头
+const (
+	SecChUa                = "Sec-CH-UA"
+	SecChUaMobile          = "Sec-CH-UA-Mobile"
+	SecChUaPlatform        = "Sec-CH-UA-Platform"
+	SecChUaFullVersionList = "Sec-CH-UA-Full-Version-List"
+	SecChUaArch            = "Sec-CH-UA-Arch"
+	SecChUaBitness         = "Sec-CH-UA-Bitness"
+)
+
+// Header 请求头
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Request 生成请求头所需的请求信息
+type Request struct {
+	URL          string
+	Initiator    string // 发起请求的文档URL，地址栏输入等浏览器发起的导航为空
+	TopLevelURL  string // 顶层页面URL，用于判断是否发送高熵客户端提示
+	ResourceType ResourceType
+}
+
+// IsNavigation 是否为框架导航请求
+func (r Request) IsNavigation() bool {
+	switch r.ResourceType {
+	case ResourceMainFrame, ResourceSubFrame, ResourceNavigationPreloadMainFrame, ResourceNavigationPreloadSubFrame:
+		return true
+	}
+	return false
+}
+
+// Profile 请求头配置
+type Profile struct {
+	config *config.BrowserConfig
+	hints  *ClientHints
+}
+
+// NewProfile 创建新的请求头配置实例，hints为空时不发送高熵客户端提示
+func NewProfile(cfg *config.BrowserConfig, hints *ClientHints) *Profile {
+	return &Profile{config: cfg, hints: hints}
+}
+
+// Headers 生成请求需要设置的请求头
+// 客户端提示和Sec-Fetch只发送给安全来源（https），与Chrome一致
+func (p *Profile) Headers(request Request) []Header {
+	var result []Header
+	add := func(name, value string) {
+		if value != "" {
+			result = append(result, Header{Name: name, Value: value})
+		}
+	}
+	basic := p.config.Basic
+	add("User-Agent", basic.UserAgent)
+	add("Accept-Language", basic.AcceptLanguage)
+
+	if !isSecure(request.URL) {
+		return result
+	}
+
+	headersConfig := p.config.Headers
+	add(SecChUa, headersConfig.SecChUa)
+	add(SecChUaMobile, headersConfig.SecChUaMobile)
+	platform := headersConfig.SecChUaPlatform
+	if platform == "" {
+		platform = `"` + PlatformFromUserAgent(basic.UserAgent) + `"`
+	}
+	add(SecChUaPlatform, platform)
+
+	if p.hints != nil {
+		topLevelURL := request.TopLevelURL
+		if request.ResourceType == ResourceMainFrame || topLevelURL == "" {
+			topLevelURL = request.URL
+		}
+		// 高熵提示只发送给与顶层页面同源的请求，第三方请求需要权限策略委托，这里不发送
+		if sameOrigin(request.URL, topLevelURL) {
+			requested := p.hints.Requested(topLevelURL)
+			if requested[strings.ToLower(SecChUaFullVersionList)] {
+				add(SecChUaFullVersionList, headersConfig.SecChUaFullVersionList)
+			}
+			if requested[strings.ToLower(SecChUaArch)] {
+				add(SecChUaArch, headersConfig.SecChUaArch)
+			}
+			if requested[strings.ToLower(SecChUaBitness)] {
+				add(SecChUaBitness, headersConfig.SecChUaBitness)
+			}
+		}
+	}
+
+	add("Sec-Fetch-Site", p.fetchSite(request))
+	add("Sec-Fetch-Mode", p.fetchMode(request))
+	add("Sec-Fetch-User", fetchUser(request))
+	add("Sec-Fetch-Dest", p.fetchDest(request))
+
+	// 配置的缓存相关请求头取自平台前端的接口请求，只用于XHR/fetch请求
+	if request.ResourceType == ResourceXHR {
+		add("Cache-Control", headersConfig.CacheControl)
+		add("Pragma", headersConfig.Pragma)
+		add("X-Sw-Cache", headersConfig.XSwCache)
+	}
+	return result
+}
+
+// fetchSite 按请求地址和发起方推导Sec-Fetch-Site
+func (p *Profile) fetchSite(request Request) string {
+	if request.Initiator == "" {
+		if request.IsNavigation() {
+			return "none"
+		}
+		return p.config.Headers.SecFetchSite
+	}
+	return FetchSite(request.URL, request.Initiator)
+}
+
+// fetchMode 按资源类型推导Sec-Fetch-Mode，无法推导时使用配置值
+func (p *Profile) fetchMode(request Request) string {
+	switch request.ResourceType {
+	case ResourceMainFrame, ResourceSubFrame, ResourceNavigationPreloadMainFrame, ResourceNavigationPreloadSubFrame:
+		return "navigate"
+	case ResourceStylesheet, ResourceScript, ResourceImage, ResourceFavicon, ResourceMedia,
+		ResourceObject, ResourcePluginResource, ResourcePing, ResourcePrefetch, ResourceCSPReport:
+		return "no-cors"
+	case ResourceFont:
+		return "cors"
+	case ResourceWorker, ResourceSharedWorker, ResourceServiceWorker:
+		return "same-origin"
+	}
+	return p.config.Headers.SecFetchMode
+}
+
+// fetchUser 用户发起的顶层导航发送Sec-Fetch-User: ?1
+// CEF不提供请求的用户激活状态，没有发起方的主框架导航（地址栏输入、书签、新窗口）视为用户发起
+func fetchUser(request Request) string {
+	switch request.ResourceType {
+	case ResourceMainFrame, ResourceNavigationPreloadMainFrame:
+		if request.Initiator == "" {
+			return "?1"
+		}
+	}
+	return ""
+}
+
+// fetchDest 按资源类型推导Sec-Fetch-Dest，无法推导时使用配置值
+func (p *Profile) fetchDest(request Request) string {
+	switch request.ResourceType {
+	case ResourceMainFrame, ResourceNavigationPreloadMainFrame:
+		return "document"
+	case ResourceSubFrame, ResourceNavigationPreloadSubFrame:
+		return "iframe"
+	case ResourceStylesheet:
+		return "style"
+	case ResourceScript:
+		return "script"
+	case ResourceImage, ResourceFavicon:
+		return "image"
+	case ResourceFont:
+		return "font"
+	case ResourceObject:
+		return "object"
+	case ResourcePluginResource:
+		return "embed"
+	case ResourceWorker:
+		return "worker"
+	case ResourceSharedWorker:
+		return "sharedworker"
+	case ResourceServiceWorker:
+		return "serviceworker"
+	case ResourceCSPReport:
+		return "report"
+	case ResourceXHR, ResourcePing, ResourcePrefetch:
+		return "empty"
+	}
+	// 媒体资源可能是audio、video或track，CEF的资源类型无法区分，与其他无法推导的类型一样使用配置值
+	if dest := p.config.Headers.SecFetchDest; dest != "" {
+		return dest
+	}
+	return "empty"
+}
+
+// FetchSite 比较请求地址和发起方的源，返回same-origin/same-site/cross-site
+func FetchSite(requestURL, initiator string) string {
+	target, err := url.Parse(requestURL)
+	if err != nil {
+		return "cross-site"
+	}
+	source, err := url.Parse(initiator)
+	if err != nil {
+		return "cross-site"
+	}
+	if origin(target) == origin(source) {
+		return "same-origin"
+	}
+	if target.Scheme == source.Scheme && registrableDomain(target.Hostname()) == registrableDomain(source.Hostname()) {
+		return "same-site"
+	}
+	return "cross-site"
+}
+
+// PlatformFromUserAgent 从UA中推断平台名称，未配置Sec-CH-UA-Platform时使用
+func PlatformFromUserAgent(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Windows"):
+		return "Windows"
+	case strings.Contains(userAgent, "Macintosh"):
+		return "macOS"
+	case strings.Contains(userAgent, "Android"):
+		return "Android"
+	case strings.Contains(userAgent, "Linux"):
+		return "Linux"
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad"):
+		return "iOS"
+	}
+	return "Windows"
+}
+
+// ClientHints 记录各源通过Accept-CH请求的客户端提示
+type ClientHints struct {
+	lock    sync.RWMutex
+	origins map[string]map[string]bool // 源 -> 小写的提示名称
+}
+
+// NewClientHints 创建新的客户端提示记录实例
+func NewClientHints() *ClientHints {
+	return &ClientHints{origins: make(map[string]map[string]bool)}
+}
+
+// Observe 记录导航响应的Accept-CH，与Chrome一致，响应未包含Accept-CH时清除该源的记录
+func (c *ClientHints) Observe(responseURL, acceptCH string) {
+	parsedURL, err := url.Parse(responseURL)
+	if err != nil || parsedURL.Host == "" {
+		return
+	}
+	key := origin(parsedURL)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if strings.TrimSpace(acceptCH) == "" {
+		delete(c.origins, key)
+		return
+	}
+	hints := make(map[string]bool)
+	for _, item := range strings.Split(acceptCH, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			hints[item] = true
+		}
+	}
+	c.origins[key] = hints
+}
+
+// Requested 获取地址所在源请求的客户端提示
+func (c *ClientHints) Requested(pageURL string) map[string]bool {
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.origins[origin(parsedURL)]
+}
+
+// isSecure 是否为安全来源
+func isSecure(rawURL string) bool {
+	return strings.HasPrefix(strings.ToLower(rawURL), "https://")
+}
+
+// sameOrigin 两个地址是否同源
+func sameOrigin(a, b string) bool {
+	urlA, errA := url.Parse(a)
+	urlB, errB := url.Parse(b)
+	return errA == nil && errB == nil && origin(urlA) == origin(urlB)
+}
+
+// origin 获取地址的源（协议、主机名和端口）
+func origin(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Hostname()) + ":" + port
+}
+
+// registrableDomain 获取可注册域名，无法解析时返回主机名本身
+func registrableDomain(host string) string {
+	host = strings.ToLower(host)
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
@@ -0,0 +1,204 @@
+package headers
+
+import (
+	"cef/internal/config"
+	"testing"
+)
+
+const chromeWindowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+func newTestProfile(hints *ClientHints) *Profile {
+	cfg := &config.BrowserConfig{}
+	cfg.Basic.UserAgent = chromeWindowsUA
+	cfg.Basic.AcceptLanguage = "zh-CN,zh;q=0.9"
+	cfg.Headers.SecChUa = `"Chromium";v="120"`
+	cfg.Headers.SecChUaMobile = "?0"
+	cfg.Headers.SecChUaFullVersionList = `"Chromium";v="120.0.6099.109"`
+	cfg.Headers.SecChUaArch = `"x86"`
+	cfg.Headers.SecChUaBitness = `"64"`
+	cfg.Headers.SecFetchSite = "same-origin"
+	cfg.Headers.SecFetchMode = "cors"
+	cfg.Headers.SecFetchDest = "empty"
+	cfg.Headers.CacheControl = "no-cache"
+	return NewProfile(cfg, hints)
+}
+
+func headerMap(headers []Header) map[string]string {
+	result := make(map[string]string, len(headers))
+	for _, header := range headers {
+		result[header.Name] = header.Value
+	}
+	return result
+}
+
+func TestProfile_HeadersFetchMetadata(t *testing.T) {
+	profile := newTestProfile(nil)
+	tests := []struct {
+		name    string
+		request Request
+		site    string
+		mode    string
+		user    string
+		dest    string
+	}{
+		{name: "typed navigation", request: Request{URL: "https://ad.oceanengine.com/", ResourceType: ResourceMainFrame},
+			site: "none", mode: "navigate", user: "?1", dest: "document"},
+		{name: "same-origin link", request: Request{URL: "https://ad.oceanengine.com/b", Initiator: "https://ad.oceanengine.com/a", ResourceType: ResourceMainFrame},
+			site: "same-origin", mode: "navigate", dest: "document"},
+		{name: "same-site link", request: Request{URL: "https://ad.oceanengine.com/", Initiator: "https://agent.oceanengine.com/", ResourceType: ResourceMainFrame},
+			site: "same-site", mode: "navigate", dest: "document"},
+		{name: "cross-site link", request: Request{URL: "https://ad.oceanengine.com/", Initiator: "https://example.com/", ResourceType: ResourceMainFrame},
+			site: "cross-site", mode: "navigate", dest: "document"},
+		{name: "iframe without initiator", request: Request{URL: "https://ad.oceanengine.com/frame", ResourceType: ResourceSubFrame},
+			site: "none", mode: "navigate", dest: "iframe"},
+		{name: "subresource without initiator uses config", request: Request{URL: "https://ad.oceanengine.com/api", ResourceType: ResourceXHR},
+			site: "same-origin", mode: "cors", dest: "empty"},
+		{name: "script", request: Request{URL: "https://cdn.example.com/a.js", Initiator: "https://ad.oceanengine.com/", ResourceType: ResourceScript},
+			site: "cross-site", mode: "no-cors", dest: "script"},
+		{name: "media falls back to config", request: Request{URL: "https://ad.oceanengine.com/a.mp3", Initiator: "https://ad.oceanengine.com/", ResourceType: ResourceMedia},
+			site: "same-origin", mode: "no-cors", dest: "empty"},
+		{name: "worker", request: Request{URL: "https://ad.oceanengine.com/w.js", Initiator: "https://ad.oceanengine.com/", ResourceType: ResourceWorker},
+			site: "same-origin", mode: "same-origin", dest: "worker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := headerMap(profile.Headers(tt.request))
+			want := map[string]string{"Sec-Fetch-Site": tt.site, "Sec-Fetch-Mode": tt.mode, "Sec-Fetch-User": tt.user, "Sec-Fetch-Dest": tt.dest}
+			for name, value := range want {
+				if got[name] != value {
+					t.Errorf("%s = %q, want %q", name, got[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestProfile_FetchDestMediaWithoutConfig(t *testing.T) {
+	profile := NewProfile(&config.BrowserConfig{}, nil)
+	got := headerMap(profile.Headers(Request{URL: "https://example.com/a.vtt", ResourceType: ResourceMedia}))
+	if got["Sec-Fetch-Dest"] != "empty" {
+		t.Errorf("Sec-Fetch-Dest = %q, want empty", got["Sec-Fetch-Dest"])
+	}
+}
+
+func TestProfile_HeadersInsecure(t *testing.T) {
+	got := headerMap(newTestProfile(nil).Headers(Request{URL: "http://ad.oceanengine.com/", ResourceType: ResourceMainFrame}))
+	if got["User-Agent"] != chromeWindowsUA || got["Accept-Language"] == "" {
+		t.Errorf("http请求也应发送User-Agent和Accept-Language: %v", got)
+	}
+	for _, name := range []string{SecChUa, SecChUaMobile, SecChUaPlatform, "Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest"} {
+		if value, ok := got[name]; ok {
+			t.Errorf("http请求不应发送%s: %q", name, value)
+		}
+	}
+}
+
+func TestProfile_HeadersLowEntropyHints(t *testing.T) {
+	got := headerMap(newTestProfile(nil).Headers(Request{URL: "https://ad.oceanengine.com/", ResourceType: ResourceMainFrame}))
+	if got[SecChUa] == "" || got[SecChUaMobile] != "?0" || got[SecChUaPlatform] != `"Windows"` {
+		t.Errorf("低熵客户端提示 = %v", got)
+	}
+	if _, ok := got["Cache-Control"]; ok {
+		t.Error("Cache-Control只用于XHR请求")
+	}
+	xhr := headerMap(newTestProfile(nil).Headers(Request{URL: "https://ad.oceanengine.com/api", ResourceType: ResourceXHR}))
+	if xhr["Cache-Control"] != "no-cache" {
+		t.Errorf("XHR请求的Cache-Control = %q", xhr["Cache-Control"])
+	}
+}
+
+func TestProfile_HeadersHighEntropyHints(t *testing.T) {
+	hints := NewClientHints()
+	hints.Observe("https://ad.oceanengine.com/", "Sec-CH-UA-Full-Version-List, sec-ch-ua-arch")
+	profile := newTestProfile(hints)
+	tests := []struct {
+		name    string
+		request Request
+		want    map[string]bool // 提示名称 -> 是否发送
+	}{
+		{name: "requested by top level", request: Request{URL: "https://ad.oceanengine.com/", ResourceType: ResourceMainFrame},
+			want: map[string]bool{SecChUaFullVersionList: true, SecChUaArch: true, SecChUaBitness: false}},
+		{name: "same-origin subresource", request: Request{URL: "https://ad.oceanengine.com/api", TopLevelURL: "https://ad.oceanengine.com/", ResourceType: ResourceXHR},
+			want: map[string]bool{SecChUaFullVersionList: true, SecChUaArch: true, SecChUaBitness: false}},
+		{name: "third-party subresource", request: Request{URL: "https://cdn.example.com/a.js", TopLevelURL: "https://ad.oceanengine.com/", ResourceType: ResourceScript},
+			want: map[string]bool{SecChUaFullVersionList: false, SecChUaArch: false, SecChUaBitness: false}},
+		{name: "same-site is still third party", request: Request{URL: "https://agent.oceanengine.com/api", TopLevelURL: "https://ad.oceanengine.com/", ResourceType: ResourceXHR},
+			want: map[string]bool{SecChUaFullVersionList: false, SecChUaArch: false, SecChUaBitness: false}},
+		{name: "origin without Accept-CH", request: Request{URL: "https://example.com/", ResourceType: ResourceMainFrame},
+			want: map[string]bool{SecChUaFullVersionList: false, SecChUaArch: false, SecChUaBitness: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := headerMap(profile.Headers(tt.request))
+			for name, sent := range tt.want {
+				if _, ok := got[name]; ok != sent {
+					t.Errorf("%s sent = %v, want %v", name, ok, sent)
+				}
+			}
+		})
+	}
+}
+
+func TestClientHints_Observe(t *testing.T) {
+	hints := NewClientHints()
+	hints.Observe("https://ad.oceanengine.com/index.html", " Sec-CH-UA-Arch ,, sec-ch-ua-bitness")
+	requested := hints.Requested("https://ad.oceanengine.com:443/other")
+	if !requested["sec-ch-ua-arch"] || !requested["sec-ch-ua-bitness"] || len(requested) != 2 {
+		t.Errorf("Requested = %v", requested)
+	}
+	if got := hints.Requested("http://ad.oceanengine.com/"); got != nil {
+		t.Errorf("其他源不应共享Accept-CH: %v", got)
+	}
+	// 之后的导航响应没有Accept-CH时清除记录
+	hints.Observe("https://ad.oceanengine.com/next", "")
+	if got := hints.Requested("https://ad.oceanengine.com/"); got != nil {
+		t.Errorf("缺少Accept-CH时应清除记录: %v", got)
+	}
+	hints.Observe("not a url", "sec-ch-ua-arch")
+	hints.Observe("/relative", "sec-ch-ua-arch")
+	if len(hints.origins) != 0 {
+		t.Errorf("无效地址不应记录: %v", hints.origins)
+	}
+}
+
+func TestFetchSite(t *testing.T) {
+	tests := []struct {
+		url       string
+		initiator string
+		want      string
+	}{
+		{"https://ad.oceanengine.com/a", "https://ad.oceanengine.com/b", "same-origin"},
+		{"https://ad.oceanengine.com/a", "https://ad.oceanengine.com:443/b", "same-origin"},
+		{"https://ad.oceanengine.com/", "https://agent.oceanengine.com/", "same-site"},
+		{"https://a.example.co.uk/", "https://b.example.co.uk/", "same-site"},
+		{"https://a.co.uk/", "https://b.co.uk/", "cross-site"},
+		{"https://ad.oceanengine.com/", "http://ad.oceanengine.com/", "cross-site"},
+		{"https://ad.oceanengine.com/", "https://ad.oceanengine.com:8443/", "same-site"},
+		{"https://ad.oceanengine.com/", "https://example.com/", "cross-site"},
+		{"https://ad.oceanengine.com/", "%zz", "cross-site"},
+	}
+	for _, tt := range tests {
+		if got := FetchSite(tt.url, tt.initiator); got != tt.want {
+			t.Errorf("FetchSite(%q, %q) = %q, want %q", tt.url, tt.initiator, got, tt.want)
+		}
+	}
+}
+
+func TestPlatformFromUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{chromeWindowsUA, "Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36", "macOS"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36", "Android"},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36", "Linux"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "iOS"},
+		{"", "Windows"},
+	}
+	for _, tt := range tests {
+		if got := PlatformFromUserAgent(tt.userAgent); got != tt.want {
+			t.Errorf("PlatformFromUserAgent(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
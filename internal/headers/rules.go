@@ -0,0 +1,248 @@
+// Package headers 请求头改写规则
+// 按域名对请求头执行设置、追加、删除和正则替换，请求头名称不区分大小写，多值请求头按配置的策略合并
+package headers
+
+import (
+	"cef/internal/config"
+	"cef/internal/security"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 请求头改写动作
+const (
+	ActionSet     = "set"
+	ActionAppend  = "append"
+	ActionRemove  = "remove"
+	ActionReplace = "replace"
+)
+
+// 多值请求头策略
+const (
+	MultiValueKeep   = "keep"   // 保留所有值
+	MultiValueDedupe = "dedupe" // 删除重复的值，保留不同的值
+	MultiValueFirst  = "first"  // 只保留第一个值
+	MultiValueLast   = "last"   // 只保留最后一个值
+	MultiValueJoin   = "join"   // 合并为一个值，Cookie使用"; "分隔，其他使用", "分隔
+)
+
+// StringMultiMap 字符串多值映射，与CEF的ICefStringMultiMap方法一致
+type StringMultiMap interface {
+	GetSize() uint32
+	GetKey(index uint32) string
+	GetValue(index uint32) string
+	Append(key, value string) bool
+	Clear()
+}
+
+// List 按顺序保存的请求头，同名请求头可以出现多次
+type List []Header
+
+// FromMultiMap 按原顺序读取多值映射中的请求头
+func FromMultiMap(m StringMultiMap) List {
+	size := m.GetSize()
+	list := make(List, 0, size)
+	for i := uint32(0); i < size; i++ {
+		list = append(list, Header{Name: m.GetKey(i), Value: m.GetValue(i)})
+	}
+	return list
+}
+
+// WriteTo 清空多值映射后按顺序写入请求头
+func (l List) WriteTo(m StringMultiMap) {
+	m.Clear()
+	for _, header := range l {
+		m.Append(header.Name, header.Value)
+	}
+}
+
+// Values 获取请求头的所有值
+func (l List) Values(name string) []string {
+	var values []string
+	for _, header := range l {
+		if strings.EqualFold(header.Name, name) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
+// Get 获取请求头的第一个值
+func (l List) Get(name string) (string, bool) {
+	for _, header := range l {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set 设置请求头，替换所有同名请求头
+// 已存在时保持第一个同名请求头的位置，否则添加到末尾
+func (l List) Set(name, value string) List {
+	result := make(List, 0, len(l)+1)
+	replaced := false
+	for _, header := range l {
+		if !strings.EqualFold(header.Name, name) {
+			result = append(result, header)
+			continue
+		}
+		if !replaced {
+			result = append(result, Header{Name: header.Name, Value: value})
+			replaced = true
+		}
+	}
+	if !replaced {
+		result = append(result, Header{Name: name, Value: value})
+	}
+	return result
+}
+
+// Append 追加请求头的值，添加到最后一个同名请求头之后
+func (l List) Append(name, value string) List {
+	index := len(l)
+	for i, header := range l {
+		if strings.EqualFold(header.Name, name) {
+			index = i + 1
+			name = header.Name
+		}
+	}
+	result := make(List, 0, len(l)+1)
+	result = append(result, l[:index]...)
+	result = append(result, Header{Name: name, Value: value})
+	return append(result, l[index:]...)
+}
+
+// Remove 删除所有同名请求头
+func (l List) Remove(name string) List {
+	result := make(List, 0, len(l))
+	for _, header := range l {
+		if !strings.EqualFold(header.Name, name) {
+			result = append(result, header)
+		}
+	}
+	return result
+}
+
+// headerRule 已编译的请求头改写规则
+type headerRule struct {
+	config.HeaderRule
+	re *regexp.Regexp
+}
+
+// RuleSet 请求头改写规则集合
+type RuleSet struct {
+	rules      []headerRule
+	multiValue map[string]string // 小写的请求头名称 -> 多值策略
+}
+
+// NewRuleSet 创建新的请求头改写规则集合实例，规则配置错误时返回错误
+func NewRuleSet(rules []config.HeaderRule, multiValue map[string]string) (*RuleSet, error) {
+	ruleSet := &RuleSet{multiValue: make(map[string]string, len(multiValue))}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("请求头改写规则%d缺少请求头名称", i)
+		}
+		compiled := headerRule{HeaderRule: rule}
+		switch rule.Action {
+		case ActionSet, ActionAppend, ActionRemove:
+		case ActionReplace:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("请求头改写规则%d正则错误: %v", i, err)
+			}
+			compiled.re = re
+		default:
+			return nil, fmt.Errorf("请求头改写规则%d不支持的动作: %s", i, rule.Action)
+		}
+		ruleSet.rules = append(ruleSet.rules, compiled)
+	}
+	for name, policy := range multiValue {
+		switch policy {
+		case MultiValueKeep, MultiValueDedupe, MultiValueFirst, MultiValueLast, MultiValueJoin:
+		default:
+			return nil, fmt.Errorf("请求头%s不支持的多值策略: %s", name, policy)
+		}
+		ruleSet.multiValue[strings.ToLower(name)] = policy
+	}
+	return ruleSet, nil
+}
+
+// Apply 对发往host的请求执行匹配的规则，然后按多值策略合并同名请求头
+func (r *RuleSet) Apply(host string, list List) List {
+	for _, rule := range r.rules {
+		if rule.HostPattern != "" && !security.MatchHost(host, rule.HostPattern) {
+			continue
+		}
+		switch rule.Action {
+		case ActionSet:
+			list = list.Set(rule.Name, rule.Value)
+		case ActionAppend:
+			list = list.Append(rule.Name, rule.Value)
+		case ActionRemove:
+			list = list.Remove(rule.Name)
+		case ActionReplace:
+			for i := range list {
+				if strings.EqualFold(list[i].Name, rule.Name) {
+					list[i].Value = rule.re.ReplaceAllString(list[i].Value, rule.Value)
+				}
+			}
+		}
+	}
+	return r.mergeValues(list)
+}
+
+// Policy 获取请求头的多值策略
+func (r *RuleSet) Policy(name string) string {
+	if policy, ok := r.multiValue[strings.ToLower(name)]; ok {
+		return policy
+	}
+	return MultiValueDedupe
+}
+
+// mergeValues 按多值策略合并同名请求头，合并后的请求头位于第一个同名请求头的位置
+func (r *RuleSet) mergeValues(list List) List {
+	result := make(List, 0, len(list))
+	positions := make(map[string]int) // 小写名称 -> result中的位置
+	for _, header := range list {
+		key := strings.ToLower(header.Name)
+		index, exists := positions[key]
+		if !exists {
+			positions[key] = len(result)
+			result = append(result, header)
+			continue
+		}
+		switch r.Policy(key) {
+		case MultiValueKeep:
+			result = append(result, header)
+		case MultiValueDedupe:
+			if !containsValue(result, key, header.Value) {
+				result = append(result, header)
+			}
+		case MultiValueLast:
+			result[index].Value = header.Value
+		case MultiValueJoin:
+			result[index].Value += joinSeparator(key) + header.Value
+		}
+	}
+	return result
+}
+
+// containsValue 检查是否已存在同名同值的请求头
+func containsValue(list List, key, value string) bool {
+	for _, header := range list {
+		if strings.ToLower(header.Name) == key && header.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// joinSeparator 合并多值请求头时使用的分隔符
+func joinSeparator(key string) string {
+	if key == "cookie" {
+		return "; "
+	}
+	return ", "
+}
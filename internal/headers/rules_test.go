@@ -0,0 +1,137 @@
+package headers
+
+import (
+	"cef/internal/config"
+	"reflect"
+	"testing"
+)
+
+// fakeMultiMap 模拟ICefStringMultiMap：按添加顺序保存，同名键可以出现多次
+type fakeMultiMap struct {
+	keys   []string
+	values []string
+}
+
+func newFakeMultiMap(pairs ...string) *fakeMultiMap {
+	m := &fakeMultiMap{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m.Append(pairs[i], pairs[i+1])
+	}
+	return m
+}
+
+func (m *fakeMultiMap) GetSize() uint32              { return uint32(len(m.keys)) }
+func (m *fakeMultiMap) GetKey(index uint32) string   { return m.keys[index] }
+func (m *fakeMultiMap) GetValue(index uint32) string { return m.values[index] }
+func (m *fakeMultiMap) Clear()                       { m.keys, m.values = nil, nil }
+func (m *fakeMultiMap) Append(key, value string) bool {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+	return true
+}
+
+func (m *fakeMultiMap) pairs() []string {
+	var result []string
+	for i := range m.keys {
+		result = append(result, m.keys[i], m.values[i])
+	}
+	return result
+}
+
+func applyRules(t *testing.T, rules []config.HeaderRule, multiValue map[string]string, host string, m *fakeMultiMap) []string {
+	t.Helper()
+	ruleSet, err := NewRuleSet(rules, multiValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ruleSet.Apply(host, FromMultiMap(m)).WriteTo(m)
+	return m.pairs()
+}
+
+func TestRuleSet_Actions(t *testing.T) {
+	rules := []config.HeaderRule{
+		{Action: ActionRemove, Name: "dnt"},
+		{Action: ActionSet, Name: "accept-language", Value: "en-US"},
+		{Action: ActionAppend, Name: "X-Trace", Value: "2"},
+		{Action: ActionAppend, Name: "X-New", Value: "new"},
+		{Action: ActionReplace, Name: "user-agent", Pattern: `Chrome/(\d+)`, Value: "Chrome/$1.0"},
+		{HostPattern: "other.com", Action: ActionSet, Name: "X-Other", Value: "1"},
+	}
+	m := newFakeMultiMap(
+		"User-Agent", "Mozilla/5.0 Chrome/120",
+		"DNT", "1",
+		"Accept-Language", "zh-CN",
+		"X-Trace", "1",
+		"ACCEPT-LANGUAGE", "zh",
+		"Accept", "*/*",
+	)
+	got := applyRules(t, rules, map[string]string{"x-trace": MultiValueKeep}, "www.example.com", m)
+	want := []string{
+		"User-Agent", "Mozilla/5.0 Chrome/120.0",
+		"Accept-Language", "en-US",
+		"X-Trace", "1",
+		"X-Trace", "2",
+		"Accept", "*/*",
+		"X-New", "new",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestRuleSet_HostPattern(t *testing.T) {
+	rules := []config.HeaderRule{{HostPattern: "example.com", Action: ActionSet, Name: "X-Site", Value: "1"}}
+	for host, want := range map[string]bool{"example.com": true, "api.example.com": true, "badexample.com": false} {
+		got := applyRules(t, rules, nil, host, newFakeMultiMap("Accept", "*/*"))
+		if matched := len(got) == 4; matched != want {
+			t.Errorf("%s: 匹配 = %v, want %v", host, matched, want)
+		}
+	}
+}
+
+func TestRuleSet_MultiValuePolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{"", []string{"Cookie", "a=1", "Accept", "x", "Cookie", "b=2"}},
+		{MultiValueDedupe, []string{"Cookie", "a=1", "Accept", "x", "Cookie", "b=2"}},
+		{MultiValueKeep, []string{"Cookie", "a=1", "Accept", "x", "cookie", "a=1", "Cookie", "b=2"}},
+		{MultiValueFirst, []string{"Cookie", "a=1", "Accept", "x"}},
+		{MultiValueLast, []string{"Cookie", "b=2", "Accept", "x"}},
+		{MultiValueJoin, []string{"Cookie", "a=1; a=1; b=2", "Accept", "x"}},
+	}
+	for _, tt := range tests {
+		multiValue := map[string]string{}
+		if tt.policy != "" {
+			multiValue["COOKIE"] = tt.policy
+		}
+		m := newFakeMultiMap("Cookie", "a=1", "Accept", "x", "cookie", "a=1", "Cookie", "b=2")
+		if got := applyRules(t, nil, multiValue, "example.com", m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+
+	// 非Cookie请求头合并时使用逗号分隔
+	m := newFakeMultiMap("Accept-Encoding", "gzip", "accept-encoding", "br")
+	if got := applyRules(t, nil, map[string]string{"accept-encoding": MultiValueJoin}, "", m); !reflect.DeepEqual(got, []string{"Accept-Encoding", "gzip, br"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNewRuleSet_Invalid(t *testing.T) {
+	invalid := []struct {
+		rules      []config.HeaderRule
+		multiValue map[string]string
+	}{
+		{rules: []config.HeaderRule{{Action: ActionSet}}},
+		{rules: []config.HeaderRule{{Action: "rename", Name: "a"}}},
+		{rules: []config.HeaderRule{{Action: ActionReplace, Name: "a", Pattern: "("}}},
+		{multiValue: map[string]string{"cookie": "merge"}},
+	}
+	for i, tt := range invalid {
+		if _, err := NewRuleSet(tt.rules, tt.multiValue); err == nil {
+			t.Errorf("%d: 错误的配置应返回错误", i)
+		}
+	}
+}
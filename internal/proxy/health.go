@@ -0,0 +1,222 @@
+// Package proxy 代理健康检查
+// 后台定时通过代理访问探测地址，记录可用性和延迟，可用性变化时通知调用方切换代理
+package proxy
+
+import (
+	"cef/internal/config"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeURL         = "http://www.gstatic.com/generate_204"
+	defaultCheckInterval    = 60
+	defaultCheckTimeout     = 10
+	defaultFailureThreshold = 2
+)
+
+// Status 代理的健康状态
+type Status struct {
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Url         string        `json:"url"`
+	Healthy     bool          `json:"healthy"`
+	Latency     time.Duration `json:"latency"`
+	Failures    int           `json:"failures"` // 连续失败次数
+	LastError   string        `json:"last_error,omitempty"`
+	LastChecked time.Time     `json:"last_checked"`
+}
+
+// HealthChangeHandler 代理可用性变化时的回调
+type HealthChangeHandler func(server config.ProxyServer, status Status)
+
+// HealthChecker 代理健康检查器
+type HealthChecker struct {
+	config   config.ProxyHealthCheck
+	servers  func() []config.ProxyServer // 需要检查的代理
+	onChange HealthChangeHandler
+
+	lock   sync.RWMutex
+	status map[string]*Status // 代理标识 -> 状态
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewHealthChecker 创建新的代理健康检查器实例，未配置的参数使用默认值
+func NewHealthChecker(cfg config.ProxyHealthCheck, servers func() []config.ProxyServer, onChange HealthChangeHandler) *HealthChecker {
+	if cfg.ProbeURL == "" {
+		cfg.ProbeURL = defaultProbeURL
+	}
+	if cfg.IntervalSec <= 0 {
+		cfg.IntervalSec = defaultCheckInterval
+	}
+	if cfg.TimeoutSec <= 0 {
+		cfg.TimeoutSec = defaultCheckTimeout
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	return &HealthChecker{
+		config:   cfg,
+		servers:  servers,
+		onChange: onChange,
+		status:   make(map[string]*Status),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 启动后台检查，立即检查一次后按间隔定时检查
+func (c *HealthChecker) Start() {
+	c.wg.Add(1)
+	go c.checkLoop()
+}
+
+// Close 停止后台检查
+func (c *HealthChecker) Close() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// checkLoop 定时检查所有代理
+func (c *HealthChecker) checkLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(time.Duration(c.config.IntervalSec) * time.Second)
+	defer ticker.Stop()
+	for {
+		c.CheckAll()
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// CheckAll 并发检查所有代理
+func (c *HealthChecker) CheckAll() {
+	var wg sync.WaitGroup
+	checked := make(map[string]bool)
+	for _, server := range c.servers() {
+		if checked[Key(server)] {
+			continue
+		}
+		checked[Key(server)] = true
+		wg.Add(1)
+		go func(server config.ProxyServer) {
+			defer wg.Done()
+			c.Check(server)
+		}(server)
+	}
+	wg.Wait()
+}
+
+// Check 检查单个代理并更新状态，可用性变化时通知回调
+func (c *HealthChecker) Check(server config.ProxyServer) Status {
+	latency, err := c.probe(server)
+
+	c.lock.Lock()
+	status, ok := c.status[Key(server)]
+	if !ok {
+		status = &Status{Name: server.Name, Type: server.Type, Url: server.Url, Healthy: true}
+		c.status[Key(server)] = status
+	}
+	wasHealthy := status.Healthy
+	status.LastChecked = time.Now()
+	if err != nil {
+		status.Failures++
+		status.LastError = err.Error()
+		status.Healthy = status.Failures < c.config.FailureThreshold
+	} else {
+		status.Failures = 0
+		status.LastError = ""
+		status.Latency = latency
+		status.Healthy = true
+	}
+	result := *status
+	c.lock.Unlock()
+
+	if result.Healthy != wasHealthy {
+		if result.Healthy {
+			fmt.Printf("代理恢复可用 - 代理: %s, 延迟: %v\n", server.Name, result.Latency)
+		} else {
+			fmt.Printf("代理不可用 - 代理: %s, 错误: %s\n", server.Name, result.LastError)
+		}
+		if c.onChange != nil {
+			c.onChange(server, result)
+		}
+	}
+	return result
+}
+
+// Healthy 代理是否可用，尚未检查的代理视为可用
+func (c *HealthChecker) Healthy(server config.ProxyServer) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	status, ok := c.status[Key(server)]
+	return !ok || status.Healthy
+}
+
+// Status 获取代理的状态，尚未检查时返回false
+func (c *HealthChecker) Status(server config.ProxyServer) (Status, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	status, ok := c.status[Key(server)]
+	if !ok {
+		return Status{}, false
+	}
+	return *status, true
+}
+
+// Statuses 获取所有已检查代理的状态
+func (c *HealthChecker) Statuses() []Status {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	result := make([]Status, 0, len(c.status))
+	for _, status := range c.status {
+		result = append(result, *status)
+	}
+	return result
+}
+
+// probe 通过代理访问探测地址，返回延迟
+// pac类型无法直接作为代理使用，只检查PAC脚本是否可以访问
+func (c *HealthChecker) probe(server config.ProxyServer) (time.Duration, error) {
+	target := c.config.ProbeURL
+	transport := &http.Transport{DisableKeepAlives: true}
+	if server.Type == TypePAC {
+		target = server.Url
+	} else {
+		proxyURL, err := url.Parse(ServerURL(server))
+		if err != nil {
+			return 0, fmt.Errorf("代理地址错误: %v", err)
+		}
+		if server.Username != "" {
+			proxyURL.User = url.UserPassword(server.Username, server.Password)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(c.config.TimeoutSec) * time.Second,
+	}
+
+	start := time.Now()
+	resp, err := client.Get(target)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	latency := time.Since(start)
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return 0, fmt.Errorf("代理认证失败")
+	case resp.StatusCode >= http.StatusBadRequest:
+		return 0, fmt.Errorf("探测地址返回状态码%d", resp.StatusCode)
+	}
+	return latency, nil
+}
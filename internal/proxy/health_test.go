@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"cef/internal/config"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newStandInProxy 创建模拟HTTP代理：代理请求的URL为完整地址，按探测地址直接返回
+func newStandInProxy(t *testing.T, username, password string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		if username != "" {
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+			if r.Header.Get("Proxy-Authorization") != want {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serverOf(name string, s *httptest.Server) config.ProxyServer {
+	return normalize(config.ProxyServer{Name: name, Url: strings.TrimPrefix(s.URL, "http://")})
+}
+
+func TestHealthChecker_Check(t *testing.T) {
+	alive := serverOf("alive", newStandInProxy(t, "", ""))
+	auth := serverOf("auth", newStandInProxy(t, "user", "pass"))
+	auth.Username, auth.Password = "user", "pass"
+	wrongAuth := auth
+	wrongAuth.Name, wrongAuth.Password = "wrong", "bad"
+
+	deadServer := httptest.NewServer(http.NotFoundHandler())
+	dead := serverOf("dead", deadServer)
+	deadServer.Close()
+
+	checker := NewHealthChecker(config.ProxyHealthCheck{ProbeURL: "http://probe.invalid/generate_204", TimeoutSec: 2, FailureThreshold: 1}, nil, nil)
+	tests := []struct {
+		server config.ProxyServer
+		want   bool
+	}{
+		{alive, true},
+		{auth, true},
+		{wrongAuth, false},
+		{dead, false},
+	}
+	for _, tt := range tests {
+		status := checker.Check(tt.server)
+		if status.Healthy != tt.want {
+			t.Errorf("%s: Healthy = %v, want %v (%s)", tt.server.Name, status.Healthy, tt.want, status.LastError)
+		}
+		if tt.want && status.Latency <= 0 {
+			t.Errorf("%s: 可用代理应记录延迟", tt.server.Name)
+		}
+	}
+}
+
+func TestHealthChecker_Failover(t *testing.T) {
+	standIn := newStandInProxy(t, "", "")
+	primary := serverOf("primary", standIn)
+	backup := serverOf("backup", newStandInProxy(t, "", ""))
+	servers := []config.ProxyServer{primary, backup}
+
+	var lock sync.Mutex
+	var changes []string
+	checker := NewHealthChecker(config.ProxyHealthCheck{ProbeURL: "http://probe.invalid/", TimeoutSec: 2, FailureThreshold: 2},
+		func() []config.ProxyServer { return servers },
+		func(server config.ProxyServer, status Status) {
+			lock.Lock()
+			defer lock.Unlock()
+			changes = append(changes, server.Name)
+		})
+
+	// 找到固定选择primary的账户
+	selector := NewSelector()
+	account := ""
+	for i := 0; i < 100; i++ {
+		candidate := "account" + strings.Repeat("x", i)
+		if server, _ := selector.Select(candidate, SelectionSticky, servers, checker.Healthy); Key(server) == Key(primary) {
+			account = candidate
+			break
+		}
+	}
+	if account == "" {
+		t.Fatal("未找到选择primary的账户")
+	}
+
+	checker.CheckAll()
+	standIn.Close()
+	checker.CheckAll()
+	if !checker.Healthy(primary) {
+		t.Fatal("连续失败次数未达到阈值时应视为可用")
+	}
+	checker.CheckAll()
+	if checker.Healthy(primary) {
+		t.Fatal("连续失败达到阈值后应视为不可用")
+	}
+	if len(changes) != 1 || changes[0] != "primary" {
+		t.Errorf("可用性变化通知 = %v, want [primary]", changes)
+	}
+
+	if server, _ := selector.Select(account, SelectionSticky, servers, checker.Healthy); Key(server) != Key(backup) {
+		t.Errorf("primary不可用时应切换到backup，实际为%s", server.Name)
+	}
+	if server, _ := selector.Select(account, SelectionSticky, servers, nil); Key(server) != Key(primary) {
+		t.Errorf("不检查可用性时应仍选择primary，实际为%s", server.Name)
+	}
+}
+
+func TestSelector_RoundRobin(t *testing.T) {
+	servers := []config.ProxyServer{{Name: "a", Url: "a:1"}, {Name: "b", Url: "b:1"}, {Name: "c", Url: "c:1"}}
+	selector := NewSelector()
+	down := func(server config.ProxyServer) bool { return server.Name != "b" }
+	var got []string
+	for i := 0; i < 4; i++ {
+		server, _ := selector.Select("", SelectionRoundRobin, servers, down)
+		got = append(got, server.Name)
+	}
+	if want := "a,c,a,c"; strings.Join(got, ",") != want {
+		t.Errorf("轮询结果 = %v, want %s", got, want)
+	}
+}
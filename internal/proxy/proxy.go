@@ -0,0 +1,191 @@
+// Package proxy 代理选择
+// 将代理配置解析为代理列表，按账户固定或轮询从代理池中选择代理，并生成Chromium的代理偏好
+package proxy
+
+import (
+	"cef/internal/config"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 代理类型
+const (
+	TypeHTTP   = "http"
+	TypeHTTPS  = "https"
+	TypeSOCKS5 = "socks5"
+	TypePAC    = "pac"
+)
+
+// 代理池选择方式
+const (
+	SelectionSticky     = "sticky"      // 同一账户始终使用同一代理
+	SelectionRoundRobin = "round_robin" // 每个请求上下文依次使用下一个代理
+)
+
+// Chromium代理模式
+const (
+	ModeDirect       = "direct"
+	ModeSystem       = "system"
+	ModeFixedServers = "fixed_servers"
+	ModePACScript    = "pac_script"
+)
+
+// Servers 获取配置的代理列表，配置了代理池时使用代理池，否则使用单个代理
+// mode为direct或system时不使用代理
+func Servers(cfg config.BrowserConfig) []config.ProxyServer {
+	proxyConfig := cfg.Proxy
+	if proxyConfig.Mode == ModeDirect || proxyConfig.Mode == ModeSystem {
+		return nil
+	}
+	var servers []config.ProxyServer
+	for _, server := range proxyConfig.Pool {
+		if server.Url != "" {
+			servers = append(servers, normalize(server))
+		}
+	}
+	if len(servers) > 0 || proxyConfig.Url == "" {
+		return servers
+	}
+	serverType := proxyConfig.Type
+	if serverType == "" && proxyConfig.Mode == ModePACScript {
+		serverType = TypePAC
+	}
+	return []config.ProxyServer{normalize(config.ProxyServer{
+		Name:     "default",
+		Type:     serverType,
+		Url:      proxyConfig.Url,
+		Username: proxyConfig.Username,
+		Password: proxyConfig.Password,
+	})}
+}
+
+// normalize 补全代理的默认类型和名称
+func normalize(server config.ProxyServer) config.ProxyServer {
+	server.Type = strings.ToLower(server.Type)
+	if server.Type == "" {
+		server.Type = TypeHTTP
+		if i := strings.Index(server.Url, "://"); i > 0 {
+			server.Type = strings.ToLower(server.Url[:i])
+		}
+	}
+	if server.Type == "socks" || server.Type == "socks5h" {
+		server.Type = TypeSOCKS5
+	}
+	if server.Name == "" {
+		server.Name = server.Url
+	}
+	return server
+}
+
+// Key 代理的唯一标识
+func Key(server config.ProxyServer) string {
+	return server.Type + "|" + server.Url
+}
+
+// Address 获取代理的host:port，pac类型返回空
+func Address(server config.ProxyServer) string {
+	if server.Type == TypePAC {
+		return ""
+	}
+	address := server.Url
+	if i := strings.Index(address, "://"); i >= 0 {
+		address = address[i+3:]
+	}
+	return strings.TrimSuffix(address, "/")
+}
+
+// ServerURL 获取Chromium代理服务器地址，按类型添加协议前缀
+func ServerURL(server config.ProxyServer) string {
+	address := Address(server)
+	switch server.Type {
+	case TypeHTTPS:
+		return "https://" + address
+	case TypeSOCKS5:
+		return "socks5://" + address
+	default:
+		return "http://" + address
+	}
+}
+
+// Match 检查代理认证请求的host和port是否属于该代理
+func Match(server config.ProxyServer, host string, port int32) bool {
+	serverHost, serverPort, err := net.SplitHostPort(Address(server))
+	if err != nil {
+		return strings.EqualFold(Address(server), host)
+	}
+	return strings.EqualFold(serverHost, host) && serverPort == strconv.Itoa(int(port))
+}
+
+// Preference 生成Chromium的proxy偏好设置，server为nil时不使用代理
+func Preference(server *config.ProxyServer, bypass []string) map[string]string {
+	if server == nil {
+		return map[string]string{"mode": ModeDirect}
+	}
+	if server.Type == TypePAC {
+		return map[string]string{"mode": ModePACScript, "pac_url": server.Url}
+	}
+	preference := map[string]string{"mode": ModeFixedServers, "server": ServerURL(*server)}
+	if len(bypass) > 0 {
+		preference["bypass_list"] = strings.Join(bypass, ";")
+	}
+	return preference
+}
+
+// Selector 代理池选择器
+type Selector struct {
+	lock   sync.Mutex
+	next   int               // 轮询的下一个位置
+	sticky map[string]string // 账户 -> 代理标识
+}
+
+// NewSelector 创建新的代理池选择器实例
+func NewSelector() *Selector {
+	return &Selector{sticky: make(map[string]string)}
+}
+
+// Select 为账户选择代理，没有代理时返回false
+// available不为空时跳过不可用的代理：固定选择的代理不可用时使用其后第一个可用的备用代理，
+// 恢复后重新使用原代理；全部不可用时仍返回原本选择的代理
+func (s *Selector) Select(account, selection string, servers []config.ProxyServer, available func(config.ProxyServer) bool) (config.ProxyServer, bool) {
+	if len(servers) == 0 {
+		return config.ProxyServer{}, false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	index := s.preferred(account, selection, servers)
+	for i := 0; i < len(servers); i++ {
+		server := servers[(index+i)%len(servers)]
+		if available == nil || available(server) {
+			if selection == SelectionRoundRobin {
+				s.next = (index + i + 1) % len(servers)
+			}
+			return server, true
+		}
+	}
+	return servers[index], true
+}
+
+// preferred 获取首选代理的位置
+func (s *Selector) preferred(account, selection string, servers []config.ProxyServer) int {
+	if selection == SelectionRoundRobin {
+		index := s.next % len(servers)
+		s.next = (index + 1) % len(servers)
+		return index
+	}
+	if key, ok := s.sticky[account]; ok {
+		for i, server := range servers {
+			if Key(server) == key {
+				return i
+			}
+		}
+	}
+	// 按账户哈希选择，重启后同一账户仍使用同一代理
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(account))
+	index := int(hash.Sum32() % uint32(len(servers)))
+	s.sticky[account] = Key(servers[index])
+	return index
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"cef/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		server config.ProxyServer
+		want   config.ProxyServer
+	}{
+		{name: "default type", server: config.ProxyServer{Url: "1.2.3.4:8080"}, want: config.ProxyServer{Name: "1.2.3.4:8080", Type: TypeHTTP, Url: "1.2.3.4:8080"}},
+		{name: "type from scheme", server: config.ProxyServer{Url: "socks5://1.2.3.4:1080"}, want: config.ProxyServer{Name: "socks5://1.2.3.4:1080", Type: TypeSOCKS5, Url: "socks5://1.2.3.4:1080"}},
+		{name: "https scheme", server: config.ProxyServer{Name: "p", Url: "HTTPS://proxy:443"}, want: config.ProxyServer{Name: "p", Type: TypeHTTPS, Url: "HTTPS://proxy:443"}},
+		{name: "socks alias", server: config.ProxyServer{Name: "p", Type: "SOCKS", Url: "proxy:1080"}, want: config.ProxyServer{Name: "p", Type: TypeSOCKS5, Url: "proxy:1080"}},
+		{name: "socks5h alias", server: config.ProxyServer{Name: "p", Url: "socks5h://proxy:1080"}, want: config.ProxyServer{Name: "p", Type: TypeSOCKS5, Url: "socks5h://proxy:1080"}},
+		{name: "explicit type wins", server: config.ProxyServer{Name: "p", Type: "pac", Url: "http://pac/proxy.pac"}, want: config.ProxyServer{Name: "p", Type: TypePAC, Url: "http://pac/proxy.pac"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.server); got != tt.want {
+				t.Errorf("normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServers(t *testing.T) {
+	browserConfig := func(fill func(*config.BrowserConfig)) config.BrowserConfig {
+		var cfg config.BrowserConfig
+		fill(&cfg)
+		return cfg
+	}
+	tests := []struct {
+		name string
+		cfg  config.BrowserConfig
+		want []config.ProxyServer
+	}{
+		{name: "no proxy", cfg: browserConfig(func(c *config.BrowserConfig) {}), want: nil},
+		{name: "direct ignores url", cfg: browserConfig(func(c *config.BrowserConfig) {
+			c.Proxy.Mode, c.Proxy.Url = ModeDirect, "1.2.3.4:8080"
+		}), want: nil},
+		{name: "system ignores pool", cfg: browserConfig(func(c *config.BrowserConfig) {
+			c.Proxy.Mode = ModeSystem
+			c.Proxy.Pool = []config.ProxyServer{{Url: "1.2.3.4:8080"}}
+		}), want: nil},
+		{name: "single proxy", cfg: browserConfig(func(c *config.BrowserConfig) {
+			c.Proxy.Url, c.Proxy.Username, c.Proxy.Password = "1.2.3.4:8080", "u", "p"
+		}), want: []config.ProxyServer{{Name: "default", Type: TypeHTTP, Url: "1.2.3.4:8080", Username: "u", Password: "p"}}},
+		{name: "pac mode", cfg: browserConfig(func(c *config.BrowserConfig) {
+			c.Proxy.Mode, c.Proxy.Url = ModePACScript, "http://pac/proxy.pac"
+		}), want: []config.ProxyServer{{Name: "default", Type: TypePAC, Url: "http://pac/proxy.pac"}}},
+		{name: "pool overrides url", cfg: browserConfig(func(c *config.BrowserConfig) {
+			c.Proxy.Url = "9.9.9.9:8080"
+			c.Proxy.Pool = []config.ProxyServer{{Name: "a", Url: "1.1.1.1:8080"}, {Url: ""}, {Name: "b", Url: "socks5://2.2.2.2:1080"}}
+		}), want: []config.ProxyServer{{Name: "a", Type: TypeHTTP, Url: "1.1.1.1:8080"}, {Name: "b", Type: TypeSOCKS5, Url: "socks5://2.2.2.2:1080"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Servers(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Servers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	a := normalize(config.ProxyServer{Name: "a", Url: "1.2.3.4:8080", Username: "u1"})
+	b := normalize(config.ProxyServer{Name: "b", Url: "1.2.3.4:8080", Username: "u2"})
+	c := normalize(config.ProxyServer{Url: "socks5://1.2.3.4:8080"})
+	if Key(a) != Key(b) {
+		t.Errorf("名称和认证信息不同的同一代理应有相同的标识: %q != %q", Key(a), Key(b))
+	}
+	if Key(a) == Key(c) {
+		t.Errorf("类型不同的代理应有不同的标识: %q", Key(a))
+	}
+}
+
+func TestPreference(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *config.ProxyServer
+		bypass []string
+		want   map[string]string
+	}{
+		{name: "no server", want: map[string]string{"mode": ModeDirect}},
+		{name: "http", server: &config.ProxyServer{Type: TypeHTTP, Url: "1.2.3.4:8080"}, want: map[string]string{"mode": ModeFixedServers, "server": "http://1.2.3.4:8080"}},
+		{name: "https with scheme", server: &config.ProxyServer{Type: TypeHTTPS, Url: "https://proxy:443/"}, want: map[string]string{"mode": ModeFixedServers, "server": "https://proxy:443"}},
+		{name: "socks5 with bypass", server: &config.ProxyServer{Type: TypeSOCKS5, Url: "socks5://proxy:1080"}, bypass: []string{"<local>", "*.example.com"},
+			want: map[string]string{"mode": ModeFixedServers, "server": "socks5://proxy:1080", "bypass_list": "<local>;*.example.com"}},
+		{name: "pac ignores bypass", server: &config.ProxyServer{Type: TypePAC, Url: "http://pac/proxy.pac"}, bypass: []string{"<local>"},
+			want: map[string]string{"mode": ModePACScript, "pac_url": "http://pac/proxy.pac"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Preference(tt.server, tt.bypass); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Preference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		server config.ProxyServer
+		host   string
+		port   int32
+		want   bool
+	}{
+		{name: "host and port", server: config.ProxyServer{Type: TypeHTTP, Url: "proxy.example.com:8080"}, host: "proxy.example.com", port: 8080, want: true},
+		{name: "case insensitive", server: config.ProxyServer{Type: TypeHTTP, Url: "Proxy.Example.com:8080"}, host: "proxy.example.com", port: 8080, want: true},
+		{name: "with scheme", server: config.ProxyServer{Type: TypeSOCKS5, Url: "socks5://1.2.3.4:1080"}, host: "1.2.3.4", port: 1080, want: true},
+		{name: "other port", server: config.ProxyServer{Type: TypeHTTP, Url: "proxy.example.com:8080"}, host: "proxy.example.com", port: 3128, want: false},
+		{name: "other host", server: config.ProxyServer{Type: TypeHTTP, Url: "proxy.example.com:8080"}, host: "evil.example.com", port: 8080, want: false},
+		{name: "no port in config", server: config.ProxyServer{Type: TypeHTTP, Url: "proxy.example.com"}, host: "proxy.example.com", port: 80, want: true},
+		{name: "pac", server: config.ProxyServer{Type: TypePAC, Url: "http://pac/proxy.pac"}, host: "pac", port: 80, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.server, tt.host, tt.port); got != tt.want {
+				t.Errorf("Match(%q, %d) = %v, want %v", tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelector_Select(t *testing.T) {
+	servers := []config.ProxyServer{
+		normalize(config.ProxyServer{Name: "a", Url: "1.1.1.1:8080"}),
+		normalize(config.ProxyServer{Name: "b", Url: "2.2.2.2:8080"}),
+		normalize(config.ProxyServer{Name: "c", Url: "3.3.3.3:8080"}),
+	}
+	selector := NewSelector()
+	if _, ok := selector.Select("user", SelectionSticky, nil, nil); ok {
+		t.Error("没有代理时应返回false")
+	}
+
+	first, _ := selector.Select("user", SelectionSticky, servers, nil)
+	for i := 0; i < 3; i++ {
+		if got, _ := selector.Select("user", SelectionSticky, servers, nil); got != first {
+			t.Fatalf("固定选择应始终返回同一代理: %s != %s", got.Name, first.Name)
+		}
+	}
+	down := func(server config.ProxyServer) bool { return Key(server) != Key(first) }
+	if backup, _ := selector.Select("user", SelectionSticky, servers, down); backup == first {
+		t.Errorf("固定选择的代理不可用时应使用备用代理")
+	}
+	if got, _ := selector.Select("user", SelectionSticky, servers, nil); got != first {
+		t.Errorf("代理恢复后应重新使用原代理: %s", got.Name)
+	}
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		server, _ := selector.Select("", SelectionRoundRobin, servers, nil)
+		names = append(names, server.Name)
+	}
+	if want := []string{"a", "b", "c", "a"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("轮询顺序 = %v, want %v", names, want)
+	}
+}
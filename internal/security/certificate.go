@@ -0,0 +1,175 @@
+// Package security 证书校验策略
+// 替代全局忽略证书错误：默认严格校验，支持按域名放行、SPKI固定和自定义企业根证书
+package security
+
+import (
+	"cef/internal/config"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// 证书校验模式
+const (
+	CertificateModeStrict     = "strict"     // 严格校验，仅放行配置的例外
+	CertificateModePermissive = "permissive" // 放行所有证书错误（仅用于调试）
+)
+
+// errCertAuthorityInvalid 证书颁发机构不受信任（net::ERR_CERT_AUTHORITY_INVALID）
+const errCertAuthorityInvalid = -202
+
+// CertificateDecision 证书校验结果
+type CertificateDecision struct {
+	Allowed bool
+	Reason  string
+}
+
+// CertificatePolicy 证书校验策略
+type CertificatePolicy struct {
+	config func(...string) *config.BrowserConfig
+
+	caLock  sync.Mutex
+	caFile  string
+	caPool  *x509.CertPool
+	caError error
+}
+
+// NewCertificatePolicy 创建新的证书校验策略实例
+func NewCertificatePolicy(cfg func(...string) *config.BrowserConfig) *CertificatePolicy {
+	return &CertificatePolicy{
+		config: cfg,
+	}
+}
+
+// OnCertificateError 决定是否放行证书错误
+// chain为DER编码的证书链，第一个为站点证书
+func (p *CertificatePolicy) OnCertificateError(host string, certError int32, chain [][]byte, account ...string) CertificateDecision {
+	certConfig := p.config(account...).Certificate
+
+	// SPKI固定只能在正常校验之外增加拒绝条件：不匹配时直接拒绝，匹配时仍按下面的规则处理证书错误，
+	// 否则固定了中间证书时，该CA为其他域名签发的证书或过期、吊销的证书都会被放行
+	if pins := matchPins(host, certConfig.Pins); len(pins) > 0 && !chainMatchesPins(chain, pins) {
+		return CertificateDecision{Reason: "spki_pin_mismatch"}
+	}
+
+	// 颁发机构不受信任时，尝试使用自定义企业根证书校验
+	if certError == errCertAuthorityInvalid && certConfig.CustomCAFile != "" {
+		err := p.verifyWithCustomCA(certConfig.CustomCAFile, host, chain)
+		if err == nil {
+			return CertificateDecision{Allowed: true, Reason: "custom_ca"}
+		}
+		fmt.Printf("自定义根证书校验失败 - 域名: %s, 错误: %v\n", host, err)
+	}
+
+	for _, exceptionHost := range certConfig.AllowedErrorHosts {
+		if MatchHost(host, exceptionHost) {
+			return CertificateDecision{Allowed: true, Reason: "host_exception:" + exceptionHost}
+		}
+	}
+
+	if certConfig.Mode == CertificateModePermissive {
+		return CertificateDecision{Allowed: true, Reason: "permissive"}
+	}
+	return CertificateDecision{Reason: "strict"}
+}
+
+// VerifyPins 校验正常建立的连接是否符合SPKI固定配置
+// 未配置固定的域名始终通过
+func (p *CertificatePolicy) VerifyPins(host string, chain [][]byte, account ...string) bool {
+	pins := matchPins(host, p.config(account...).Certificate.Pins)
+	if len(pins) == 0 {
+		return true
+	}
+	return chainMatchesPins(chain, pins)
+}
+
+// matchPins 获取域名对应的SPKI固定值
+func matchPins(host string, pins []config.CertificatePin) []string {
+	var result []string
+	for _, pin := range pins {
+		if MatchHost(host, pin.Host) {
+			result = append(result, pin.SPKISha256...)
+		}
+	}
+	return result
+}
+
+// chainMatchesPins 检查证书链中是否有公钥与固定值匹配
+func chainMatchesPins(chain [][]byte, pins []string) bool {
+	for _, der := range chain {
+		hash, err := SPKIHash(der)
+		if err != nil {
+			continue
+		}
+		for _, pin := range pins {
+			if hash == pin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SPKIHash 计算DER编码证书的SPKI SHA256（base64编码，与HPKP格式一致）
+func SPKIHash(der []byte) (string, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// verifyWithCustomCA 使用自定义根证书校验证书链
+func (p *CertificatePolicy) verifyWithCustomCA(caFile, host string, chain [][]byte) error {
+	pool, err := p.loadCustomCA(caFile)
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("证书链为空")
+	}
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, der := range chain[1:] {
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         pool,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// loadCustomCA 加载自定义根证书（PEM），配置的文件路径变化时重新加载
+func (p *CertificatePolicy) loadCustomCA(caFile string) (*x509.CertPool, error) {
+	p.caLock.Lock()
+	defer p.caLock.Unlock()
+	if p.caFile == caFile && (p.caPool != nil || p.caError != nil) {
+		return p.caPool, p.caError
+	}
+	p.caFile = caFile
+	p.caPool, p.caError = nil, nil
+
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		p.caError = fmt.Errorf("读取自定义根证书失败: %v", err)
+		return nil, p.caError
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		p.caError = fmt.Errorf("自定义根证书中没有有效的证书: %s", caFile)
+		return nil, p.caError
+	}
+	p.caPool = pool
+	return pool, nil
+}
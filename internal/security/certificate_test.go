@@ -0,0 +1,76 @@
+package security
+
+import (
+	"cef/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestCertificate 生成自签名证书，返回DER编码
+func newTestCertificate(t *testing.T, host string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestCertificatePolicy_PinsNeverAllowErrors(t *testing.T) {
+	const (
+		errCertCommonNameInvalid = -200
+		errCertDateInvalid       = -201
+	)
+	der := newTestCertificate(t, "pinned.example.com")
+	pin, err := SPKIHash(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.BrowserConfig{}
+	cfg.Certificate.Mode = CertificateModeStrict
+	cfg.Certificate.Pins = []config.CertificatePin{{Host: "example.com", SPKISha256: []string{pin}}}
+	policy := NewCertificatePolicy(func(...string) *config.BrowserConfig { return cfg })
+
+	chain := [][]byte{der}
+	for _, certError := range []int32{errCertCommonNameInvalid, errCertDateInvalid, errCertAuthorityInvalid} {
+		if decision := policy.OnCertificateError("pinned.example.com", certError, chain); decision.Allowed {
+			t.Fatalf("certificate error %d allowed by pin match: %+v", certError, decision)
+		}
+	}
+
+	// 固定匹配时仍按域名例外处理
+	cfg.Certificate.AllowedErrorHosts = []string{"pinned.example.com"}
+	if decision := policy.OnCertificateError("pinned.example.com", errCertDateInvalid, chain); !decision.Allowed {
+		t.Fatalf("host exception ignored for pinned host: %+v", decision)
+	}
+
+	// 固定不匹配时拒绝，域名例外和宽松模式都不能放行
+	cfg.Certificate.Mode = CertificateModePermissive
+	other := [][]byte{newTestCertificate(t, "pinned.example.com")}
+	if decision := policy.OnCertificateError("pinned.example.com", errCertDateInvalid, other); decision.Allowed || decision.Reason != "spki_pin_mismatch" {
+		t.Fatalf("pin mismatch allowed: %+v", decision)
+	}
+	if !policy.VerifyPins("pinned.example.com", chain) || policy.VerifyPins("pinned.example.com", other) {
+		t.Fatal("VerifyPins result incorrect")
+	}
+	if !policy.VerifyPins("unpinned.com", other) {
+		t.Fatal("host without pins rejected")
+	}
+}
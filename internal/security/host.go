@@ -0,0 +1,166 @@
+// Package security 主机名规范化与匹配
+// 在白名单匹配前统一处理国际化域名、末尾点号和IP字面量，避免绕过白名单
+package security
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// hostProfile 与浏览器一致的主机名IDNA转换规则
+// idna.Lookup会校验STD3规则和连字符位置，拒绝a_b.example.com、r3---sn-abc.googlevideo.com等浏览器可以访问的真实主机名，
+// 这里只做映射和punycode转换，非法字符由forbiddenHostChars单独检查
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+	idna.CheckHyphens(false),
+)
+
+// forbiddenHostChars 浏览器URL规范中主机名不允许出现的字符
+const forbiddenHostChars = "\x00\t\n\r #%/:<>?@[\\]^|"
+
+// NormalizeHost 将主机名转换为规范形式
+// IP地址统一为标准文本形式（IPv4映射的IPv6地址转为IPv4，数字简写的IPv4按浏览器规则解析），
+// 域名去除末尾点号后按IDNA规则转换为小写punycode
+func NormalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	host = strings.TrimRight(host, ".")
+	if host == "" {
+		return "", errors.New("主机名为空")
+	}
+	if addr, ok := parseIPHost(host); ok {
+		return addr.String(), nil
+	}
+	if endsInNumber(host) {
+		// 最后一段为数字但不是合法IPv4的主机名，浏览器同样视为无效
+		return "", fmt.Errorf("主机名不合法: %s", host)
+	}
+	ascii, err := hostProfile.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("主机名不合法: %v", err)
+	}
+	if strings.ContainsAny(ascii, forbiddenHostChars) {
+		return "", fmt.Errorf("主机名包含非法字符: %s", host)
+	}
+	ascii = strings.TrimRight(strings.ToLower(ascii), ".")
+	// IDNA映射后可能得到IP形式的主机名，如全角数字
+	if addr, ok := parseIPHost(ascii); ok {
+		return addr.String(), nil
+	}
+	return ascii, nil
+}
+
+// MatchHost 检查主机名是否匹配规则
+// 规则可以是域名（支持精确匹配和子域名匹配）、IP地址或CIDR网段，IP主机名只与IP类规则匹配
+func MatchHost(host, pattern string) bool {
+	normalizedHost, err := NormalizeHost(host)
+	if err != nil {
+		return false
+	}
+	return matchNormalizedHost(normalizedHost, pattern)
+}
+
+// matchNormalizedHost 使用已规范化的主机名匹配规则
+func matchNormalizedHost(host, pattern string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	hostAddr, hostIsIP := parseIPHost(host)
+
+	if strings.Contains(pattern, "/") {
+		prefix, err := netip.ParsePrefix(pattern)
+		if err != nil || !hostIsIP {
+			return false
+		}
+		return prefix.Masked().Contains(hostAddr)
+	}
+
+	normalizedPattern, err := NormalizeHost(pattern)
+	if err != nil {
+		return false
+	}
+	if patternAddr, ok := parseIPHost(normalizedPattern); ok {
+		return hostIsIP && patternAddr == hostAddr
+	}
+	if hostIsIP {
+		return false
+	}
+	return host == normalizedPattern || strings.HasSuffix(host, "."+normalizedPattern)
+}
+
+// parseIPHost 解析IP形式的主机名
+func parseIPHost(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		// 去除IPv6区域标识，并将IPv4映射地址转换为IPv4
+		return addr.WithZone("").Unmap(), true
+	}
+	return parseIPv4Host(host)
+}
+
+// parseIPv4Host 按WHATWG URL规范解析IPv4主机名
+// 支持浏览器接受的简写形式，如127.1、0x7f.0.0.1、2130706433
+func parseIPv4Host(host string) (netip.Addr, bool) {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	numbers := make([]uint64, 0, len(parts))
+	for _, part := range parts {
+		number, ok := parseIPv4Number(part)
+		if !ok {
+			return netip.Addr{}, false
+		}
+		numbers = append(numbers, number)
+	}
+	last := len(numbers) - 1
+	for _, number := range numbers[:last] {
+		if number > 255 {
+			return netip.Addr{}, false
+		}
+	}
+	if numbers[last] >= uint64(1)<<(8*uint(4-last)) {
+		return netip.Addr{}, false
+	}
+	ipv4 := numbers[last]
+	for i, number := range numbers[:last] {
+		ipv4 += number << (8 * uint(3-i))
+	}
+	return netip.AddrFrom4([4]byte{byte(ipv4 >> 24), byte(ipv4 >> 16), byte(ipv4 >> 8), byte(ipv4)}), true
+}
+
+// endsInNumber 检查主机名最后一段是否为数字
+func endsInNumber(host string) bool {
+	labels := strings.Split(host, ".")
+	_, ok := parseIPv4Number(labels[len(labels)-1])
+	return ok
+}
+
+// parseIPv4Number 解析IPv4的单个部分，支持十进制、0x开头的十六进制和0开头的八进制
+func parseIPv4Number(part string) (uint64, bool) {
+	if part == "" {
+		return 0, false
+	}
+	base := 10
+	if len(part) >= 2 && (strings.HasPrefix(part, "0x") || strings.HasPrefix(part, "0X")) {
+		part = part[2:]
+		base = 16
+		if part == "" {
+			return 0, true
+		}
+	} else if len(part) >= 2 && part[0] == '0' {
+		part = part[1:]
+		base = 8
+	}
+	number, err := strconv.ParseUint(part, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
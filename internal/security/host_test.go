@@ -0,0 +1,140 @@
+package security
+
+import (
+	"cef/internal/config"
+	"testing"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{host: "Agent.OceanEngine.com", want: "agent.oceanengine.com"},
+		{host: "agent.oceanengine.com.", want: "agent.oceanengine.com"},
+		{host: "agent.oceanengine.com..", want: "agent.oceanengine.com"},
+		{host: "ｏｃｅａｎｅｎｇｉｎｅ.com", want: "oceanengine.com"},
+		{host: "例子.中国", want: "xn--fsqu00a.xn--fiqs8s"},
+		{host: "xn--fsqu00a.xn--fiqs8s", want: "xn--fsqu00a.xn--fiqs8s"},
+		{host: "оceanengine.com", want: "xn--ceanengine-dvi.com"},
+		{host: "127.0.0.1", want: "127.0.0.1"},
+		{host: "127.1", want: "127.0.0.1"},
+		{host: "2130706433", want: "127.0.0.1"},
+		{host: "0x7f.0.0.1", want: "127.0.0.1"},
+		{host: "0177.0.0.1", want: "127.0.0.1"},
+		{host: "127.0.0.1.", want: "127.0.0.1"},
+		{host: "[::1]", want: "::1"},
+		{host: "::ffff:127.0.0.1", want: "127.0.0.1"},
+		{host: "0:0:0:0:0:0:0:1", want: "::1"},
+		{host: "a_b.example.com", want: "a_b.example.com"},
+		{host: "r3---sn-abc.googlevideo.com", want: "r3---sn-abc.googlevideo.com"},
+		{host: "ab--cd.example.com", want: "ab--cd.example.com"},
+		{host: "-leading.example.com", want: "-leading.example.com"},
+		{host: "", wantErr: true},
+		{host: "a b.example.com", wantErr: true},
+		{host: "a%2eb.example.com", wantErr: true},
+		{host: "example.256", wantErr: true},
+		{host: "256.256.256.256", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeHost(tt.host)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeHost(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		pattern string
+		want    bool
+	}{
+		{host: "oceanengine.com", pattern: "oceanengine.com", want: true},
+		{host: "agent.oceanengine.com", pattern: "oceanengine.com", want: true},
+		{host: "agent.oceanengine.com.", pattern: "OceanEngine.com", want: true},
+		{host: "evil-oceanengine.com", pattern: "oceanengine.com", want: false},
+		{host: "a_b.oceanengine.com", pattern: "oceanengine.com", want: true},
+		{host: "r3---sn-abc.googlevideo.com", pattern: "googlevideo.com", want: true},
+		{host: "оceanengine.com", pattern: "oceanengine.com", want: false},
+		{host: "localhost", pattern: "localhost", want: true},
+		{host: "127.0.0.1", pattern: "127.0.0.1", want: true},
+		{host: "127.1", pattern: "127.0.0.1", want: true},
+		{host: "127.0.0.2", pattern: "127.0.0.1", want: false},
+		{host: "127.0.0.2", pattern: "127.0.0.0/8", want: true},
+		{host: "10.0.0.1", pattern: "127.0.0.0/8", want: false},
+		{host: "::1", pattern: "::1/128", want: true},
+		{host: "::ffff:127.0.0.1", pattern: "127.0.0.0/8", want: true},
+		{host: "1.0.0.127", pattern: "127.0.0.1", want: false},
+		{host: "127.0.0.1", pattern: "0.1", want: false},
+		{host: "localhost", pattern: "127.0.0.0/8", want: false},
+	}
+	for _, tt := range tests {
+		if got := MatchHost(tt.host, tt.pattern); got != tt.want {
+			t.Errorf("MatchHost(%q, %q) = %v, want %v", tt.host, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestWhitelistValidator_IsURLAllowed(t *testing.T) {
+	validator := NewWhitelistValidator(func(...string) *config.WhitelistConfig {
+		return &config.WhitelistConfig{
+			AllowedDomains:    []string{"oceanengine.com", "localhost", "127.0.0.1", "10.0.0.0/8", "例子.中国"},
+			NotAllowedDomains: []string{"business.oceanengine.com", "10.1.0.0/16"},
+		}
+	})
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://agent.oceanengine.com/", want: true},
+		{url: "https://agent.oceanengine.com./", want: true},
+		{url: "https://AGENT.OCEANENGINE.COM/", want: true},
+		{url: "https://business.oceanengine.com/", want: false},
+		{url: "https://business.oceanengine.com./", want: false},
+		{url: "https://Business.OceanEngine.com/", want: false},
+		{url: "https://оceanengine.com/", want: false},
+		{url: "https://xn--fsqu00a.xn--fiqs8s/", want: true},
+		{url: "https://例子.中国/", want: true},
+		{url: "http://localhost:22022/index.html", want: true},
+		{url: "http://127.0.0.1:22022/", want: true},
+		{url: "http://127.1:22022/", want: true},
+		{url: "http://2130706433/", want: true},
+		{url: "http://127.0.0.2/", want: false},
+		{url: "http://10.2.3.4/", want: true},
+		{url: "http://10.1.3.4/", want: false},
+		{url: "http://[::1]/", want: false},
+		{url: "https://example.com/", want: false},
+		{url: AdLoginUrl, want: false},
+	}
+	for _, tt := range tests {
+		if got := validator.IsURLAllowed(tt.url); got != tt.want {
+			t.Errorf("IsURLAllowed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestWhitelistValidator_IsDomainAllowed(t *testing.T) {
+	validator := NewWhitelistValidator(func(...string) *config.WhitelistConfig {
+		return &config.WhitelistConfig{
+			AllowedDomains:    []string{"oceanengine.com"},
+			NotAllowedDomains: []string{"business.oceanengine.com"},
+		}
+	})
+	for domain, want := range map[string]bool{
+		".oceanengine.com":          true,
+		"ad.oceanengine.com":        true,
+		".business.oceanengine.com": false,
+		".example.com":              false,
+		".":                         false,
+	} {
+		if got := validator.IsDomainAllowed(domain); got != want {
+			t.Errorf("IsDomainAllowed(%q) = %v, want %v", domain, got, want)
+		}
+	}
+}
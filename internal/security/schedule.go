@@ -0,0 +1,137 @@
+// Package security 访问时间段控制
+// 校验白名单规则上配置的允许访问星期、时间范围和时区
+package security
+
+import (
+	"cef/internal/config"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 星期缩写和全称与time.Weekday的对应关系，不区分大小写，拼写错误的星期视为配置错误
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ValidateRules 校验白名单规则的访问时间段配置，用于加载配置时提前发现错误
+func ValidateRules(whitelist *config.WhitelistConfig) error {
+	for _, rule := range whitelist.Rules {
+		if rule.Schedule == nil {
+			continue
+		}
+		if err := validateSchedule(rule.Schedule); err != nil {
+			return fmt.Errorf("规则%s的访问时间段配置无效: %v", rule.Domain, err)
+		}
+	}
+	return nil
+}
+
+// validateSchedule 校验时区、星期和全部时间范围，不依赖当前时间
+func validateSchedule(schedule *config.AccessSchedule) error {
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return fmt.Errorf("时区解析失败: %v", err)
+		}
+	}
+	for _, day := range schedule.Days {
+		if _, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]; !ok {
+			return fmt.Errorf("星期配置无效: %s", day)
+		}
+	}
+	for _, timeRange := range schedule.TimeRanges {
+		if _, _, err := parseTimeRange(timeRange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inSchedule 检查指定时间是否在允许访问的时间段内
+// 配置无法解析时按不允许处理，避免错误配置放开访问
+func inSchedule(schedule *config.AccessSchedule, now time.Time) (bool, error) {
+	if schedule == nil {
+		return true, nil
+	}
+	if schedule.Timezone != "" {
+		location, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return false, fmt.Errorf("时区解析失败: %v", err)
+		}
+		now = now.In(location)
+	}
+
+	if len(schedule.Days) > 0 {
+		// 先校验全部星期，避免拼写错误的星期因排在匹配项之后而不被发现
+		matched := false
+		for _, day := range schedule.Days {
+			weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+			if !ok {
+				return false, fmt.Errorf("星期配置无效: %s", day)
+			}
+			if weekday == now.Weekday() {
+				matched = true
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if len(schedule.TimeRanges) == 0 {
+		return true, nil
+	}
+	minute := now.Hour()*60 + now.Minute()
+	allowed := false
+	for _, timeRange := range schedule.TimeRanges {
+		start, end, err := parseTimeRange(timeRange)
+		if err != nil {
+			return false, err
+		}
+		if start < end {
+			allowed = allowed || (minute >= start && minute < end)
+		} else {
+			// 跨零点的时间范围，如22:00-06:00
+			allowed = allowed || minute >= start || minute < end
+		}
+	}
+	return allowed, nil
+}
+
+// parseTimeRange 解析HH:MM-HH:MM格式的时间范围，返回当天的起止分钟数
+// 起止时间相同的范围含义不明确，视为配置错误，全天访问使用00:00-24:00
+func parseTimeRange(timeRange string) (start, end int, err error) {
+	parts := strings.Split(timeRange, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("时间范围配置无效: %s", timeRange)
+	}
+	if start, err = parseClock(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(parts[1]); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("时间范围的起止时间相同: %s", timeRange)
+	}
+	return start, end, nil
+}
+
+// parseClock 解析HH:MM格式的时间，返回当天的分钟数，24:00表示当天结束
+func parseClock(clock string) (int, error) {
+	clock = strings.TrimSpace(clock)
+	if clock == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("时间配置无效: %s", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,154 @@
+package security
+
+import (
+	"cef/internal/config"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		timeRange string
+		start     int
+		end       int
+		wantErr   bool
+	}{
+		{timeRange: "09:00-18:00", start: 9 * 60, end: 18 * 60},
+		{timeRange: " 09:30 - 18:15 ", start: 9*60 + 30, end: 18*60 + 15},
+		{timeRange: "22:00-06:00", start: 22 * 60, end: 6 * 60},
+		{timeRange: "00:00-24:00", start: 0, end: 24 * 60},
+		{timeRange: "18:00-24:00", start: 18 * 60, end: 24 * 60},
+		{timeRange: "09:00", wantErr: true},
+		{timeRange: "09:00-18:00-20:00", wantErr: true},
+		{timeRange: "25:00-26:00", wantErr: true},
+		{timeRange: "09:60-10:00", wantErr: true},
+		{timeRange: "24:01-10:00", wantErr: true},
+		{timeRange: "nine-five", wantErr: true},
+		{timeRange: "09:00-09:00", wantErr: true},
+		{timeRange: "00:00-00:00", wantErr: true},
+		{timeRange: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		start, end, err := parseTimeRange(tt.timeRange)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTimeRange(%q) expected error", tt.timeRange)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTimeRange(%q) error: %v", tt.timeRange, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("parseTimeRange(%q) = %d-%d, want %d-%d", tt.timeRange, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestInSchedule(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-01-01是星期一
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, shanghai)
+	}
+	workdays := &config.AccessSchedule{Days: []string{"mon", "Tuesday", " WED ", "thu", "fri"}, TimeRanges: []string{"09:00-18:00"}}
+	tests := []struct {
+		name     string
+		schedule *config.AccessSchedule
+		now      time.Time
+		want     bool
+		wantErr  bool
+	}{
+		{name: "no schedule", schedule: nil, now: at(1, 3, 0), want: true},
+		{name: "empty schedule", schedule: &config.AccessSchedule{}, now: at(1, 3, 0), want: true},
+		{name: "weekday in range", schedule: workdays, now: at(1, 9, 0), want: true},
+		{name: "full day name", schedule: workdays, now: at(2, 12, 0), want: true},
+		{name: "day with spaces and case", schedule: workdays, now: at(3, 12, 0), want: true},
+		{name: "end exclusive", schedule: workdays, now: at(1, 18, 0), want: false},
+		{name: "before start", schedule: workdays, now: at(1, 8, 59), want: false},
+		{name: "weekend", schedule: workdays, now: at(6, 12, 0), want: false},
+		{name: "days only", schedule: &config.AccessSchedule{Days: []string{"sun"}}, now: at(7, 23, 59), want: true},
+		{name: "overnight before midnight", schedule: &config.AccessSchedule{TimeRanges: []string{"22:00-06:00"}}, now: at(1, 23, 30), want: true},
+		{name: "overnight after midnight", schedule: &config.AccessSchedule{TimeRanges: []string{"22:00-06:00"}}, now: at(2, 5, 59), want: true},
+		{name: "overnight outside", schedule: &config.AccessSchedule{TimeRanges: []string{"22:00-06:00"}}, now: at(2, 6, 0), want: false},
+		{name: "until 24:00", schedule: &config.AccessSchedule{TimeRanges: []string{"18:00-24:00"}}, now: at(1, 23, 59), want: true},
+		{name: "24:00 excludes midnight", schedule: &config.AccessSchedule{TimeRanges: []string{"18:00-24:00"}}, now: at(2, 0, 0), want: false},
+		{name: "whole day", schedule: &config.AccessSchedule{TimeRanges: []string{"00:00-24:00"}}, now: at(1, 0, 0), want: true},
+		{name: "second range", schedule: &config.AccessSchedule{TimeRanges: []string{"09:00-12:00", "13:00-18:00"}}, now: at(1, 13, 0), want: true},
+		{name: "between ranges", schedule: &config.AccessSchedule{TimeRanges: []string{"09:00-12:00", "13:00-18:00"}}, now: at(1, 12, 30), want: false},
+		{
+			// 上海时间星期一10:00是UTC星期一02:00，不在UTC的09:00-18:00内
+			name:     "timezone converts time",
+			schedule: &config.AccessSchedule{TimeRanges: []string{"09:00-18:00"}, Timezone: "UTC"},
+			now:      at(1, 10, 0),
+			want:     false,
+		},
+		{
+			// 上海时间星期二07:00是UTC星期一23:00
+			name:     "timezone converts weekday",
+			schedule: &config.AccessSchedule{Days: []string{"mon"}, Timezone: "UTC"},
+			now:      at(2, 7, 0),
+			want:     true,
+		},
+		{
+			name:     "timezone in range",
+			schedule: &config.AccessSchedule{TimeRanges: []string{"09:00-18:00"}, Timezone: "America/New_York"},
+			now:      at(2, 0, 0), // 纽约时间星期一11:00
+			want:     true,
+		},
+		{name: "invalid timezone", schedule: &config.AccessSchedule{Timezone: "Mars/Base"}, now: at(1, 12, 0), wantErr: true},
+		{name: "invalid day", schedule: &config.AccessSchedule{Days: []string{"funday"}}, now: at(1, 12, 0), wantErr: true},
+		{name: "misspelled day", schedule: &config.AccessSchedule{Days: []string{"monxyz"}}, now: at(1, 12, 0), wantErr: true},
+		{name: "misspelled day after match", schedule: &config.AccessSchedule{Days: []string{"mon", "tuesdya"}}, now: at(1, 12, 0), wantErr: true},
+		{name: "equal bounds", schedule: &config.AccessSchedule{TimeRanges: []string{"09:00-09:00"}}, now: at(1, 9, 0), wantErr: true},
+		{name: "invalid range after match", schedule: &config.AccessSchedule{TimeRanges: []string{"09:00-18:00", "18:00-18:00"}}, now: at(1, 12, 0), wantErr: true},
+		{name: "invalid range", schedule: &config.AccessSchedule{TimeRanges: []string{"9-5"}}, now: at(1, 12, 0), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inSchedule(tt.schedule, tt.now)
+			if tt.wantErr {
+				if err == nil || got {
+					t.Fatalf("inSchedule() = %v, %v; want false with error", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("inSchedule() error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("inSchedule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *config.AccessSchedule
+		wantErr  bool
+	}{
+		{name: "no schedule"},
+		{name: "valid", schedule: &config.AccessSchedule{Days: []string{"Monday", "fri"}, TimeRanges: []string{"22:00-06:00"}, Timezone: "Asia/Shanghai"}},
+		{name: "misspelled day", schedule: &config.AccessSchedule{Days: []string{"firday"}}, wantErr: true},
+		{name: "truncated day", schedule: &config.AccessSchedule{Days: []string{"thur"}}, wantErr: true},
+		{name: "equal bounds", schedule: &config.AccessSchedule{TimeRanges: []string{"12:00-12:00"}}, wantErr: true},
+		// 当天不在允许的星期内时时间范围也要校验
+		{name: "invalid range on other days", schedule: &config.AccessSchedule{Days: []string{"sun"}, TimeRanges: []string{"9-5"}}, wantErr: true},
+		{name: "invalid timezone", schedule: &config.AccessSchedule{Timezone: "Mars/Base"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whitelist := &config.WhitelistConfig{Rules: []config.WhitelistRule{{Domain: "example.com", Schedule: tt.schedule}}}
+			if err := ValidateRules(whitelist); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -7,78 +7,171 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
 	AdLoginUrl = "https://ad.oceanengine.com/pages/login/index.html"
 )
 
+// 被阻止时的处理方式
+const (
+	BlockedActionRedirect = "redirect" // 重定向到配置的URL
+	BlockedActionPage     = "page"     // 展示阻止说明页面
+	BlockedActionCancel   = "cancel"   // 静默取消
+)
+
 // WhitelistValidator 白名单验证器
 type WhitelistValidator struct {
 	config func(...string) *config.WhitelistConfig
+	now    func() time.Time // 当前时间，用于时间段规则校验
 }
 
 // NewWhitelistValidator 创建新的白名单验证器实例
 func NewWhitelistValidator(cfg func(...string) *config.WhitelistConfig) *WhitelistValidator {
 	return &WhitelistValidator{
 		config: cfg,
+		now:    time.Now,
 	}
 }
 
+// Decision 白名单校验结果
+type Decision struct {
+	Allowed bool   // 是否允许访问
+	Rule    string // 命中的规则，用于审计
+	Message string // 阻止原因，为空时使用全局的阻止消息
+}
+
 // IsURLAllowed 检查URL是否被允许访问
 // 支持精确匹配和子域名匹配两种模式
 func (v *WhitelistValidator) IsURLAllowed(requestURL string, account ...string) bool {
+	return v.Evaluate(requestURL, account...).Allowed
+}
+
+// Evaluate 校验URL并返回命中的规则
+func (v *WhitelistValidator) Evaluate(requestURL string, account ...string) Decision {
 	// 解析URL
 	parsedURL, err := url.Parse(requestURL)
 	if err != nil {
 		fmt.Printf("URL解析失败: %v\n", err)
-		return false
+		return Decision{Rule: "invalid_url"}
 	}
 	if parsedURL.Scheme == "bytedance" {
-		return true
+		return Decision{Allowed: true, Rule: "scheme:bytedance"}
 	}
 	// 特殊判断AD、千川系统的登录页面,不允许跳转
 	if requestURL == AdLoginUrl {
-		return false
+		return Decision{Rule: "ad_login_url"}
 	}
 
-	// 获取域名并转换为小写
-	hostname := strings.ToLower(parsedURL.Hostname())
+	// 获取规范化的主机名（IDNA、末尾点号、IP字面量）
+	hostname, err := NormalizeHost(parsedURL.Hostname())
+	if err != nil {
+		fmt.Printf("主机名规范化失败: %v\n", err)
+		return Decision{Rule: "invalid_host"}
+	}
 
 	// 检查是否在白名单中
-	inAllowedDomain := false
+	matchedDomain := ""
 	for _, allowedDomain := range v.config(account...).AllowedDomains {
-		allowedDomain = strings.ToLower(allowedDomain)
-
-		// 支持精确匹配和子域名匹配
-		if hostname == allowedDomain || strings.HasSuffix(hostname, "."+allowedDomain) {
-			inAllowedDomain = true
+		// 支持精确匹配、子域名匹配、IP和CIDR网段匹配
+		if matchNormalizedHost(hostname, allowedDomain) {
+			matchedDomain = strings.ToLower(allowedDomain)
 		}
 	}
-	if !inAllowedDomain {
-		return false
+	if matchedDomain == "" {
+		return Decision{Rule: "not_in_allowed_domains"}
 	}
 	// 检查是否在黑名单中
 	for _, notAllowedDomain := range v.config(account...).NotAllowedDomains {
-		notAllowedDomain = strings.ToLower(notAllowedDomain)
+		if matchNormalizedHost(hostname, notAllowedDomain) {
+			return Decision{Rule: "not_allowed_domains:" + strings.ToLower(notAllowedDomain)}
+		}
+	}
 
-		// 支持精确匹配和子域名匹配
-		if hostname == notAllowedDomain || strings.HasSuffix(hostname, "."+notAllowedDomain) {
-			return false
+	// 检查是否在规则允许访问的时间段内
+	if rule := v.MatchRule(requestURL, account...); rule != nil && rule.Schedule != nil {
+		ok, err := inSchedule(rule.Schedule, v.now())
+		if err != nil {
+			fmt.Printf("访问时间段配置错误 - 域名: %s, 错误: %v\n", rule.Domain, err)
+		}
+		if !ok {
+			message := rule.Schedule.BlockedMessage
+			if message == "" {
+				message = v.config(account...).OutOfScheduleMsg
+			}
+			return Decision{Rule: "schedule:" + strings.ToLower(rule.Domain), Message: message}
 		}
 	}
 
+	return Decision{Allowed: true, Rule: "allowed_domains:" + matchedDomain}
+}
+
+// IsDomainAllowed 检查域名是否在白名单内且不在黑名单内，不校验访问时间段
+// 用于Cookie等不对应具体请求的场景
+func (v *WhitelistValidator) IsDomainAllowed(domain string, account ...string) bool {
+	hostname, err := NormalizeHost(strings.TrimPrefix(domain, "."))
+	if err != nil || hostname == "" {
+		return false
+	}
+	whitelist := v.config(account...)
+	allowed := false
+	for _, allowedDomain := range whitelist.AllowedDomains {
+		if matchNormalizedHost(hostname, allowedDomain) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+	for _, notAllowedDomain := range whitelist.NotAllowedDomains {
+		if matchNormalizedHost(hostname, notAllowedDomain) {
+			return false
+		}
+	}
 	return true
 }
 
 // GetBlockedMessage 获取访问被阻止时的消息
-func (v *WhitelistValidator) GetBlockedMessage() string {
-	return v.config().BlockedMessage
+func (v *WhitelistValidator) GetBlockedMessage(account ...string) string {
+	return v.config(account...).BlockedMessage
 }
 
 // GetRedirectURL 获取被阻止时的重定向URL
-func (v *WhitelistValidator) GetRedirectURL() string {
-	return v.config().RedirectBlockedTo
+func (v *WhitelistValidator) GetRedirectURL(account ...string) string {
+	return v.config(account...).RedirectBlockedTo
+}
+
+// MatchRule 查找URL对应的域名规则，没有匹配时返回nil
+func (v *WhitelistValidator) MatchRule(requestURL string, account ...string) *config.WhitelistRule {
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return nil
+	}
+	hostname, err := NormalizeHost(parsedURL.Hostname())
+	if err != nil {
+		return nil
+	}
+	rules := v.config(account...).Rules
+	for i := range rules {
+		if matchNormalizedHost(hostname, rules[i].Domain) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// GetBlockedAction 获取URL被阻止时的处理方式
+// 优先使用匹配规则上的配置，其次使用全局配置，默认重定向
+func (v *WhitelistValidator) GetBlockedAction(requestURL string, account ...string) string {
+	if rule := v.MatchRule(requestURL, account...); rule != nil && rule.BlockedAction != "" {
+		return rule.BlockedAction
+	}
+	if action := v.config(account...).BlockedAction; action != "" {
+		return action
+	}
+	return BlockedActionRedirect
 }
 
 // LogBlockedAccess 记录被阻止的访问尝试
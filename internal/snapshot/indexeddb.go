@@ -0,0 +1,364 @@
+// Package snapshot IndexedDB快照
+// 在页面中读取源的IndexedDB数据库结构和记录，恢复时在文档开始时重建缺少的数据库和对象仓库并写回缺少的记录；
+// 键和值按结构化克隆支持的类型编码为JSON，Blob、File等无法编码的记录不保存
+package snapshot
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	// MaxIndexedDBRecords 每个对象仓库最多保存的记录数量
+	MaxIndexedDBRecords = 10000
+	// MaxIndexedDBSize 每个源最多保存的IndexedDB记录编码后大小（字符数）
+	MaxIndexedDBSize = 32 << 20
+)
+
+// IndexedDBDatabase 单个IndexedDB数据库
+type IndexedDBDatabase struct {
+	Name    string           `json:"name"`
+	Version int64            `json:"version"`
+	Stores  []IndexedDBStore `json:"stores"`
+}
+
+// UnmarshalJSON 兼容早期快照中只记录数据库名称的字符串，这样的数据库没有版本和对象仓库，恢复时跳过
+func (d *IndexedDBDatabase) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		*d = IndexedDBDatabase{Name: name}
+		return nil
+	}
+	type database IndexedDBDatabase
+	return json.Unmarshal(data, (*database)(d))
+}
+
+// IndexedDBStore 对象仓库的结构和记录
+type IndexedDBStore struct {
+	Name          string            `json:"name"`
+	KeyPath       json.RawMessage   `json:"key_path"` // null、字符串或字符串数组
+	AutoIncrement bool              `json:"auto_increment,omitempty"`
+	Indexes       []IndexedDBIndex  `json:"indexes,omitempty"`
+	Records       []IndexedDBRecord `json:"records,omitempty"`
+	Skipped       int               `json:"skipped,omitempty"` // 无法编码或超出数量、大小限制而未保存的记录数量
+}
+
+// IndexedDBIndex 对象仓库的索引
+type IndexedDBIndex struct {
+	Name       string          `json:"name"`
+	KeyPath    json.RawMessage `json:"key_path"`
+	Unique     bool            `json:"unique,omitempty"`
+	MultiEntry bool            `json:"multi_entry,omitempty"`
+}
+
+// IndexedDBRecord 对象仓库中的一条记录，键和值为indexedDBCodec编码后的JSON
+type IndexedDBRecord struct {
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// indexedDBCodec 键和值的编码脚本
+// 字符串、布尔值、null和有限数字保持原样，数组逐项编码；其他值编码为{"$t": 类型, "v": 内容}，
+// 普通对象也使用标记，避免与页面数据中的"$t"属性混淆。Blob、File、循环引用等无法编码时抛出异常
+const indexedDBCodec = `
+    function toBase64(buffer, offset, length) {
+        var bytes = new Uint8Array(buffer, offset, length), binary = "";
+        for (var i = 0; i < bytes.length; i += 0x8000) {
+            binary += String.fromCharCode.apply(null, bytes.subarray(i, i + 0x8000));
+        }
+        return btoa(binary);
+    }
+    function fromBase64(text) {
+        var binary = atob(text), bytes = new Uint8Array(binary.length);
+        for (var i = 0; i < binary.length; i++) {
+            bytes[i] = binary.charCodeAt(i);
+        }
+        return bytes.buffer;
+    }
+    function encode(value, seen) {
+        seen = seen || [];
+        var type = typeof value;
+        if (value === null || type === "string" || type === "boolean") {
+            return value;
+        }
+        if (type === "number") {
+            if (Object.is(value, -0)) {
+                return {"$t": "number", "v": "-0"};
+            }
+            return isFinite(value) ? value : {"$t": "number", "v": String(value)};
+        }
+        if (type === "undefined") {
+            return {"$t": "undefined"};
+        }
+        if (type === "bigint") {
+            return {"$t": "bigint", "v": value.toString()};
+        }
+        if (type !== "object" || seen.indexOf(value) >= 0) {
+            throw new TypeError("unsupported value");
+        }
+        var tag = Object.prototype.toString.call(value).slice(8, -1);
+        if (tag === "Date") {
+            return {"$t": "date", "v": value.getTime()};
+        }
+        if (tag === "RegExp") {
+            return {"$t": "regexp", "v": value.source, "f": value.flags};
+        }
+        if (tag === "ArrayBuffer") {
+            return {"$t": "buffer", "v": toBase64(value, 0, value.byteLength)};
+        }
+        if (ArrayBuffer.isView(value)) {
+            return {"$t": "view", "c": tag, "v": toBase64(value.buffer, value.byteOffset, value.byteLength)};
+        }
+        seen = seen.concat([value]);
+        var encoded, key;
+        if (Array.isArray(value)) {
+            encoded = [];
+            for (var i = 0; i < value.length; i++) {
+                encoded.push(encode(value[i], seen));
+            }
+            return encoded;
+        }
+        if (tag === "Map") {
+            encoded = [];
+            value.forEach(function (item, itemKey) {
+                encoded.push([encode(itemKey, seen), encode(item, seen)]);
+            });
+            return {"$t": "map", "v": encoded};
+        }
+        if (tag === "Set") {
+            encoded = [];
+            value.forEach(function (item) {
+                encoded.push(encode(item, seen));
+            });
+            return {"$t": "set", "v": encoded};
+        }
+        if (tag !== "Object") {
+            throw new TypeError("unsupported value: " + tag);
+        }
+        encoded = {};
+        for (key in value) {
+            if (Object.prototype.hasOwnProperty.call(value, key)) {
+                encoded[key] = encode(value[key], seen);
+            }
+        }
+        return {"$t": "object", "v": encoded};
+    }
+    function decode(value) {
+        if (value === null || typeof value !== "object") {
+            return value;
+        }
+        if (Array.isArray(value)) {
+            return value.map(decode);
+        }
+        var decoded, key;
+        switch (value["$t"]) {
+        case "number":
+            return value.v === "-0" ? -0 : Number(value.v);
+        case "undefined":
+            return undefined;
+        case "bigint":
+            return BigInt(value.v);
+        case "date":
+            return new Date(value.v);
+        case "regexp":
+            return new RegExp(value.v, value.f);
+        case "buffer":
+            return fromBase64(value.v);
+        case "view":
+            var buffer = fromBase64(value.v);
+            return value.c === "DataView" ? new DataView(buffer) : new self[value.c](buffer);
+        case "map":
+            decoded = new Map();
+            value.v.forEach(function (entry) {
+                decoded.set(decode(entry[0]), decode(entry[1]));
+            });
+            return decoded;
+        case "set":
+            decoded = new Set();
+            value.v.forEach(function (item) {
+                decoded.add(decode(item));
+            });
+            return decoded;
+        default:
+            decoded = {};
+            for (key in value.v) {
+                if (Object.prototype.hasOwnProperty.call(value.v, key)) {
+                    decoded[key] = decode(value.v[key]);
+                }
+            }
+            return decoded;
+        }
+    }
+`
+
+// IndexedDBCaptureScript 生成读取当前源所有IndexedDB数据库的脚本，脚本的值为Promise，完成后得到[]IndexedDBDatabase的JSON
+// 只读取已存在的数据库，不会创建或升级数据库
+func IndexedDBCaptureScript() string {
+	return indexedDBCaptureScript(MaxIndexedDBRecords, MaxIndexedDBSize)
+}
+
+// indexedDBCaptureScript 生成读取IndexedDB的脚本，每个对象仓库最多保存maxRecords条记录，整个源最多保存maxSize个字符
+func indexedDBCaptureScript(maxRecords, maxSize int) string {
+	return `(function (maxRecords, maxSize) {` + indexedDBCodec + `
+    var size = 0;
+    function captureStore(store) {
+        var result = {
+            name: store.name,
+            key_path: store.keyPath,
+            auto_increment: store.autoIncrement,
+            indexes: Array.prototype.map.call(store.indexNames, function (name) {
+                var index = store.index(name);
+                return {name: name, key_path: index.keyPath, unique: index.unique, multi_entry: index.multiEntry};
+            }),
+            records: [],
+            skipped: 0
+        };
+        return new Promise(function (resolve, reject) {
+            var request = store.openCursor();
+            request.onsuccess = function () {
+                var cursor = request.result;
+                if (!cursor) {
+                    resolve(result);
+                    return;
+                }
+                try {
+                    var record = {key: encode(cursor.primaryKey), value: encode(cursor.value)};
+                    var length = JSON.stringify(record).length;
+                    if (result.records.length >= maxRecords || size + length > maxSize) {
+                        throw new RangeError("limit exceeded");
+                    }
+                    size += length;
+                    result.records.push(record);
+                } catch (e) {
+                    result.skipped++;
+                }
+                cursor.continue();
+            };
+            request.onerror = function () {
+                reject(request.error);
+            };
+        });
+    }
+    function captureDatabase(name) {
+        return new Promise(function (resolve, reject) {
+            var request = indexedDB.open(name);
+            request.onupgradeneeded = function () {
+                // 数据库在读取前被删除，不创建空数据库
+                request.transaction.abort();
+            };
+            request.onsuccess = function () {
+                resolve(request.result);
+            };
+            request.onerror = function () {
+                reject(request.error);
+            };
+        }).then(function (db) {
+            var names = Array.prototype.slice.call(db.objectStoreNames);
+            var database = {name: name, version: db.version, stores: []};
+            if (names.length === 0) {
+                db.close();
+                return database;
+            }
+            var transaction = db.transaction(names, "readonly");
+            return Promise.all(names.map(function (name) {
+                return captureStore(transaction.objectStore(name));
+            })).then(function (stores) {
+                db.close();
+                database.stores = stores;
+                return database;
+            });
+        }).catch(function () {
+            return null;
+        });
+    }
+    if (!self.indexedDB || !indexedDB.databases) {
+        return Promise.resolve("[]");
+    }
+    return indexedDB.databases().then(function (infos) {
+        return Promise.all(infos.map(function (info) {
+            return captureDatabase(info.name);
+        }));
+    }).then(function (databases) {
+        return JSON.stringify(databases.filter(function (database) {
+            return database !== null;
+        }));
+    });
+})(` + strconv.Itoa(maxRecords) + `, ` + strconv.Itoa(maxSize) + `)`
+}
+
+// IndexedDBRestoreScript 生成在源的文档开始时写回IndexedDB的脚本，脚本的值为开始写回的数据库数量，没有版本的数据库跳过
+// 不存在或版本较低的数据库按快照的版本创建或升级，补建缺少的对象仓库和索引；
+// 页面中已存在的记录不覆盖，已是更高版本的数据库只写回仍存在的对象仓库。写回是异步的，单条记录写入失败时跳过
+func IndexedDBRestoreScript(databases []IndexedDBDatabase) string {
+	data, err := json.Marshal(databases)
+	if err != nil {
+		data = []byte("[]")
+	}
+	return `(function (databases) {` + indexedDBCodec + `
+    function upgrade(db, transaction, database) {
+        database.stores.forEach(function (store) {
+            var objectStore = db.objectStoreNames.contains(store.name) ? transaction.objectStore(store.name) :
+                db.createObjectStore(store.name, {keyPath: store.key_path, autoIncrement: store.auto_increment});
+            (store.indexes || []).forEach(function (index) {
+                if (!objectStore.indexNames.contains(index.name)) {
+                    objectStore.createIndex(index.name, index.key_path, {unique: index.unique, multiEntry: index.multi_entry});
+                }
+            });
+        });
+    }
+    function write(db, database) {
+        db.onversionchange = function () {
+            db.close();
+        };
+        var stores = database.stores.filter(function (store) {
+            return db.objectStoreNames.contains(store.name) && store.records && store.records.length > 0;
+        });
+        if (stores.length === 0) {
+            db.close();
+            return;
+        }
+        var transaction = db.transaction(stores.map(function (store) {
+            return store.name;
+        }), "readwrite");
+        transaction.oncomplete = transaction.onabort = function () {
+            db.close();
+        };
+        stores.forEach(function (store) {
+            var objectStore = transaction.objectStore(store.name);
+            store.records.forEach(function (record) {
+                try {
+                    var request = objectStore.keyPath === null ? objectStore.add(decode(record.value), decode(record.key)) : objectStore.add(decode(record.value));
+                    request.onerror = function (event) {
+                        // 键已存在，保留页面中的记录
+                        event.preventDefault();
+                        event.stopPropagation();
+                    };
+                } catch (e) {
+                }
+            });
+        });
+    }
+    function restore(database, version) {
+        var request = version ? indexedDB.open(database.name, version) : indexedDB.open(database.name);
+        request.onupgradeneeded = function () {
+            upgrade(request.result, request.transaction, database);
+        };
+        request.onsuccess = function () {
+            write(request.result, database);
+        };
+        request.onerror = function (event) {
+            event.preventDefault();
+            if (version && request.error && request.error.name === "VersionError") {
+                restore(database, 0);
+            }
+        };
+    }
+    databases = databases.filter(function (database) {
+        return database.version > 0;
+    });
+    databases.forEach(function (database) {
+        restore(database, database.version);
+    });
+    return databases.length;
+})(` + string(data) + `)`
+}
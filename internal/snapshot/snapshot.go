@@ -0,0 +1,186 @@
+// Package snapshot 账户会话快照
+// 将账户的Cookie和各源的localStorage、IndexedDB保存为加密文件，用于在其他机器上或清除缓存后恢复登录状态；
+// sessionStorage和Cache Storage不保存也不恢复，依赖这些存储的站点恢复后可能需要重新加载数据
+package snapshot
+
+import (
+	"bytes"
+	"cef/internal/cookies"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// Version 快照格式版本
+	Version = 1
+	// FileExt 快照文件扩展名
+	FileExt = ".snapshot"
+
+	magic         = "CEFSNAP1"
+	saltSize      = 16
+	keySize       = 32 // AES-256
+	keyIterations = 100000
+)
+
+// ErrDecrypt 口令错误或快照文件被修改
+var ErrDecrypt = errors.New("会话快照解密失败: 口令错误或文件已损坏")
+
+// Snapshot 账户会话快照
+type Snapshot struct {
+	Version   int              `json:"version"`
+	Account   string           `json:"account"`
+	CreatedAt time.Time        `json:"created_at"`
+	Cookies   []cookies.Cookie `json:"cookies"`
+	Origins   []OriginStorage  `json:"origins"`
+}
+
+// OriginStorage 单个源的localStorage和IndexedDB
+type OriginStorage struct {
+	Origin       string              `json:"origin"`               // 如https://ad.oceanengine.com
+	LocalStorage map[string]string   `json:"local_storage"`        // localStorage的键值
+	IndexedDB    []IndexedDBDatabase `json:"indexed_db,omitempty"` // IndexedDB数据库
+}
+
+// Seal 将快照压缩后使用口令加密
+// 加密格式: magic | salt | nonce | AES-256-GCM密文，密钥由口令和salt通过PBKDF2-HMAC-SHA256生成
+func Seal(snapshot *Snapshot, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("未配置会话快照口令")
+	}
+	var plain bytes.Buffer
+	writer := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := append(append([]byte(magic), salt...), nonce...)
+	// 文件头作为附加数据参与认证，修改salt或nonce都会导致解密失败
+	return aead.Seal(header, nonce, plain.Bytes(), header), nil
+}
+
+// Open 解密并解析快照
+func Open(data []byte, passphrase string) (*Snapshot, error) {
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, errors.New("不是会话快照文件")
+	}
+	if len(data) < len(magic)+saltSize {
+		return nil, ErrDecrypt
+	}
+	salt := data[len(magic) : len(magic)+saltSize]
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(magic) + saltSize + aead.NonceSize()
+	if len(data) < headerSize {
+		return nil, ErrDecrypt
+	}
+	header := data[:headerSize]
+	plain, err := aead.Open(nil, header[len(magic)+saltSize:], data[headerSize:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var snapshot Snapshot
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("解析会话快照失败: %v", err)
+	}
+	if snapshot.Version > Version {
+		return nil, fmt.Errorf("不支持的会话快照版本: %d", snapshot.Version)
+	}
+	return &snapshot, nil
+}
+
+// Save 加密快照并写入文件，先写临时文件再替换，避免写入中断损坏原有快照
+func Save(path string, snapshot *Snapshot, passphrase string) error {
+	data, err := Seal(snapshot, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Load 读取并解密快照文件，快照不属于account时返回错误
+func Load(path, account, passphrase string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := Open(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Account != account {
+		return nil, fmt.Errorf("会话快照属于账户%s，不是%s", snapshot.Account, account)
+	}
+	return snapshot, nil
+}
+
+// newAEAD 由口令和salt通过PBKDF2-HMAC-SHA256生成AES-256-GCM
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, keyIterations, keySize, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RestoreScript 生成在源的文档开始时写回localStorage的脚本，脚本的值为写入的键数量
+// 页面中已存在的键不覆盖，重复执行不会改写页面之后修改过的数据
+func RestoreScript(localStorage map[string]string) string {
+	items, err := json.Marshal(localStorage)
+	if err != nil {
+		items = []byte("{}")
+	}
+	return `(function (items) {
+    var restored = 0;
+    for (var key in items) {
+        if (Object.prototype.hasOwnProperty.call(items, key) && window.localStorage.getItem(key) === null) {
+            window.localStorage.setItem(key, items[key]);
+            restored++;
+        }
+    }
+    return restored;
+})(` + string(items) + `)`
+}
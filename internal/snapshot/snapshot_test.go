@@ -0,0 +1,309 @@
+package snapshot
+
+import (
+	"cef/internal/cookies"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	snapshot := &Snapshot{
+		Version:   Version,
+		Account:   "user@example.com",
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Cookies:   []cookies.Cookie{{Domain: ".oceanengine.com", Name: "sessionid", Value: "abc", Path: "/", Secure: true}},
+		Origins: []OriginStorage{{
+			Origin:       "https://ad.oceanengine.com",
+			LocalStorage: map[string]string{"token": "t1", "theme": "dark"},
+			IndexedDB: []IndexedDBDatabase{{Name: "app", Version: 2, Stores: []IndexedDBStore{{
+				Name:    "kv",
+				KeyPath: json.RawMessage(`null`),
+				Records: []IndexedDBRecord{{Key: json.RawMessage(`"token"`), Value: json.RawMessage(`{"$t":"date","v":0}`)}},
+			}}}},
+		}},
+	}
+	path := filepath.Join(t.TempDir(), "snapshots", "user@example.com"+FileExt)
+	if err := Save(path, snapshot, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path, "user@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("got  %+v\nwant %+v", got, snapshot)
+	}
+
+	if _, err := Load(path, "user@example.com", "wrong"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("错误的口令应返回ErrDecrypt，实际为%v", err)
+	}
+	if _, err := Load(path, "other", "secret"); err == nil {
+		t.Error("快照不属于账户时应返回错误")
+	}
+}
+
+func TestOpen_Tampered(t *testing.T) {
+	data, err := Seal(&Snapshot{Version: Version, Account: "a"}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int{len(magic), len(magic) + saltSize, len(data) - 1} {
+		tampered := append([]byte(nil), data...)
+		tampered[offset] ^= 1
+		if _, err := Open(tampered, "secret"); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("修改第%d字节后应返回ErrDecrypt，实际为%v", offset, err)
+		}
+	}
+	if _, err := Seal(&Snapshot{}, ""); err == nil {
+		t.Error("口令为空时应返回错误")
+	}
+}
+
+func TestRestoreScript(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("未安装node")
+	}
+	// 使用Map模拟localStorage，页面已有的theme不应被覆盖
+	program := `var store = new Map([["theme", "light"]]);
+var window = {localStorage: {
+    getItem: function (key) { return store.has(key) ? store.get(key) : null; },
+    setItem: function (key, value) { store.set(key, String(value)); }
+}};
+var first = ` + RestoreScript(map[string]string{"token": "t1</script> ", "theme": "dark"}) + `;
+var second = ` + RestoreScript(map[string]string{"token": "t2"}) + `;
+console.log(JSON.stringify({first: first, second: second, token: window.localStorage.getItem("token"), theme: window.localStorage.getItem("theme")}));`
+	cmd := exec.Command(node)
+	cmd.Stdin = strings.NewReader(program)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("执行脚本失败: %v\n%s", err, output)
+	}
+	var got struct {
+		First  int    `json:"first"`
+		Second int    `json:"second"`
+		Token  string `json:"token"`
+		Theme  string `json:"theme"`
+	}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("解析输出失败: %v\n%s", err, output)
+	}
+	if got.First != 1 || got.Second != 0 || got.Token != "t1</script> " || got.Theme != "light" {
+		t.Errorf("恢复结果 = %+v", got)
+	}
+}
+
+// fakeIndexedDB 在node中模拟测试用到的IndexedDB接口，记录按键的JSON保存，请求异步完成
+const fakeIndexedDB = `var self = globalThis;
+function names(list) {
+    list.contains = function (name) { return list.indexOf(name) >= 0; };
+    return list;
+}
+function later(fn) { setTimeout(fn, 0); }
+function Store(name, options) {
+    this.name = name;
+    this.keyPath = options && options.keyPath !== undefined ? options.keyPath : null;
+    this.autoIncrement = !!(options && options.autoIncrement);
+    this.indexes = {};
+    this.indexNames = names([]);
+    this.records = new Map();
+}
+Store.prototype.createIndex = function (name, keyPath, options) {
+    this.indexes[name] = {keyPath: keyPath, unique: !!options.unique, multiEntry: !!options.multiEntry};
+    this.indexNames.push(name);
+};
+Store.prototype.index = function (name) { return this.indexes[name]; };
+Store.prototype.add = function (value, key) {
+    var store = this, request = {};
+    if (store.keyPath !== null) { key = value[store.keyPath]; }
+    later(function () {
+        var id = JSON.stringify(key instanceof Date ? {date: key.getTime()} : key);
+        if (store.records.has(id)) {
+            var event = {preventDefault: function () {}, stopPropagation: function () {}};
+            request.error = {name: "ConstraintError"};
+            request.onerror(event);
+            return;
+        }
+        store.records.set(id, {key: key, value: value});
+    });
+    return request;
+};
+Store.prototype.openCursor = function () {
+    var records = Array.from(this.records.values()), position = 0, request = {};
+    function next() {
+        later(function () {
+            var record = records[position++];
+            request.result = record ? {primaryKey: record.key, value: record.value, continue: next} : null;
+            request.onsuccess();
+        });
+    }
+    next();
+    return request;
+};
+function Database(name, version) {
+    this.name = name;
+    this.version = version;
+    this.stores = {};
+    this.objectStoreNames = names([]);
+}
+Database.prototype.createObjectStore = function (name, options) {
+    this.objectStoreNames.push(name);
+    return this.stores[name] = new Store(name, options);
+};
+Database.prototype.transaction = function () {
+    var db = this, transaction = {objectStore: function (name) { return db.stores[name]; }};
+    setTimeout(function () { transaction.oncomplete && transaction.oncomplete(); }, 10);
+    return transaction;
+};
+Database.prototype.close = function () {};
+var databases = {};
+var indexedDB = {
+    databases: function () {
+        return Promise.resolve(Object.keys(databases).map(function (name) { return {name: name, version: databases[name].version}; }));
+    },
+    open: function (name, version) {
+        var request = {};
+        later(function () {
+            var db = databases[name];
+            if (db && version && version < db.version) {
+                request.error = {name: "VersionError"};
+                request.onerror({preventDefault: function () {}});
+                return;
+            }
+            var upgrade = !db || (version && version > db.version);
+            if (!db) { db = databases[name] = new Database(name, version || 1); }
+            request.result = db;
+            if (upgrade) {
+                db.version = version || 1;
+                request.transaction = {objectStore: function (store) { return db.stores[store]; }};
+                request.onupgradeneeded && request.onupgradeneeded();
+            }
+            request.onsuccess();
+        });
+        return request;
+    }
+};
+self.indexedDB = indexedDB;
+`
+
+func runNode(t *testing.T, program string) []byte {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("未安装node")
+	}
+	cmd := exec.Command(node)
+	cmd.Stdin = strings.NewReader(program)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("执行脚本失败: %v\n%s", err, output)
+	}
+	return output
+}
+
+func TestIndexedDBScripts(t *testing.T) {
+	// 源页面: app数据库包含键外置的kv仓库和键内置的users仓库，prefs数据库包含theme和lang
+	captured := runNode(t, fakeIndexedDB+`
+var app = databases.app = new Database("app", 3);
+var kv = app.createObjectStore("kv");
+kv.records.set('"token"', {key: "token", value: {"$t": "x", d: new Date(0), b: new Uint8Array([1, 2]), n: NaN, m: new Map([[1, "one"]])}});
+kv.records.set('"blob"', {key: "blob", value: new Blob(["x"])});
+var users = app.createObjectStore("users", {keyPath: "id"});
+users.createIndex("byName", "name", {unique: true});
+users.records.set("1", {key: 1, value: {id: 1, name: "a"}});
+databases.prefs = new Database("prefs", 1);
+var prefs = databases.prefs.createObjectStore("kv");
+prefs.records.set('"theme"', {key: "theme", value: "dark"});
+prefs.records.set('"lang"', {key: "lang", value: "zh"});
+`+IndexedDBCaptureScript()+`.then(function (result) { process.stdout.write(result); });`)
+	var databases []IndexedDBDatabase
+	if err := json.Unmarshal(captured, &databases); err != nil {
+		t.Fatalf("解析读取结果失败: %v\n%s", err, captured)
+	}
+	if len(databases) != 2 || databases[0].Name != "app" || databases[0].Version != 3 || len(databases[0].Stores) != 2 {
+		t.Fatalf("读取结果 = %s", captured)
+	}
+	if store := databases[0].Stores[0]; len(store.Records) != 1 || store.Skipped != 1 || string(store.KeyPath) != "null" {
+		t.Errorf("无法编码的Blob应跳过，kv仓库 = %+v", store)
+	}
+	if store := databases[0].Stores[1]; string(store.KeyPath) != `"id"` || len(store.Indexes) != 1 || !store.Indexes[0].Unique {
+		t.Errorf("users仓库 = %+v", store)
+	}
+
+	// 目标页面: 没有app数据库，prefs数据库已有theme，theme不应被覆盖
+	restored := runNode(t, fakeIndexedDB+`
+databases.prefs = new Database("prefs", 1);
+var prefs = databases.prefs.createObjectStore("kv");
+prefs.records.set('"theme"', {key: "theme", value: "light"});
+var started = `+IndexedDBRestoreScript(databases)+`;
+setTimeout(function () {
+    var token = databases.app.stores.kv.records.get('"token"').value;
+    var user = databases.app.stores.users.records.get("1").value;
+    console.log(JSON.stringify({
+        started: started,
+        version: databases.app.version,
+        token: token["$t"] === "x" && token.d instanceof Date && token.d.getTime() === 0 && token.b instanceof Uint8Array &&
+            token.b.join() === "1,2" && Number.isNaN(token.n) && token.m.get(1) === "one",
+        user: user.name,
+        index: databases.app.stores.users.indexes.byName.unique,
+        theme: prefs.records.get('"theme"').value,
+        lang: prefs.records.get('"lang"').value
+    }));
+}, 100);`)
+	var got struct {
+		Started int    `json:"started"`
+		Version int    `json:"version"`
+		Token   bool   `json:"token"`
+		User    string `json:"user"`
+		Index   bool   `json:"index"`
+		Theme   string `json:"theme"`
+		Lang    string `json:"lang"`
+	}
+	if err := json.Unmarshal(restored, &got); err != nil {
+		t.Fatalf("解析输出失败: %v\n%s", err, restored)
+	}
+	if got.Started != 2 || got.Version != 3 || !got.Token || got.User != "a" || !got.Index || got.Theme != "light" || got.Lang != "zh" {
+		t.Errorf("写回结果 = %+v", got)
+	}
+}
+
+func TestIndexedDBCaptureScript_Limit(t *testing.T) {
+	output := runNode(t, fakeIndexedDB+`
+databases.app = new Database("app", 1);
+var store = databases.app.createObjectStore("kv");
+for (var i = 0; i < 5; i++) {
+    store.records.set(String(i), {key: i, value: "v" + i});
+}
+`+indexedDBCaptureScript(3, 1<<20)+`.then(function (result) { process.stdout.write(result); });`)
+	var databases []IndexedDBDatabase
+	if err := json.Unmarshal(output, &databases); err != nil {
+		t.Fatalf("解析读取结果失败: %v\n%s", err, output)
+	}
+	if store := databases[0].Stores[0]; len(store.Records) != 3 || store.Skipped != 2 {
+		t.Errorf("超出数量限制的记录应跳过，kv仓库 = %+v", store)
+	}
+}
+
+func TestIndexedDBDatabase_UnmarshalJSON(t *testing.T) {
+	var storage OriginStorage
+	data := `{"origin":"https://ad.oceanengine.com","local_storage":{},"indexed_db":["cache",{"name":"app","version":2,"stores":[]}]}`
+	if err := json.Unmarshal([]byte(data), &storage); err != nil {
+		t.Fatal(err)
+	}
+	want := []IndexedDBDatabase{{Name: "cache"}, {Name: "app", Version: 2, Stores: []IndexedDBStore{}}}
+	if !reflect.DeepEqual(storage.IndexedDB, want) {
+		t.Errorf("IndexedDB = %+v, want %+v", storage.IndexedDB, want)
+	}
+	// 只有名称的数据库不创建
+	output := runNode(t, fakeIndexedDB+`var started = `+IndexedDBRestoreScript(want)+`;
+setTimeout(function () { console.log(JSON.stringify({started: started, cache: "cache" in databases})); }, 50);`)
+	if got := strings.TrimSpace(string(output)); got != `{"started":1,"cache":false}` {
+		t.Errorf("写回结果 = %s", got)
+	}
+}
@@ -3,14 +3,16 @@
 package main
 
 import (
+	"cef/internal/audit"       // 访问审计日志
 	"cef/internal/browser"     // 浏览器初始化和事件处理
 	"cef/internal/config"      // 配置管理
 	"cef/internal/fingerprint" // 指纹伪装
 	"cef/internal/security"    // 安全控制（白名单等）
 	"cef/pkg/external/aegis"
-	"embed"                            // Go内置的文件嵌入功能
-	"github.com/energye/energy/v2/cef" // Energy CEF核心包
-	"log"                              // 日志记录
+	"embed"                                    // Go内置的文件嵌入功能
+	"github.com/energye/energy/v2/cef"         // Energy CEF核心包
+	"github.com/energye/energy/v2/cef/process" // 判断当前是否为主进程
+	"log"                                      // 日志记录
 )
 
 // 使用Go的embed指令将resources目录下的所有文件嵌入到程序中
@@ -33,12 +35,26 @@ func main() {
 
 	aegis.SetDefault(aegis.NewAegisClient(configLoader.ExternalConfig.AegisAddr.Mode))
 
+	// 初始化访问审计日志
+	// 每个CEF子进程（渲染、GPU等）都会执行main，只在主进程中写入和上报，避免多个进程同时轮转同一文件和重复上报
+	if process.Args.IsMain() {
+		if auditLogger, err := audit.NewLogger(configLoader.ExternalConfig.Audit); err != nil {
+			log.Printf(" 警告：访问审计日志初始化失败: %v", err)
+		} else {
+			audit.SetDefault(auditLogger)
+			defer auditLogger.Close()
+		}
+	}
+
 	// 获取配置实例
 	browserConfigLoader := configLoader.GetBrowserConfigLoader()
 	whitelistConfigLoader := configLoader.GetWhitelistConfigLoader()
 	allowedEmailsConfigLoader := configLoader.GetAllowedEmailsConfigLoader()
 
 	// 2. 初始化安全控制模块
+	if err := security.ValidateRules(whitelistConfigLoader()); err != nil {
+		log.Fatalf("白名单配置无效: %v", err)
+	}
 	whitelistValidator := security.NewWhitelistValidator(whitelistConfigLoader)
 	log.Println("安全控制模块初始化完成")
 
@@ -53,16 +69,17 @@ func main() {
 	scriptGenerator := fingerprint.NewGenerator(browserConfigLoader, allowedEmailsConfigLoader)
 	log.Println("指纹伪装模块初始化完成")
 
-	notifyAccountChangeChan := make(chan string, 1)
 	// 4. 初始化浏览器事件处理器
 	eventHandler := browser.NewEventHandler(
 		browserConfigLoader,
 		whitelistValidator,
 		scriptManager,
 		scriptGenerator,
-		notifyAccountChangeChan,
 	)
 	defer eventHandler.Close()
+	eventHandler.GetDownloadManager().SetOnProgress(func(event browser.DownloadEvent) {
+		log.Printf("下载进度: %s %d%% (%d/%d)", event.FullPath, event.Percent, event.ReceivedBytes, event.TotalBytes)
+	})
 
 	log.Println("浏览器事件处理器初始化完成")
 
@@ -75,20 +92,9 @@ func main() {
 	cef.BrowserWindow.Config.IconFS = "resources/icon.png"
 	app := browserInit.Initialize()
 
-	//go func() {
-	//	for account := range notifyAccountChangeChan {
-	//		fmt.Println("收到AccountChange信息， account:", account)
-	//		browserConfig := browserConfigLoader(account)
-	//		fmt.Println("开始设置app.SetUserAgent:", browserConfig.Basic.UserAgent)
-	//		// 设置全局User-Agent（影响所有HTTP请求）
-	//		app.SetUserAgent(browserConfig.Basic.UserAgent)
-	//
-	//		// 设置平台信息
-	//		//app.AddCustomCommandLine("--user-agent", browserConfig.Basic.UserAgent)
-	//		fmt.Println("成功设置app.SetUserAgent")
-	//	}
-	//	fmt.Println("notifyAccountChangeChan关闭")
-	//}()
+	eventHandler.GetAccountEvents().Subscribe("log", func(event browser.AccountChangeEvent) {
+		log.Printf("账户切换: 窗口 %d, %s -> %s", event.WindowId, event.Previous, event.Account)
+	})
 
 	log.Println("启动 CEF 应用...")
 	// 6. 启动并运行应用程序
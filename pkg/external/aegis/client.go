@@ -1,8 +1,10 @@
 package aegis
 
 import (
+	"bytes"
 	pkgHttp "cef/pkg/http"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -57,3 +59,20 @@ func (c *Client) GetConfigWithResult(serviceName string, result any, keys ...str
 	}
 	return pkgHttp.DoWithJsonResult(context.Background(), pkgHttp.MustNewRequest(http.MethodGet, c.addr+path, nil), result)
 }
+
+// ReportEvents /report/{namespace}/{stream}/batch
+func (c *Client) ReportEvents(stream string, events any) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(events) failed, %w", err)
+	}
+	path := fmt.Sprintf("/report/cef/%s/batch", stream)
+	var resp ReportResponse
+	if err = pkgHttp.DoWithJsonResult(context.Background(), pkgHttp.MustNewRequest(http.MethodPost, c.addr+path, bytes.NewReader(body)), &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("result code is not 0, msg: %s", resp.Msg)
+	}
+	return nil
+}
@@ -7,3 +7,8 @@ type ConfigGetResponse struct {
 		ConfigMap map[string]interface{} `json:"config_map"` // ConfigMap
 	} `json:"data"`
 }
+
+type ReportResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
@@ -319,69 +319,6 @@
         }
     }, 1000); // 每秒检查一次
     
-    // 7. 使用MutationObserver监控DOM变化，确保头部设置
-    const observer = new MutationObserver(function(mutations) {
-        mutations.forEach(function(mutation) {
-            // 检查是否有新的iframe被添加，需要重新应用伪装
-            if (mutation.type === 'childList') {
-                mutation.addedNodes.forEach(function(node) {
-                    if (node.tagName === 'IFRAME') {
-                        console.log(' 检测到新iframe，重新应用头部伪装');
-                        // 在iframe加载完成后重新应用伪装
-                        node.addEventListener('load', function() {
-                            try {
-                                const iframeDoc = node.contentDocument || node.contentWindow.document;
-                                if (iframeDoc) {
-                                    // 在iframe中重新定义navigator属性
-                                    const iframeWindow = node.contentWindow;
-                                    if (iframeWindow) {
-                                        Object.defineProperty(iframeWindow.navigator, 'platform', {
-                                            get: function() {
-                                                return navigatorPlatform;
-                                            }
-                                        });
-                                        
-                                        // 重新定义userAgentData
-                                        Object.defineProperty(iframeWindow.navigator, 'userAgentData', {
-                                            get: function() {
-                                                return {
-                                                    brands: [
-                                                        { brand: "Not_A Brand", version: "8" },
-                                                        { brand: "Chromium", version: "120" },
-                                                        { brand: "Google Chrome", version: "120" }
-                                                    ],
-                                                    mobile: false,
-                                                    platform: platformRaw,
-                                                    getHighEntropyValues: function(hints) {
-                                                        return Promise.resolve({
-                                                            platform: platformRaw,
-                                                            platformVersion: platformRaw === "Windows" ? "10.0.0" : "15.0.0",
-                                                            architecture: "x86",
-                                                            model: "",
-                                                            uaFullVersion: "120.0.0.0",
-                                                            brands: [
-                                                                { brand: "Not_A Brand", version: "8" },
-                                                                { brand: "Chromium", version: "120" },
-                                                                { brand: "Google Chrome", version: "120" }
-                                                            ]
-                                                        });
-                                                    }
-                                                };
-                                            }
-                                        });
-                                    }
-                                }
-                            } catch (e) {
-                                console.warn(' iframe头部伪装失败:', e);
-                            }
-                        });
-                    }
-                });
-            }
-        });
-    });
-    
-    // 开始观察DOM变化
-    observer.observe(document, { childList: true, subtree: true });
+    // 7. iframe和Worker无需在此处理：每个框架的JS上下文创建时都会注入本脚本，网络加载的Worker由Worker脚本的响应过滤覆盖，blob:和data:地址的Worker由Worker注入脚本覆盖
     
 })();
\ No newline at end of file